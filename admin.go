@@ -0,0 +1,309 @@
+package razbox
+
+import (
+	"path"
+	"strings"
+
+	"github.com/razzie/beepboop"
+	"github.com/razzie/razbox/internal"
+)
+
+// SetAdminPassword enables the admin console with the given password or key (an empty string disables it)
+func (api *API) SetAdminPassword(pw string) error {
+	if len(pw) == 0 {
+		api.admin = nil
+		return nil
+	}
+	admin, err := internal.NewAdmin(pw)
+	if err != nil {
+		return err
+	}
+	api.admin = admin
+	return nil
+}
+
+// IsAdminEnabled returns whether the admin console is enabled
+func (api *API) IsAdminEnabled() bool {
+	return api.admin != nil
+}
+
+// IsAdmin returns whether the session has admin access
+func (api *API) IsAdmin(sess Session) bool {
+	return api.admin != nil && api.admin.EnsureAccess(sess) == nil
+}
+
+func (api *API) ensureAdminAccess(sess Session) error {
+	if api.admin == nil {
+		return &ErrAdminDisabled{}
+	}
+	if err := api.admin.EnsureAccess(sess); err != nil {
+		return &ErrNoAdminAccess{}
+	}
+	return nil
+}
+
+// AuthAdmin grants the session admin access
+func (api *API) AuthAdmin(pr *beepboop.PageRequest, password string) error {
+	sess := pr.Session()
+
+	if api.admin == nil {
+		return &ErrAdminDisabled{}
+	}
+
+	if err := api.checkRateLimit("auth", sess.IP(), api.AuthsPerMin); err != nil {
+		return err
+	}
+
+	if !api.admin.TestPassword(password) {
+		pr.Log("admin auth failed from", sess.IP())
+		return &ErrWrongPassword{}
+	}
+
+	access := make(beepboop.AccessMap)
+	access.Add("admin", "razbox", api.admin.GetAccessCode())
+	return sess.MergeAccess(access)
+}
+
+// AdminFolderInfo ...
+type AdminFolderInfo struct {
+	Folder         string          `json:"folder"`
+	Size           int64           `json:"size"`
+	Files          int             `json:"files"`
+	Subfolders     int             `json:"subfolders"`
+	ReadProtected  bool            `json:"read_protected"`
+	WriteProtected bool            `json:"write_protected"`
+	Uploadable     bool            `json:"uploadable"`
+	Members        int             `json:"members"`
+	APITokens      int             `json:"api_tokens"`
+	Settings       *FolderSettings `json:"settings"`
+}
+
+func newAdminFolderInfo(folder *internal.Folder) (*AdminFolderInfo, error) {
+	usage, err := folder.GetUsage()
+	if err != nil {
+		return nil, err
+	}
+	return &AdminFolderInfo{
+		Folder:         folder.RelPath,
+		Size:           usage.Size,
+		Files:          usage.Files,
+		Subfolders:     usage.Subfolders,
+		ReadProtected:  len(folder.Config.ReadPassword) > 0,
+		WriteProtected: len(folder.Config.WritePassword) > 0,
+		Uploadable:     len(folder.Config.UploadPassword) > 0,
+		Members:        len(folder.Config.Members),
+		APITokens:      len(folder.Config.APITokens),
+		Settings:       newFolderSettings(folder.GetSettings()),
+	}, nil
+}
+
+// GetAllFolders returns every config root folder with its storage usage
+func (api *API) GetAllFolders(sess Session) ([]*AdminFolderInfo, error) {
+	if err := api.ensureAdminAccess(sess); err != nil {
+		return nil, err
+	}
+
+	roots, err := internal.FindConfigRoots(api.root)
+	if err != nil {
+		return nil, err
+	}
+
+	folders := make([]*AdminFolderInfo, 0, len(roots))
+	for _, root := range roots {
+		folder, err := internal.GetFolder(api.root, root)
+		if err != nil {
+			continue
+		}
+		info, err := newAdminFolderInfo(folder)
+		if err != nil {
+			return nil, err
+		}
+		folders = append(folders, info)
+	}
+	return folders, nil
+}
+
+// GetFolderInfo returns a config root folder with its storage usage
+func (api *API) GetFolderInfo(sess Session, folderName string) (*AdminFolderInfo, error) {
+	if err := api.ensureAdminAccess(sess); err != nil {
+		return nil, err
+	}
+
+	folder, unlock, cached, err := api.getFolder(sess, folderName)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		defer api.goCacheFolder(folder)
+	}
+	defer unlock()
+
+	if folder.ConfigInherited {
+		return nil, &ErrNotFound{}
+	}
+	return newAdminFolderInfo(folder)
+}
+
+// CreateFolderOptions ...
+type CreateFolderOptions struct {
+	Folder         string
+	ReadPassword   string
+	WritePassword  string
+	UploadPassword string
+	Settings       FolderSettings
+}
+
+// CreateFolder creates a new folder with its own config
+func (api *API) CreateFolder(sess Session, o *CreateFolderOptions) (string, error) {
+	if err := api.ensureAdminAccess(sess); err != nil {
+		return "", err
+	}
+
+	dirs := strings.Split(path.Clean(strings.Trim(o.Folder, "/")), "/")
+	for i, dir := range dirs {
+		safeName, err := getSafeFilename(dir)
+		if err != nil {
+			return "", &ErrInvalidName{Name: o.Folder}
+		}
+		dirs[i] = safeName
+	}
+	relPath := path.Join(dirs...)
+	if internal.IsReservedPath(relPath) {
+		return "", &ErrInvalidName{Name: o.Folder}
+	}
+	if _, err := api.storage.Stat(relPath); err == nil {
+		return "", &ErrFolderExists{Folder: relPath}
+	}
+
+	err := api.storage.MkdirAll(relPath)
+	if err != nil {
+		return "", err
+	}
+
+	folder := &internal.Folder{
+		Root:             api.root,
+		RelPath:          relPath,
+		ConfigRootFolder: relPath,
+	}
+	err = folder.SetPasswords(o.ReadPassword, o.WritePassword)
+	if err != nil {
+		internal.RemoveFolderTree(api.root, relPath)
+		return "", err
+	}
+	if len(o.UploadPassword) > 0 {
+		err = folder.SetUploadPassword(o.UploadPassword)
+		if err != nil {
+			internal.RemoveFolderTree(api.root, relPath)
+			return "", err
+		}
+	}
+	err = folder.SetSettings(o.Settings.toInternal())
+	if err != nil {
+		internal.RemoveFolderTree(api.root, relPath)
+		return "", err
+	}
+
+	if api.cache != nil {
+		// the parent folder (if any) has a new subfolder
+		api.cache.TouchFolder(path.Dir(relPath))
+	}
+	api.audit(sess, folder, internal.AuditCreateSubfolder, relPath, "created by admin")
+	return relPath, nil
+}
+
+// AdminSetFolderSettings changes the config options of a config root folder
+func (api *API) AdminSetFolderSettings(sess Session, folderName string, settings *FolderSettings) error {
+	if err := api.ensureAdminAccess(sess); err != nil {
+		return err
+	}
+
+	changed := false
+	folder, unlock, cached, err := api.getFolderForWrite(sess, folderName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if !cached || changed {
+			api.goCacheFolder(folder)
+		}
+	}()
+	defer unlock()
+
+	err = api.setFolderSettings(sess, folder, settings)
+	if err != nil {
+		return err
+	}
+	changed = true
+	return nil
+}
+
+// AdminResetFolderPassword sets the password of a config root folder without knowing the previous one
+func (api *API) AdminResetFolderPassword(sess Session, folderName, accessType, password string) error {
+	if err := api.ensureAdminAccess(sess); err != nil {
+		return err
+	}
+
+	changed := false
+	folder, unlock, cached, err := api.getFolderForWrite(sess, folderName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if !cached || changed {
+			api.goCacheFolder(folder)
+		}
+	}()
+	defer unlock()
+
+	err = folder.SetPassword(accessType, password)
+	if err != nil {
+		return err
+	}
+	changed = true
+	api.uncacheInheritedSubfolders(folder)
+	api.audit(sess, folder, internal.AuditChangePassword, folder.RelPath, accessType+" (reset by admin)")
+	return nil
+}
+
+// DeleteFolder permanently deletes a config root folder with all of its files and subfolders
+func (api *API) DeleteFolder(sess Session, folderName string) error {
+	if err := api.ensureAdminAccess(sess); err != nil {
+		return err
+	}
+
+	folder, unlock, _, err := api.getFolderForWrite(sess, folderName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if folder.ConfigInherited || folder.RelPath == "." {
+		return &ErrNotDeletable{Name: folderName}
+	}
+
+	if api.cache != nil {
+		roots, _ := internal.FindConfigRoots(api.root)
+		for _, root := range roots {
+			if root != folder.RelPath && !strings.HasPrefix(root, folder.RelPath+"/") {
+				continue
+			}
+			if sub, err := internal.GetFolder(api.root, root); err == nil {
+				api.uncacheInheritedSubfolders(sub)
+			}
+			api.cache.UncacheFolder(root)
+		}
+	}
+
+	err = internal.RemoveFolderTree(api.root, folder.RelPath)
+	if err != nil {
+		return err
+	}
+
+	if api.cache != nil {
+		api.cache.TouchFolder(path.Dir(folder.RelPath))
+	}
+	if parent, err := internal.GetFolder(api.root, path.Dir(folder.RelPath)); err == nil {
+		api.audit(sess, parent, internal.AuditDeleteSubfolder, folder.RelPath, "deleted by admin")
+	}
+	return nil
+}
@@ -5,23 +5,35 @@ import (
 	"sync"
 	"time"
 
+	"github.com/go-redis/redis/v7"
 	"github.com/razzie/beepboop"
+	"github.com/razzie/razbox/internal"
 )
 
+// memoryCacheSize is the max number of folders in the in-process cache (without Redis)
+const memoryCacheSize = 1000
+
 // API ...
 type API struct {
 	root                string
-	db                  *beepboop.DB
-	folderLock          sync.Map
+	storage             internal.Storage
+	cache               internal.FolderCache
+	limiter             internal.RateLimiter
+	folderLocks         folderLocks
+	redisLocks          *redisLocks
+	uploadLock          sync.Map
+	shareDownloadLock   sync.Mutex
+	admin               *internal.Admin
 	CacheDuration       time.Duration
 	CookieExpiration    time.Duration
 	ThumbnailRetryAfter time.Duration
 	AuthsPerMin         int
+	LockTimeout         time.Duration
 }
 
 // NewAPI ...
 func NewAPI(root string) (*API, error) {
-	if !filepath.IsAbs(root) {
+	if internal.IsLocalRoot(root) && !filepath.IsAbs(root) {
 		var err error
 		root, err = filepath.Abs(root)
 		if err != nil {
@@ -29,24 +41,70 @@ func NewAPI(root string) (*API, error) {
 		}
 	}
 
+	storage, err := internal.GetStorage(root)
+	if err != nil {
+		return nil, err
+	}
+
 	return &API{
 		root:                root,
+		storage:             storage,
 		CacheDuration:       time.Hour,
 		CookieExpiration:    time.Hour * 24 * 7,
 		ThumbnailRetryAfter: time.Hour,
 		AuthsPerMin:         3,
+		LockTimeout:         time.Second * 30,
 	}, nil
 }
 
-// ConnectDB ...
+// ConnectDB connects to Redis, or falls back to an in-process cache and rate limiter if it fails
+// (which are not shared with other instances)
 func (api *API) ConnectDB(redisUrl string) (*beepboop.DB, error) {
+	// beepboop doesn't expose its client, so the folder cache and leases get their own
+	// (the URL is parsed first, so there's nothing to close if it's invalid)
+	opt, err := redis.ParseURL(redisUrl)
+	if err != nil {
+		api.useMemoryCache()
+		return nil, err
+	}
+
 	db, err := beepboop.NewDB(redisUrl)
 	if err != nil {
+		api.useMemoryCache()
 		return nil, err
 	}
+	client := redis.NewClient(opt)
 
 	db.CacheDuration = api.CacheDuration
 	db.SessionDuration = api.CookieExpiration
-	api.db = db
+	api.cache = internal.NewRedisFolderCache(client, api.CacheDuration)
+	api.limiter = db
+	api.redisLocks = newRedisLocks(client)
 	return db, nil
 }
+
+// useMemoryCache sets up the in-process folder cache and rate limiter (used without Redis)
+func (api *API) useMemoryCache() {
+	api.cache = internal.NewMemoryFolderCache(api.CacheDuration, memoryCacheSize)
+	api.limiter = internal.NewMemoryRateLimiter()
+}
+
+// checkRateLimit returns an ErrRateLimitExceeded (with the time until the next permitted request if it's known)
+// if the IP exceeded the rate limit of the request type
+func (api *API) checkRateLimit(reqType, ip string, rate int) error {
+	switch limiter := api.limiter.(type) {
+	case nil:
+		return nil
+	case *internal.MemoryRateLimiter:
+		if ok, wait := limiter.Take(reqType, ip, rate); !ok {
+			return &ErrRateLimitExceeded{ReqPerMin: rate, Wait: wait}
+		}
+		return nil
+	}
+
+	// beepboop counts the requests until a minute passes without any, so every rejected request restarts the wait
+	if ok, err := api.limiter.IsWithinRateLimit(reqType, ip, rate); !ok && err == nil {
+		return &ErrRateLimitExceeded{ReqPerMin: rate, Wait: time.Minute}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package razbox
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/razzie/razbox/internal"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func init() {
+	internal.BcryptCost = bcrypt.MinCost
+}
+
+// newTestAPI returns an API on a new in-memory storage with a folder "f"
+// that has read, write and upload passwords (which are the same as the access types)
+// and the config changed by the given functions
+func newTestAPI(t *testing.T, configure ...func(*internal.FolderConfig)) *API {
+	t.Helper()
+	root := "mem://" + strings.Replace(t.Name(), "/", "-", -1)
+	s, err := internal.GetStorage(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.MkdirAll("f"); err != nil {
+		t.Fatal(err)
+	}
+	folder := &internal.Folder{
+		Root:    root,
+		RelPath: "f",
+		Config: internal.FolderConfig{
+			MaxFileSizeMB:   1,
+			MaxFolderSizeMB: 10,
+		},
+	}
+	for _, configure := range configure {
+		configure(&folder.Config)
+	}
+	if err := folder.SetPasswords("read", "write"); err != nil {
+		t.Fatal(err)
+	}
+	if err := folder.SetUploadPassword("upload"); err != nil {
+		t.Fatal(err)
+	}
+
+	api, err := NewAPI(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api.cache = internal.NewMemoryFolderCache(api.CacheDuration, memoryCacheSize)
+	return api
+}
+
+// newTestSession returns a session that has the given access types to folder "f"
+func newTestSession(t *testing.T, api *API, accessTypes ...string) Session {
+	t.Helper()
+	folder, err := internal.GetFolder(api.root, "f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := NewAnonymousSession(context.Background(), "127.0.0.1")
+	for _, accessType := range accessTypes {
+		token, err := folder.GetAccessToken(accessType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sess.MergeAccess(token)
+	}
+	return sess
+}
+
+func getTestFile(t *testing.T, api *API, filename string) *internal.File {
+	t.Helper()
+	folder, err := internal.GetFolder(api.root, "f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := folder.GetFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestRateLimitRetryAfter(t *testing.T) {
+	tests := []struct {
+		name           string
+		redis          bool
+		wantRetryAfter time.Duration
+	}{
+		// the token bucket gets a new token in a second with 60 requests per minute
+		{"memory", false, time.Second},
+		// the Redis counter expires a minute after the last request
+		{"redis", true, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			api.AuthsPerMin = 60
+			var mr *miniredis.Miniredis
+			if tt.redis {
+				mr = miniredis.RunT(t)
+				if _, err := api.ConnectDB("redis://" + mr.Addr()); err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				api.useMemoryCache()
+			}
+			for i := 0; i < api.AuthsPerMin; i++ {
+				api.AuthShareLink(newTestSession(t, api), "invalid", "")
+			}
+			if mr != nil {
+				mr.FastForward(45 * time.Second)
+			}
+
+			err := api.AuthShareLink(newTestSession(t, api), "invalid", "")
+			e, ok := err.(*ErrRateLimitExceeded)
+			if !ok {
+				t.Fatalf("got error %v, want ErrRateLimitExceeded", err)
+			}
+			if retryAfter := e.RetryAfter(); retryAfter != tt.wantRetryAfter {
+				t.Errorf("retry after = %v, want %v", retryAfter, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func TestConnectDB(t *testing.T) {
+	mr := miniredis.RunT(t)
+	tests := []struct {
+		name      string
+		url       string
+		wantRedis bool
+	}{
+		{"connected", "redis://" + mr.Addr(), true},
+		{"invalid url", "http://" + mr.Addr(), false},
+		{"unreachable", "redis://127.0.0.1:1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			db, err := api.ConnectDB(tt.url)
+			if connected := err == nil && db != nil; connected != tt.wantRedis {
+				t.Fatalf("connected = %t (%v), want %t", connected, err, tt.wantRedis)
+			}
+			// the API works without Redis too
+			if _, memory := api.limiter.(*internal.MemoryRateLimiter); memory == tt.wantRedis {
+				t.Errorf("memory rate limiter = %t, want %t", memory, !tt.wantRedis)
+			}
+			if redis := api.redisLocks != nil; redis != tt.wantRedis {
+				t.Errorf("redis locks = %t, want %t", redis, tt.wantRedis)
+			}
+		})
+	}
+}
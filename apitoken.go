@@ -0,0 +1,174 @@
+package razbox
+
+import (
+	"context"
+	"path"
+
+	"github.com/razzie/razbox/internal"
+)
+
+// APITokenInfo ...
+type APITokenInfo struct {
+	Name       string `json:"name"`
+	AccessType string `json:"access_type"`
+	Created    int64  `json:"created"`
+}
+
+// NewTokenSession returns a Session that has the access permitted by the given API token of a folder
+func (api *API) NewTokenSession(ctx context.Context, ip, folderName, token string) (Session, error) {
+	folder, cached, err := api.getFolderNoLock(path.Clean(folderName))
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		defer api.goCacheFolder(folder)
+	}
+
+	// tokens are stored in the config root, which is kept up to date in cache
+	if folder.ConfigInherited {
+		folder, cached, err = api.getFolderNoLock(folder.ConfigRootFolder)
+		if err != nil {
+			return nil, err
+		}
+		if !cached {
+			defer api.goCacheFolder(folder)
+		}
+	}
+
+	t, err := folder.FindAPIToken(token)
+	for err != nil && folder.Config.ParentManaged && folder.RelPath != "." {
+		// tokens of the parent folder also work in the folders it manages
+		folder, cached, err = api.getFolderNoLock(path.Dir(folder.ConfigRootFolder))
+		if err != nil {
+			break
+		}
+		if !cached {
+			defer api.goCacheFolder(folder)
+		}
+		if folder.ConfigInherited {
+			folder, _, err = api.getFolderNoLock(folder.ConfigRootFolder)
+			if err != nil {
+				break
+			}
+		}
+		t, err = folder.FindAPIToken(token)
+	}
+	if err != nil {
+		return nil, &ErrInvalidAPIToken{}
+	}
+	access, err := folder.GetAPITokenAccess(t)
+	if err != nil {
+		return nil, &ErrInvalidAPIToken{}
+	}
+
+	return &tokenSession{
+		ctx:       ctx,
+		ip:        ip,
+		access:    access,
+		tokenName: t.Name,
+	}, nil
+}
+
+// GetAPITokens ...
+func (api *API) GetAPITokens(sess Session, folderName string) ([]*APITokenInfo, error) {
+	folder, unlock, cached, err := api.getFolder(sess, folderName)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		defer api.goCacheFolder(folder)
+	}
+	defer unlock()
+
+	err = folder.EnsureReadAccess(sess)
+	if err != nil {
+		return nil, &ErrNoReadAccess{Folder: folderName}
+	}
+
+	err = folder.EnsureAdminAccess(sess)
+	if err != nil {
+		return nil, &ErrNoWriteAccess{Folder: folderName}
+	}
+
+	tokens := make([]*APITokenInfo, 0, len(folder.Config.APITokens))
+	for _, t := range folder.Config.APITokens {
+		tokens = append(tokens, &APITokenInfo{
+			Name:       t.Name,
+			AccessType: t.AccessType,
+			Created:    t.Created.Unix(),
+		})
+	}
+	return tokens, nil
+}
+
+// CreateAPIToken creates a new API token and returns its value (which is not stored in plain text)
+func (api *API) CreateAPIToken(sess Session, folderName, name, accessType string) (string, error) {
+	changed := false
+	folder, unlock, cached, err := api.getFolderForWrite(sess, folderName)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if !cached || changed {
+			api.goCacheFolder(folder)
+		}
+	}()
+	defer unlock()
+
+	err = folder.EnsureReadAccess(sess)
+	if err != nil {
+		return "", &ErrNoReadAccess{Folder: folderName}
+	}
+
+	err = folder.EnsureAdminAccess(sess)
+	if err != nil {
+		return "", &ErrNoWriteAccess{Folder: folderName}
+	}
+
+	if len(name) == 0 {
+		return "", &ErrInvalidName{Name: name}
+	}
+
+	token, err := folder.CreateAPIToken(name, accessType)
+	if err != nil {
+		return "", err
+	}
+
+	changed = true
+	api.audit(sess, folder, internal.AuditAPIToken, folder.RelPath, "created "+name+" ("+accessType+")")
+	return token, nil
+}
+
+// RevokeAPIToken ...
+func (api *API) RevokeAPIToken(sess Session, folderName, name string) error {
+	changed := false
+	folder, unlock, cached, err := api.getFolderForWrite(sess, folderName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if !cached || changed {
+			api.goCacheFolder(folder)
+		}
+	}()
+	defer unlock()
+
+	err = folder.EnsureReadAccess(sess)
+	if err != nil {
+		return &ErrNoReadAccess{Folder: folderName}
+	}
+
+	err = folder.EnsureAdminAccess(sess)
+	if err != nil {
+		return &ErrNoWriteAccess{Folder: folderName}
+	}
+
+	err = folder.RevokeAPIToken(name)
+	if err != nil {
+		return err
+	}
+
+	changed = true
+	api.audit(sess, folder, internal.AuditAPIToken, folder.RelPath, "revoked "+name)
+	return nil
+}
@@ -11,7 +11,6 @@ import (
 
 	"github.com/mholt/archiver"
 	"github.com/nwaples/rardecode"
-	"github.com/razzie/beepboop"
 	"github.com/razzie/razbox/internal"
 )
 
@@ -29,10 +28,10 @@ type ArchiveWalker interface {
 }
 
 // GetArchiveWalker ...
-func (api *API) GetArchiveWalker(sess *beepboop.Session, filePath string) (ArchiveWalker, error) {
+func (api *API) GetArchiveWalker(sess Session, filePath string) (ArchiveWalker, error) {
 	filePath = path.Clean(filePath)
 	dir := path.Dir(filePath)
-	folder, unlock, cached, err := api.getFolder(dir)
+	folder, unlock, cached, err := api.getFolder(sess, dir)
 	if err != nil {
 		return nil, err
 	}
@@ -63,7 +62,7 @@ func (api *API) GetArchiveWalker(sess *beepboop.Session, filePath string) (Archi
 
 	return &archiveWalker{
 		ctx:     sess.Context(),
-		archive: file.GetInternalFilename(),
+		archive: file,
 		walker:  walker,
 	}, nil
 }
@@ -99,12 +98,18 @@ func (af *archiveFile) Read(p []byte) (int, error) {
 
 type archiveWalker struct {
 	ctx     context.Context
-	archive string
+	archive *internal.File
 	walker  archiver.Walker
 }
 
 func (aw *archiveWalker) Walk(walkFn func(ArchiveFile) error) error {
-	return aw.walker.Walk(aw.archive, func(f archiver.File) error {
+	filename, cleanup, err := aw.archive.GetLocalFilename()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	return aw.walker.Walk(filename, func(f archiver.File) error {
 		if err := aw.ctx.Err(); err != nil {
 			return err
 		}
@@ -0,0 +1,132 @@
+package razbox
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/razzie/razbox/internal"
+)
+
+// AuditActions lists the actions that are recorded in the audit logs
+var AuditActions = internal.AuditActions
+
+// AuditEntry ...
+type AuditEntry struct {
+	Time    int64  `json:"time"`
+	IP      string `json:"ip,omitempty"`
+	Session string `json:"session,omitempty"`
+	Action  string `json:"action"`
+	Path    string `json:"path,omitempty"`
+	Details string `json:"details,omitempty"`
+}
+
+// AuditLogFilter ...
+type AuditLogFilter struct {
+	Action string
+	Query  string // matches IP, session, path or details
+	Since  time.Time
+	Until  time.Time
+	Limit  int // 0 = DefaultAuditLogLimit
+}
+
+// DefaultAuditLogLimit is the maximum number of audit log entries returned when the filter has no limit
+const DefaultAuditLogLimit = 500
+
+// getSessionName describes who the session belongs to (without revealing its secrets)
+func (api *API) getSessionName(sess Session, folder *internal.Folder) string {
+	if api.IsAdmin(sess) {
+		return "admin"
+	}
+	if m := folder.GetSessionMember(sess); m != nil {
+		return "member:" + m.Name
+	}
+	if token, ok := getShareToken(sess); ok {
+		if parts := strings.Split(token, "."); len(parts) == 4 {
+			return "share:" + parts[1]
+		}
+		return "share"
+	}
+	if s, ok := sess.(*tokenSession); ok && len(s.tokenName) > 0 {
+		return "api-token:" + s.tokenName
+	}
+
+	var name string
+	if s, ok := sess.(interface{ SessionID() string }); ok && len(s.SessionID()) > 0 {
+		name = "session:" + internal.Hash(s.SessionID())[:12]
+	}
+	if accessType := folder.GetSessionPassword(sess); len(accessType) > 0 {
+		if len(name) > 0 {
+			return name + " (" + accessType + " password)"
+		}
+		return accessType + " password"
+	}
+	if len(name) > 0 {
+		return name
+	}
+	return "anonymous"
+}
+
+// audit appends an entry to the audit log of a folder
+func (api *API) audit(sess Session, folder *internal.Folder, action, relPath, details string) {
+	e := &internal.AuditEntry{
+		Time:    time.Now(),
+		IP:      sess.IP(),
+		Session: api.getSessionName(sess, folder),
+		Action:  action,
+		Path:    relPath,
+		Details: details,
+	}
+	if err := internal.AppendAuditLog(api.root, folder.RelPath, e); err != nil {
+		log.Print("audit log error:", err)
+	}
+}
+
+// GetAuditLog returns the filtered entries of a folder's audit log (newest first)
+func (api *API) GetAuditLog(sess Session, folderName string, filter *AuditLogFilter) ([]*AuditEntry, error) {
+	folder, unlock, cached, err := api.getFolder(sess, folderName)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		defer api.goCacheFolder(folder)
+	}
+	defer unlock()
+
+	err = folder.EnsureReadAccess(sess)
+	if err != nil {
+		return nil, &ErrNoReadAccess{Folder: folderName}
+	}
+
+	err = folder.EnsureWriteAccess(sess)
+	if err != nil {
+		return nil, &ErrNoWriteAccess{Folder: folderName}
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultAuditLogLimit
+	}
+	entries, err := internal.GetAuditLog(api.root, folder.RelPath, &internal.AuditFilter{
+		Action: filter.Action,
+		Query:  filter.Query,
+		Since:  filter.Since,
+		Until:  filter.Until,
+		Limit:  filter.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*AuditEntry, 0, len(entries))
+	for _, e := range entries {
+		results = append(results, &AuditEntry{
+			Time:    e.Time.Unix(),
+			IP:      e.IP,
+			Session: e.Session,
+			Action:  e.Action,
+			Path:    e.Path,
+			Details: e.Details,
+		})
+	}
+	return results, nil
+}
@@ -2,24 +2,35 @@ package razbox
 
 import (
 	"github.com/razzie/beepboop"
+	"github.com/razzie/razbox/internal"
 )
 
 // Auth ...
 func (api *API) Auth(pr *beepboop.PageRequest, folderName, accessType, password string) error {
 	sess := pr.Session()
 
-	if api.db != nil {
-		if len(sess.IP()) == 0 {
-			pr.Log("auth: no IP in request")
-		}
-		if ok, err := api.db.IsWithinRateLimit("auth", sess.IP(), api.AuthsPerMin); !ok && err == nil {
-			return &ErrRateLimitExceeded{ReqPerMin: api.AuthsPerMin}
+	if api.limiter != nil && len(sess.IP()) == 0 {
+		pr.Log("auth: no IP in request")
+	}
+	if err := api.checkRateLimit("auth", sess.IP(), api.AuthsPerMin); err != nil {
+		return err
+	}
+
+	upgrade, err := api.auth(sess, folderName, accessType, password)
+	if upgrade {
+		if err := api.upgradePassword(sess, folderName, accessType, password); err != nil {
+			pr.Log("auth: password upgrade failed:", err)
 		}
 	}
+	return err
+}
 
-	folder, unlock, cached, err := api.getFolder(folderName)
+// auth tests the password under the read lock of the folder
+// and returns whether the password needs an upgrade
+func (api *API) auth(sess *beepboop.Session, folderName, accessType, password string) (upgrade bool, err error) {
+	folder, unlock, cached, err := api.getFolder(sess, folderName)
 	if err != nil {
-		return err
+		return false, err
 	}
 	if !cached {
 		defer api.goCacheFolder(folder)
@@ -29,10 +40,32 @@ func (api *API) Auth(pr *beepboop.PageRequest, folderName, accessType, password
 	if folder.TestPassword(accessType, password) {
 		newToken, err := folder.GetAccessToken(accessType)
 		if err != nil {
-			return err
+			return false, err
 		}
-		return sess.MergeAccess(newToken)
+		api.audit(sess, folder, internal.AuditAuth, folder.RelPath, accessType)
+		return folder.NeedsPasswordUpgrade(accessType), sess.MergeAccess(newToken)
+	}
+
+	api.audit(sess, folder, internal.AuditAuthFailed, folder.RelPath, accessType)
+	return false, &ErrWrongPassword{}
+}
+
+// upgradePassword rehashes a legacy password under the write lock of the folder
+func (api *API) upgradePassword(sess Session, folderName, accessType, password string) error {
+	folder, unlock, cached, err := api.getFolderForWrite(sess, folderName)
+	if err != nil {
+		return err
+	}
+	if !cached {
+		defer api.goCacheFolder(folder)
 	}
+	defer unlock()
 
-	return &ErrWrongPassword{}
+	if err := folder.UpgradePassword(accessType, password); err != nil {
+		return err
+	}
+	if api.cache != nil && folder.ConfigRootFolder != folder.RelPath {
+		api.cache.TouchFolder(folder.ConfigRootFolder)
+	}
+	return nil
 }
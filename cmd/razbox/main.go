@@ -2,7 +2,9 @@ package main
 
 import (
 	"flag"
+	"io/ioutil"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/razzie/razbox"
@@ -18,17 +20,27 @@ var (
 	CookieExpiration    time.Duration
 	ThumbnailRetryAfter time.Duration
 	AuthsPerMin         int
+	LockTimeout         time.Duration
+	TrashReaperInterval time.Duration
+	WatchRoot           bool
+	AdminPassword       string
+	AdminKeyFile        string
 )
 
 func init() {
 	flag.StringVar(&RedisConnStr, "redis", "redis://localhost:6379", "Redis connection string")
-	flag.StringVar(&Root, "root", "./uploads", "Root directory of folders")
+	flag.StringVar(&Root, "root", "./uploads", "Root directory of folders (or a mem://<name> or s3://<key>:<secret>@<host>/<bucket> storage URL)")
 	flag.IntVar(&Port, "port", 8080, "HTTP port")
 	flag.StringVar(&DefaultFolder, "default-folder", "", "Default folder to show in case of empty URL path")
 	flag.DurationVar(&CacheDuration, "cache-duration", time.Hour, "Cache duration")
 	flag.DurationVar(&CookieExpiration, "cookie-expiration", time.Hour*24*7, "Cookie expiration for read and write access (1 week by default)")
 	flag.DurationVar(&ThumbnailRetryAfter, "thumb-retry-after", time.Hour, "Duration to wait before attempting to create thumbnail again after fail")
-	flag.IntVar(&AuthsPerMin, "auths-per-min", 3, "Max auth attempts/minute/IP (only works with Redis)")
+	flag.IntVar(&AuthsPerMin, "auths-per-min", 3, "Max auth attempts/minute/IP (shared between instances with Redis)")
+	flag.DurationVar(&LockTimeout, "lock-timeout", time.Second*30, "Max duration to wait for a folder that is being modified (0 = until the request is canceled)")
+	flag.DurationVar(&TrashReaperInterval, "trash-reaper-interval", time.Hour, "Interval of purging expired files from trash")
+	flag.BoolVar(&WatchRoot, "watch", false, "Watch the root directory for changes made outside of razbox (local roots on Linux only)")
+	flag.StringVar(&AdminPassword, "admin-pw", "", "Password of the admin console at /admin/ (disabled if empty)")
+	flag.StringVar(&AdminKeyFile, "admin-key-file", "", "File that contains the password of the admin console (instead of -admin-pw)")
 	flag.Parse()
 }
 
@@ -43,10 +55,32 @@ func main() {
 	api.CookieExpiration = CookieExpiration
 	api.ThumbnailRetryAfter = ThumbnailRetryAfter
 	api.AuthsPerMin = AuthsPerMin
+	api.LockTimeout = LockTimeout
+
+	if len(AdminKeyFile) > 0 {
+		key, err := ioutil.ReadFile(AdminKeyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		AdminPassword = strings.TrimSpace(string(key))
+		if len(AdminPassword) == 0 {
+			log.Fatal("empty admin key file: ", AdminKeyFile)
+		}
+	}
+	if err := api.SetAdminPassword(AdminPassword); err != nil {
+		log.Fatal(err)
+	}
 
 	db, err := api.ConnectDB(RedisConnStr)
 	if err != nil {
-		log.Print("failed to connect to database:", err)
+		log.Print("failed to connect to database (using in-process cache):", err)
+	}
+
+	go api.RunTrashReaper(TrashReaperInterval)
+	if WatchRoot {
+		go func() {
+			log.Print("failed to watch root directory:", api.WatchStorage())
+		}()
 	}
 
 	srv := NewServer(api, DefaultFolder, db)
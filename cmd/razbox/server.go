@@ -9,6 +9,7 @@ import (
 
 	"github.com/razzie/beepboop"
 	"github.com/razzie/razbox"
+	"github.com/razzie/razbox/web/apiv1"
 	"github.com/razzie/razbox/web/page"
 )
 
@@ -16,7 +17,8 @@ var version string
 
 // Server ...
 type Server struct {
-	srv *beepboop.Server
+	srv   *beepboop.Server
+	apiv1 http.Handler
 }
 
 // NewServer returns a new Server
@@ -29,6 +31,7 @@ func NewServer(api *razbox.API, defaultFolder string, db *beepboop.DB) *Server {
 		page.Folder(api),
 		page.ReadAuth(api),
 		page.WriteAuth(api),
+		page.UploadAuth(api),
 		page.Upload(api),
 		page.Download(api),
 		page.Edit(api),
@@ -40,6 +43,16 @@ func NewServer(api *razbox.API, defaultFolder string, db *beepboop.DB) *Server {
 		page.Archive(api),
 		page.CreateSubfolder(api),
 		page.DeleteSubfolder(api),
+		page.EditSubfolder(api),
+		page.APITokens(api),
+		page.Members(api),
+		page.Settings(api),
+		page.Shares(api),
+		page.Share(api),
+		page.Trash(api),
+		page.Audit(api),
+		page.Admin(api),
+		page.AdminFolder(api),
 	)
 	srv.DB = db
 	srv.Logger = log.New(os.Stdout, "", log.Lshortfile|log.LstdFlags)
@@ -48,14 +61,20 @@ func NewServer(api *razbox.API, defaultFolder string, db *beepboop.DB) *Server {
 	} else {
 		srv.Header.Set("Server", "razbox")
 	}
-	return &Server{srv: srv}
+	return &Server{
+		srv:   srv,
+		apiv1: apiv1.Handler(api),
+	}
 }
 
 // Serve listens on the given port to serve http requests
 func (s *Server) Serve(port int) error {
+	mux := http.NewServeMux()
+	mux.Handle(apiv1.Prefix, s.apiv1)
+	mux.Handle("/", s.srv)
 	srv := &http.Server{
 		Addr:    fmt.Sprintf(":%d", port),
-		Handler: s.srv,
+		Handler: mux,
 	}
 	return srv.ListenAndServe()
 }
@@ -1,10 +1,38 @@
 package razbox
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 )
 
+// Error is implemented by razbox errors (including the internal ones)
+// to tell a user error from a server fault
+type Error interface {
+	error
+	Code() string
+	HTTPStatus() int
+}
+
+// ClassifyError returns the HTTP status code and the stable error code of an error
+func ClassifyError(err error) (statusCode int, code string) {
+	var e Error
+	if errors.As(err, &e) {
+		return e.HTTPStatus(), e.Code()
+	}
+	return http.StatusInternalServerError, "internal_error"
+}
+
+// GetRetryAfter returns the duration the client should wait before retrying the request
+func GetRetryAfter(err error) (time.Duration, bool) {
+	var e interface{ RetryAfter() time.Duration }
+	if errors.As(err, &e) {
+		return e.RetryAfter(), true
+	}
+	return 0, false
+}
+
 // ErrNotFound ...
 type ErrNotFound struct{}
 
@@ -12,6 +40,14 @@ func (err ErrNotFound) Error() string {
 	return "Not found"
 }
 
+func (err ErrNotFound) Code() string {
+	return "not_found"
+}
+
+func (err ErrNotFound) HTTPStatus() int {
+	return http.StatusNotFound
+}
+
 // ErrNoReadAccess ...
 type ErrNoReadAccess struct {
 	Folder string
@@ -21,6 +57,14 @@ func (err ErrNoReadAccess) Error() string {
 	return err.Folder + ": no read access"
 }
 
+func (err ErrNoReadAccess) Code() string {
+	return "no_read_access"
+}
+
+func (err ErrNoReadAccess) HTTPStatus() int {
+	return http.StatusForbidden
+}
+
 // ErrNoWriteAccess ...
 type ErrNoWriteAccess struct {
 	Folder string
@@ -30,6 +74,31 @@ func (err ErrNoWriteAccess) Error() string {
 	return err.Folder + ": no write access"
 }
 
+func (err ErrNoWriteAccess) Code() string {
+	return "no_write_access"
+}
+
+func (err ErrNoWriteAccess) HTTPStatus() int {
+	return http.StatusForbidden
+}
+
+// ErrNoUploadAccess ...
+type ErrNoUploadAccess struct {
+	Folder string
+}
+
+func (err ErrNoUploadAccess) Error() string {
+	return err.Folder + ": no upload access"
+}
+
+func (err ErrNoUploadAccess) Code() string {
+	return "no_upload_access"
+}
+
+func (err ErrNoUploadAccess) HTTPStatus() int {
+	return http.StatusForbidden
+}
+
 // ErrWrongPassword ...
 type ErrWrongPassword struct{}
 
@@ -37,6 +106,14 @@ func (err ErrWrongPassword) Error() string {
 	return "Wrong password"
 }
 
+func (err ErrWrongPassword) Code() string {
+	return "wrong_password"
+}
+
+func (err ErrWrongPassword) HTTPStatus() int {
+	return http.StatusUnauthorized
+}
+
 // ErrSizeLimitExceeded ...
 type ErrSizeLimitExceeded struct{}
 
@@ -44,6 +121,14 @@ func (err ErrSizeLimitExceeded) Error() string {
 	return "Size limit exceeded"
 }
 
+func (err ErrSizeLimitExceeded) Code() string {
+	return "size_limit_exceeded"
+}
+
+func (err ErrSizeLimitExceeded) HTTPStatus() int {
+	return http.StatusRequestEntityTooLarge
+}
+
 // ErrUnsupportedFileFormat ...
 type ErrUnsupportedFileFormat struct {
 	MIME string
@@ -53,6 +138,14 @@ func (err ErrUnsupportedFileFormat) Error() string {
 	return "Unsupported file format: " + err.MIME
 }
 
+func (err ErrUnsupportedFileFormat) Code() string {
+	return "unsupported_file_format"
+}
+
+func (err ErrUnsupportedFileFormat) HTTPStatus() int {
+	return http.StatusUnsupportedMediaType
+}
+
 // ErrBadHTTPResponseStatus ...
 type ErrBadHTTPResponseStatus struct {
 	StatusCode int
@@ -62,6 +155,14 @@ func (err ErrBadHTTPResponseStatus) Error() string {
 	return "bad response status code: " + http.StatusText(err.StatusCode)
 }
 
+func (err ErrBadHTTPResponseStatus) Code() string {
+	return "bad_http_response_status"
+}
+
+func (err ErrBadHTTPResponseStatus) HTTPStatus() int {
+	return http.StatusBadGateway
+}
+
 // ErrInvalidName ...
 type ErrInvalidName struct {
 	Name string
@@ -71,6 +172,14 @@ func (err ErrInvalidName) Error() string {
 	return "Invalid name: " + err.Name
 }
 
+func (err ErrInvalidName) Code() string {
+	return "invalid_name"
+}
+
+func (err ErrInvalidName) HTTPStatus() int {
+	return http.StatusBadRequest
+}
+
 // ErrInvalidMoveLocation ...
 type ErrInvalidMoveLocation struct {
 	Location string
@@ -80,6 +189,14 @@ func (err ErrInvalidMoveLocation) Error() string {
 	return "Invalid move location: " + err.Location
 }
 
+func (err ErrInvalidMoveLocation) Code() string {
+	return "invalid_move_location"
+}
+
+func (err ErrInvalidMoveLocation) HTTPStatus() int {
+	return http.StatusBadRequest
+}
+
 // ErrNotDeletable ...
 type ErrNotDeletable struct {
 	Name string
@@ -89,9 +206,18 @@ func (err ErrNotDeletable) Error() string {
 	return "Not deletable: " + err.Name
 }
 
+func (err ErrNotDeletable) Code() string {
+	return "not_deletable"
+}
+
+func (err ErrNotDeletable) HTTPStatus() int {
+	return http.StatusConflict
+}
+
 // ErrRateLimitExceeded ...
 type ErrRateLimitExceeded struct {
 	ReqPerMin int
+	Wait      time.Duration // time until the next request is permitted (0 = unknown)
 }
 
 func (err ErrRateLimitExceeded) Error() string {
@@ -102,6 +228,23 @@ func (err ErrRateLimitExceeded) Error() string {
 	return msg
 }
 
+func (err ErrRateLimitExceeded) Code() string {
+	return "rate_limit_exceeded"
+}
+
+func (err ErrRateLimitExceeded) HTTPStatus() int {
+	return http.StatusTooManyRequests
+}
+
+// RetryAfter returns the time until the next request is permitted (in whole seconds)
+// or a minute if it's unknown (which is when the rate limit resets)
+func (err ErrRateLimitExceeded) RetryAfter() time.Duration {
+	if err.Wait <= 0 {
+		return time.Minute
+	}
+	return (err.Wait + time.Second - 1).Truncate(time.Second)
+}
+
 // ErrNoFiles ...
 type ErrNoFiles struct{}
 
@@ -109,6 +252,14 @@ func (err ErrNoFiles) Error() string {
 	return "No files"
 }
 
+func (err ErrNoFiles) Code() string {
+	return "no_files"
+}
+
+func (err ErrNoFiles) HTTPStatus() int {
+	return http.StatusBadRequest
+}
+
 // ErrSubfoldersDisabled ...
 type ErrSubfoldersDisabled struct {
 	Folder string
@@ -118,9 +269,166 @@ func (err ErrSubfoldersDisabled) Error() string {
 	return "Subfolders are disabled for this folder: " + err.Folder
 }
 
+func (err ErrSubfoldersDisabled) Code() string {
+	return "subfolders_disabled"
+}
+
+func (err ErrSubfoldersDisabled) HTTPStatus() int {
+	return http.StatusForbidden
+}
+
 // ErrFolderBusy ...
 type ErrFolderBusy struct{}
 
 func (err ErrFolderBusy) Error() string {
 	return "Folder is busy"
 }
+
+func (err ErrFolderBusy) Code() string {
+	return "folder_busy"
+}
+
+func (err ErrFolderBusy) HTTPStatus() int {
+	return http.StatusConflict
+}
+
+// ErrFolderLeaseLost ...
+type ErrFolderLeaseLost struct{}
+
+func (err ErrFolderLeaseLost) Error() string {
+	return "Lost the lease of the folder"
+}
+
+func (err ErrFolderLeaseLost) Code() string {
+	return "folder_lease_lost"
+}
+
+func (err ErrFolderLeaseLost) HTTPStatus() int {
+	return http.StatusConflict
+}
+
+// ErrInvalidAPIToken ...
+type ErrInvalidAPIToken struct{}
+
+func (err ErrInvalidAPIToken) Error() string {
+	return "Invalid API token"
+}
+
+func (err ErrInvalidAPIToken) Code() string {
+	return "invalid_api_token"
+}
+
+func (err ErrInvalidAPIToken) HTTPStatus() int {
+	return http.StatusUnauthorized
+}
+
+// ErrInvalidUploadLength ...
+type ErrInvalidUploadLength struct{}
+
+func (err ErrInvalidUploadLength) Error() string {
+	return "Invalid upload length"
+}
+
+func (err ErrInvalidUploadLength) Code() string {
+	return "invalid_upload_length"
+}
+
+func (err ErrInvalidUploadLength) HTTPStatus() int {
+	return http.StatusBadRequest
+}
+
+// ErrUploadOffsetMismatch ...
+type ErrUploadOffsetMismatch struct {
+	Offset int64
+}
+
+func (err ErrUploadOffsetMismatch) Error() string {
+	return fmt.Sprintf("Upload offset mismatch (current offset: %d)", err.Offset)
+}
+
+func (err ErrUploadOffsetMismatch) Code() string {
+	return "upload_offset_mismatch"
+}
+
+func (err ErrUploadOffsetMismatch) HTTPStatus() int {
+	return http.StatusConflict
+}
+
+// ErrUploadBusy ...
+type ErrUploadBusy struct{}
+
+func (err ErrUploadBusy) Error() string {
+	return "Upload is busy"
+}
+
+func (err ErrUploadBusy) Code() string {
+	return "upload_busy"
+}
+
+func (err ErrUploadBusy) HTTPStatus() int {
+	return http.StatusLocked
+}
+
+// ErrInvalidContentType ...
+type ErrInvalidContentType struct {
+	ContentType string
+}
+
+func (err ErrInvalidContentType) Error() string {
+	return "Invalid content type: " + err.ContentType
+}
+
+func (err ErrInvalidContentType) Code() string {
+	return "invalid_content_type"
+}
+
+func (err ErrInvalidContentType) HTTPStatus() int {
+	return http.StatusUnsupportedMediaType
+}
+
+// ErrNoAdminAccess ...
+type ErrNoAdminAccess struct{}
+
+func (err ErrNoAdminAccess) Error() string {
+	return "No admin access"
+}
+
+func (err ErrNoAdminAccess) Code() string {
+	return "no_admin_access"
+}
+
+func (err ErrNoAdminAccess) HTTPStatus() int {
+	return http.StatusForbidden
+}
+
+// ErrAdminDisabled ...
+type ErrAdminDisabled struct{}
+
+func (err ErrAdminDisabled) Error() string {
+	return "Admin console is disabled"
+}
+
+func (err ErrAdminDisabled) Code() string {
+	return "admin_disabled"
+}
+
+func (err ErrAdminDisabled) HTTPStatus() int {
+	return http.StatusNotFound
+}
+
+// ErrFolderExists ...
+type ErrFolderExists struct {
+	Folder string
+}
+
+func (err ErrFolderExists) Error() string {
+	return err.Folder + ": folder already exists"
+}
+
+func (err ErrFolderExists) Code() string {
+	return "folder_exists"
+}
+
+func (err ErrFolderExists) HTTPStatus() int {
+	return http.StatusConflict
+}
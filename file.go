@@ -1,35 +1,57 @@
 package razbox
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/razzie/beepboop"
 	"github.com/razzie/razbox/internal"
 )
 
 // MaxThumbnailWidth ...
 const MaxThumbnailWidth = internal.MaxThumbnailWidth
 
+// ErrFileTypeNotAllowed is returned when the folder config doesn't permit the MIME type or extension of a file
+type ErrFileTypeNotAllowed = internal.ErrFileTypeNotAllowed
+
 // FileReader ...
 type FileReader interface {
 	http.File
 	os.FileInfo
 	MimeType() string
+	Hash() string
+}
+
+// GetChecksumHeaders returns the ETag and Digest (RFC 3230) headers of a file content hash
+// (or nothing if the hash of the file is unknown)
+func GetChecksumHeaders(hash string) http.Header {
+	header := make(http.Header)
+	sum, err := hex.DecodeString(hash)
+	if err != nil || len(sum) != sha256.Size {
+		return header
+	}
+	header.Set("ETag", strconv.Quote(hash))
+	header.Set("Digest", "sha-256="+base64.StdEncoding.EncodeToString(sum))
+	return header
 }
 
 // OpenFile ...
-func (api *API) OpenFile(sess *beepboop.Session, filePath string) (FileReader, error) {
+func (api *API) OpenFile(sess Session, filePath string) (FileReader, error) {
 	filePath = path.Clean(filePath)
 	dir := path.Dir(filePath)
-	folder, unlock, cached, err := api.getFolder(dir)
+	folder, unlock, cached, err := api.getFolder(sess, dir)
 	if err != nil {
 		return nil, err
 	}
@@ -39,6 +61,14 @@ func (api *API) OpenFile(sess *beepboop.Session, filePath string) (FileReader, e
 	defer unlock()
 
 	hasViewAccess := folder.EnsureReadAccess(sess) == nil
+	var share *shareAccess
+	if !hasViewAccess {
+		share, err = api.checkShareAccess(sess, folder, filePath)
+		if err != nil {
+			return nil, err
+		}
+		hasViewAccess = share != nil
+	}
 
 	basename := filepath.Base(filePath)
 	file, err := folder.GetFile(basename)
@@ -53,16 +83,20 @@ func (api *API) OpenFile(sess *beepboop.Session, filePath string) (FileReader, e
 		return nil, &ErrNoReadAccess{Folder: dir}
 	}
 
+	if share != nil {
+		return share.openDownload(sess, file)
+	}
 	return file.Open()
 }
 
-// GetInternalFilename ...
-func (api *API) GetInternalFilename(sess *beepboop.Session, filePath string) (string, error) {
+// GetLocalFilename returns the name of a local file with the content of the file
+// (and a cleanup function that must be called when the local file is not needed anymore)
+func (api *API) GetLocalFilename(sess Session, filePath string) (string, func(), error) {
 	filePath = path.Clean(filePath)
 	dir := path.Dir(filePath)
-	folder, unlock, cached, err := api.getFolder(dir)
+	folder, unlock, cached, err := api.getFolder(sess, dir)
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 	if !cached {
 		defer api.goCacheFolder(folder)
@@ -75,54 +109,77 @@ func (api *API) GetInternalFilename(sess *beepboop.Session, filePath string) (st
 	file, err := folder.GetFile(basename)
 	if err != nil {
 		if !hasViewAccess {
-			return "", &ErrNoReadAccess{Folder: dir}
+			return "", nil, &ErrNoReadAccess{Folder: dir}
 		}
-		return "", &ErrNotFound{}
+		return "", nil, &ErrNotFound{}
 	}
 
 	if !hasViewAccess && !file.Public {
-		return "", &ErrNoReadAccess{Folder: dir}
+		return "", nil, &ErrNoReadAccess{Folder: dir}
 	}
 
-	return file.GetInternalFilename(), nil
+	return file.GetLocalFilename()
 }
 
 // UploadFileOptions ...
 type UploadFileOptions struct {
-	Folder    string
-	Files     []*multipart.FileHeader
-	Filename  string
-	Tags      []string
-	Overwrite bool
-	Public    bool
+	Folder      string
+	Content     io.Reader // multipart/form-data stream
+	ContentType string    // Content-Type header (including the multipart boundary)
+	Filename    string
+	Tags        []string
+	Overwrite   bool
+	Public      bool
 }
 
-// UploadFile ...
-func (api *API) UploadFile(sess *beepboop.Session, o *UploadFileOptions) error {
-	changed := false
-	folder, unlock, cached, err := api.getFolder(o.Folder)
+// setField sets an option from a form field of the multipart stream
+func (o *UploadFileOptions) setField(part *multipart.Part) error {
+	value, err := ioutil.ReadAll(io.LimitReader(part, 1<<16))
 	if err != nil {
 		return err
 	}
-	defer func() {
-		if !cached || changed {
-			api.goCacheFolder(folder)
-		}
-	}()
-	defer unlock()
 
-	err = folder.EnsureReadAccess(sess)
-	if err != nil {
-		return &ErrNoReadAccess{Folder: o.Folder}
+	switch part.FormName() {
+	case "filename":
+		o.Filename = string(value)
+	case "tags":
+		o.Tags = strings.Fields(string(value))
+	case "overwrite":
+		o.Overwrite = string(value) == "overwrite"
+	case "public":
+		o.Public = string(value) == "public"
 	}
+	return nil
+}
 
-	err = folder.EnsureWriteAccess(sess)
-	if err != nil {
-		return &ErrNoWriteAccess{Folder: o.Folder}
+// stagedUpload is a received file that is added to the folder after the whole upload is received
+type stagedUpload struct {
+	*internal.StagedContent
+	filename  string
+	tags      []string
+	mime      string
+	overwrite bool
+	public    bool
+}
+
+// UploadFile receives the files of a multipart/form-data content and adds them to the folder.
+// Form fields (filename, tags, overwrite, public) override the given options,
+// but only for the files that come after them in the stream.
+// The folder isn't locked while the files are being received, so they are checked again before they are added.
+func (api *API) UploadFile(sess Session, o *UploadFileOptions) error {
+	mediaType, params, _ := mime.ParseMediaType(o.ContentType)
+	if mediaType != "multipart/form-data" || len(params["boundary"]) == 0 {
+		return &ErrInvalidContentType{ContentType: o.ContentType}
 	}
 
-	if len(o.Files) == 0 {
-		return &ErrNoFiles{}
+	folder, unlock, _, err := api.getFolder(sess, o.Folder)
+	if err != nil {
+		return err
+	}
+	_, err = ensureUploadAccess(sess, folder, o.Folder)
+	unlock()
+	if err != nil {
+		return err
 	}
 
 	nthFilename := func(n int) string {
@@ -133,41 +190,115 @@ func (api *API) UploadFile(sess *beepboop.Session, o *UploadFileOptions) error {
 		return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(o.Filename, ext), n+1, ext)
 	}
 
+	var uploads []*stagedUpload
+	defer func() {
+		for _, u := range uploads {
+			u.Close()
+		}
+	}()
+
 	limit := folder.GetMaxUploadSizeMB() << 20
-	for i, header := range o.Files {
-		if header.Size > limit {
-			return &ErrSizeLimitExceeded{}
+	parts := multipart.NewReader(o.Content, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
 		}
 
-		limit -= header.Size
-		filename, _ := getSafeFilename(nthFilename(i), header.Filename, internal.Salt())
+		if part.FormName() != "files" {
+			if err := o.setField(part); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// empty file input
+		if len(part.FileName()) == 0 {
+			continue
+		}
 
-		data, err := header.Open()
+		filename, _ := getSafeFilename(nthFilename(len(uploads)), part.FileName(), internal.Salt())
+		mime, data, err := internal.SniffContentType(&LimitedReader{R: part, N: limit})
 		if err != nil {
 			return err
 		}
-		defer data.Close()
+		if err := folder.CheckFileType(filename, mime); err != nil {
+			return err
+		}
+
+		content, err := internal.StageContent(api.root, o.Folder, data)
+		if err != nil {
+			return err
+		}
+		uploads = append(uploads, &stagedUpload{
+			StagedContent: content,
+			filename:      filename,
+			tags:          o.Tags,
+			mime:          mime,
+			overwrite:     o.Overwrite,
+			public:        o.Public,
+		})
+		limit -= content.Size()
+	}
+
+	if len(uploads) == 0 {
+		return &ErrNoFiles{}
+	}
+
+	changed := false
+	folder, unlock, cached, err := api.getFolderForWrite(sess, o.Folder)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if !cached || changed {
+			api.goCacheFolder(folder)
+		}
+	}()
+	defer unlock()
+
+	// the folder could have changed while the files were being received
+	uploadOnly, err := ensureUploadAccess(sess, folder, o.Folder)
+	if err != nil {
+		return err
+	}
+
+	limit = folder.GetMaxUploadSizeMB() << 20
+	for _, u := range uploads {
+		if u.Size() > limit {
+			return &ErrSizeLimitExceeded{}
+		}
+		if err := folder.CheckFileType(u.filename, u.mime); err != nil {
+			return err
+		}
 
-		mime, _ := internal.DetectContentType(data)
-		data.Seek(0, io.SeekStart)
+		if uploadOnly {
+			// upload-only sessions can't replace or publish files
+			u.overwrite, u.public = false, false
+		}
 
 		file := &internal.File{
-			Name:     filename,
+			Name:     u.filename,
 			Root:     api.root,
-			RelPath:  path.Join(o.Folder, internal.FilenameToUUID(filename)),
-			Tags:     o.Tags,
-			MIME:     mime,
-			Size:     header.Size,
+			RelPath:  path.Join(o.Folder, internal.FilenameToUUID(u.filename)),
+			Tags:     u.tags,
+			MIME:     u.mime,
 			Uploaded: time.Now(),
-			Public:   o.Public,
+			Uploader: getUploader(sess, folder),
+			Public:   u.public,
 		}
-		err = file.Create(data, o.Overwrite)
+		err = folder.CreateFile(file, u.StagedContent, u.overwrite)
 		if err != nil {
 			return err
 		}
 
-		folder.CacheFile(file)
+		api.cacheFile(folder, file)
+		limit -= file.Size
 		changed = true
+		api.audit(sess, folder, internal.AuditUpload, path.Join(o.Folder, file.Name), fmt.Sprintf("%d bytes", file.Size))
 	}
 
 	return nil
@@ -184,27 +315,15 @@ type DownloadFileToFolderOptions struct {
 }
 
 // DownloadFileToFolder ...
-func (api *API) DownloadFileToFolder(sess *beepboop.Session, o *DownloadFileToFolderOptions) error {
-	changed := false
-	folder, unlock, cached, err := api.getFolder(o.Folder)
+func (api *API) DownloadFileToFolder(sess Session, o *DownloadFileToFolderOptions) error {
+	folder, unlock, _, err := api.getFolder(sess, o.Folder)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		if !cached || changed {
-			api.goCacheFolder(folder)
-		}
-	}()
-	defer unlock()
-
-	err = folder.EnsureReadAccess(sess)
-	if err != nil {
-		return &ErrNoReadAccess{Folder: o.Folder}
-	}
-
-	err = folder.EnsureWriteAccess(sess)
+	_, err = ensureUploadAccess(sess, folder, o.Folder)
+	unlock()
 	if err != nil {
-		return &ErrNoWriteAccess{Folder: o.Folder}
+		return err
 	}
 
 	req, err := http.NewRequest("GET", o.URL, nil)
@@ -221,10 +340,9 @@ func (api *API) DownloadFileToFolder(sess *beepboop.Session, o *DownloadFileToFo
 		return &ErrBadHTTPResponseStatus{StatusCode: resp.StatusCode}
 	}
 
-	limit := folder.GetMaxUploadSizeMB() << 20
 	data := &LimitedReader{
 		R: resp.Body,
-		N: limit,
+		N: folder.GetMaxUploadSizeMB() << 20,
 	}
 
 	filename, _ := getSafeFilename(
@@ -233,21 +351,67 @@ func (api *API) DownloadFileToFolder(sess *beepboop.Session, o *DownloadFileToFo
 		path.Base(resp.Request.URL.Path),
 		internal.Salt())
 
+	mime, content, err := internal.SniffContentType(data)
+	if err != nil {
+		return err
+	}
+	if err := folder.CheckFileType(filename, mime); err != nil {
+		return err
+	}
+
+	staged, err := internal.StageContent(api.root, o.Folder, content)
+	if err != nil {
+		return err
+	}
+	defer staged.Close()
+
+	changed := false
+	folder, unlock, cached, err := api.getFolderForWrite(sess, o.Folder)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if !cached || changed {
+			api.goCacheFolder(folder)
+		}
+	}()
+	defer unlock()
+
+	// the folder could have changed during the download
+	uploadOnly, err := ensureUploadAccess(sess, folder, o.Folder)
+	if err != nil {
+		return err
+	}
+	if staged.Size() > folder.GetMaxUploadSizeMB()<<20 {
+		return &ErrSizeLimitExceeded{}
+	}
+	if err := folder.CheckFileType(filename, mime); err != nil {
+		return err
+	}
+
+	if uploadOnly {
+		// upload-only sessions can't replace or publish files
+		o.Overwrite, o.Public = false, false
+	}
+
 	file := &internal.File{
 		Name:     filename,
 		Root:     api.root,
 		RelPath:  path.Join(o.Folder, internal.FilenameToUUID(filename)),
 		Tags:     o.Tags,
+		MIME:     mime,
 		Uploaded: time.Now(),
+		Uploader: getUploader(sess, folder),
 		Public:   o.Public,
 	}
-	err = file.Create(data, o.Overwrite)
+	err = folder.CreateFile(file, staged, o.Overwrite)
 	if err != nil {
 		return err
 	}
 
-	folder.CacheFile(file)
+	api.cacheFile(folder, file)
 	changed = true
+	api.audit(sess, folder, internal.AuditUpload, path.Join(o.Folder, file.Name), "downloaded from "+o.URL)
 	return nil
 }
 
@@ -262,9 +426,9 @@ type EditFileOptions struct {
 }
 
 // EditFile ...
-func (api *API) EditFile(sess *beepboop.Session, o *EditFileOptions) error {
+func (api *API) EditFile(sess Session, o *EditFileOptions) error {
 	changed := false
-	folder, unlock, cached, err := api.getFolder(o.Folder)
+	folder, unlock, cached, err := api.getFolderForWrite(sess, o.Folder)
 	if err != nil {
 		return err
 	}
@@ -292,8 +456,15 @@ func (api *API) EditFile(sess *beepboop.Session, o *EditFileOptions) error {
 
 	oldTags := strings.Join(file.Tags, " ")
 	newTags := strings.Join(o.Tags, " ")
+	var edits []string
 
 	if newTags != oldTags || o.Public != file.Public {
+		if newTags != oldTags {
+			edits = append(edits, fmt.Sprintf("tags: %q", newTags))
+		}
+		if o.Public != file.Public {
+			edits = append(edits, fmt.Sprintf("public: %t", o.Public))
+		}
 		file.Tags = o.Tags
 		file.Public = o.Public
 		err := file.Save()
@@ -309,6 +480,11 @@ func (api *API) EditFile(sess *beepboop.Session, o *EditFileOptions) error {
 		if err != nil {
 			return err
 		}
+		if newName != file.Name {
+			if err := folder.CheckFileType(newName, file.MIME); err != nil {
+				return err
+			}
+		}
 	}
 
 	newFolderName := o.Folder
@@ -329,30 +505,35 @@ func (api *API) EditFile(sess *beepboop.Session, o *EditFileOptions) error {
 
 	if newName != o.OriginalFilename || len(o.MoveTo) > 0 {
 		newPath := path.Join(newFolderName, newName)
+		api.uncacheFile(folder, o.OriginalFilename)
 		err := file.Move(newPath)
 		if err != nil {
+			api.cacheFile(folder, file)
 			return err
 		}
-		if newFolderName != o.Folder {
-			folder.UncacheFile(o.OriginalFilename)
-			newFolder, _, _ := api.getFolderNoLock(newFolderName)
-			if newFolder != nil {
-				newFolder.CacheFile(file)
-				api.goCacheFolder(newFolder)
-			}
+		if newFolderName == o.Folder {
+			api.cacheFile(folder, file)
+		} else if api.cache != nil {
+			api.cache.CacheFile(newFolderName, file)
 		}
 		changed = true
+		edits = append(edits, "moved to "+newPath)
+	} else if changed {
+		api.cacheFile(folder, file)
 	}
 
+	if len(edits) > 0 {
+		api.audit(sess, folder, internal.AuditEdit, path.Join(o.Folder, o.OriginalFilename), strings.Join(edits, ", "))
+	}
 	return nil
 }
 
-// DeleteFile ...
-func (api *API) DeleteFile(sess *beepboop.Session, filePath string) error {
+// DeleteFile moves a file to the trash
+func (api *API) DeleteFile(sess Session, filePath string) error {
 	filePath = path.Clean(filePath)
 	dir := path.Dir(filePath)
 	changed := false
-	folder, unlock, cached, err := api.getFolder(dir)
+	folder, unlock, cached, err := api.getFolderForWrite(sess, dir)
 	if err != nil {
 		return err
 	}
@@ -379,10 +560,11 @@ func (api *API) DeleteFile(sess *beepboop.Session, filePath string) error {
 		return &ErrNotFound{}
 	}
 
-	err = file.Delete()
+	err = folder.TrashFile(file)
 	if err == nil {
-		folder.UncacheFile(file.Name)
+		api.uncacheFile(folder, file.Name)
 		changed = true
+		api.audit(sess, folder, internal.AuditDelete, path.Join(dir, file.Name), "")
 	}
 	return err
 }
@@ -412,7 +594,7 @@ func newThumbnail(thumb *internal.Thumbnail) *Thumbnail {
 }
 
 // GetFileThumbnail ...
-func (api *API) GetFileThumbnail(sess *beepboop.Session, filePath string) (*Thumbnail, error) {
+func (api *API) GetFileThumbnail(sess Session, filePath string) (*Thumbnail, error) {
 	filePath = path.Clean(filePath)
 	dir := path.Dir(filePath)
 	folder, _, err := api.getFolderNoLock(dir)
@@ -422,7 +604,13 @@ func (api *API) GetFileThumbnail(sess *beepboop.Session, filePath string) (*Thum
 
 	err = folder.EnsureReadAccess(sess)
 	if err != nil {
-		return nil, &ErrNoReadAccess{Folder: dir}
+		share, err := api.checkShareAccess(sess, folder, filePath)
+		if err != nil {
+			return nil, err
+		}
+		if share == nil {
+			return nil, &ErrNoReadAccess{Folder: dir}
+		}
 	}
 
 	basename := filepath.Base(filePath)
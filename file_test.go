@@ -0,0 +1,65 @@
+package razbox
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/razzie/razbox/internal"
+)
+
+func readTestFile(t *testing.T, file *internal.File) string {
+	t.Helper()
+	r, err := file.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestDownloadFileToFolderVersions(t *testing.T) {
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		fmt.Fprintf(w, "content %d", downloads)
+	}))
+	defer server.Close()
+
+	for _, dedup := range []bool{false, true} {
+		t.Run(fmt.Sprintf("dedup=%t", dedup), func(t *testing.T) {
+			api := newTestAPI(t, func(c *internal.FolderConfig) { c.Dedup = dedup })
+			sess := newTestSession(t, api, "read", "write")
+			downloads = 0
+
+			for i := 0; i < 2; i++ {
+				err := api.DownloadFileToFolder(sess, &DownloadFileToFolderOptions{
+					Folder:    "f",
+					URL:       server.URL + "/d.txt",
+					Overwrite: true,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			file := getTestFile(t, api, "d.txt")
+			if content := readTestFile(t, file); content != "content 2" {
+				t.Errorf("content = %q, want %q", content, "content 2")
+			}
+			version, err := file.GetVersion(1)
+			if err != nil {
+				t.Fatalf("the overwritten content isn't kept as a version: %v", err)
+			}
+			if content := readTestFile(t, version); content != "content 1" {
+				t.Errorf("version content = %q, want %q", content, "content 1")
+			}
+		})
+	}
+}
@@ -1,36 +1,52 @@
 package razbox
 
 import (
-	"io/ioutil"
+	"context"
+	"fmt"
+	"log"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
+	"sync"
 
-	"github.com/razzie/beepboop"
 	"github.com/razzie/razbox/internal"
 )
 
 // FolderFlags ...
 type FolderFlags struct {
-	EditMode        bool
-	Editable        bool
-	Deletable       bool
-	Configurable    bool
-	Subfolders      bool
-	MaxUploadSizeMB int64
+	EditMode        bool  `json:"edit_mode"`
+	Editable        bool  `json:"editable"`
+	AdminMode       bool  `json:"admin_mode"`
+	UploadMode      bool  `json:"upload_mode"`
+	Uploadable      bool  `json:"uploadable"`
+	Deletable       bool  `json:"deletable"`
+	Configurable    bool  `json:"configurable"`
+	Subfolders      bool  `json:"subfolders"`
+	MaxUploadSizeMB int64 `json:"max_upload_size_mb"`
 }
 
-func getFolderFlags(sess *beepboop.Session, f *internal.Folder) *FolderFlags {
+func getFolderFlags(sess Session, f *internal.Folder) *FolderFlags {
 	gotWriteAccess := f.EnsureWriteAccess(sess) == nil
 	deletable := false
 	if gotWriteAccess && f.ConfigInherited {
-		entries, err := ioutil.ReadDir(path.Join(f.Root, f.RelPath))
-		deletable = (err == nil) && len(entries) == 0
+		entries, err := f.GetStorage().List(f.RelPath)
+		deletable = err == nil
+		for _, entry := range entries {
+			// the audit log is removed together with the folder
+			if entry.Name() != internal.AuditLogName {
+				deletable = false
+				break
+			}
+		}
 	}
 
 	return &FolderFlags{
 		EditMode:        gotWriteAccess,
-		Editable:        len(f.Config.WritePassword) > 0,
+		Editable:        len(f.Config.WritePassword) > 0 || f.HasMembers("write") || f.Config.ParentManaged,
+		AdminMode:       f.EnsureAdminAccess(sess) == nil,
+		UploadMode:      gotWriteAccess || f.EnsureUploadAccess(sess) == nil,
+		Uploadable:      len(f.Config.UploadPassword) > 0 || f.HasMembers("upload"),
 		Deletable:       deletable,
 		Configurable:    !f.ConfigInherited,
 		Subfolders:      f.Config.Subfolders,
@@ -38,50 +54,198 @@ func getFolderFlags(sess *beepboop.Session, f *internal.Folder) *FolderFlags {
 	}
 }
 
-func (api *API) lockFolder(folder *internal.Folder) (unlock func(), err error) {
-	if _, locked := api.folderLock.LoadOrStore(folder.ConfigRootFolder, nil); locked {
-		return nil, &ErrFolderBusy{}
+func (api *API) withLockTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if api.LockTimeout > 0 {
+		return context.WithTimeout(ctx, api.LockTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// leaseFolder gets the lease of a config root folder, which excludes writers of other instances.
+// The returned context is canceled if the lease is lost before it's released.
+// Until then, the storage rejects the writes to the folder once the lease is lost,
+// and the file metadata and folder configs are only written if the fencing token is still the lease's.
+// Releasing the lease returns the new fencing token of the folder.
+func (api *API) leaseFolder(ctx context.Context, configRoot string) (leaseCtx context.Context, release func() (fence uint64), err error) {
+	if api.redisLocks == nil {
+		return ctx, func() uint64 { return 0 }, nil
+	}
+
+	// the timeout only limits waiting for the lease, the lease itself lasts until it's released
+	leaseCtx, token, releaseLease, err := api.redisLocks.lock(ctx, configRoot, api.LockTimeout)
+	if err != nil {
+		if err == context.DeadlineExceeded || err == context.Canceled {
+			return nil, nil, &ErrFolderBusy{}
+		}
+		// other instances aren't excluded until Redis is available again
+		log.Print("failed to lease folder: ", configRoot, " ", err)
+		return ctx, func() uint64 { return 0 }, nil
 	}
-	return func() {
-		api.folderLock.Delete(folder.ConfigRootFolder)
+
+	unguard := internal.GuardWrites(api.root, configRoot, func(commit bool) error {
+		// the lease context is canceled with ctx too, but that doesn't mean the lease was lost
+		if leaseCtx.Err() != nil && ctx.Err() == nil {
+			return &ErrFolderLeaseLost{}
+		}
+		if commit {
+			return api.redisLocks.checkLease(configRoot, token)
+		}
+		return nil
+	})
+	return leaseCtx, func() uint64 {
+		unguard()
+		return releaseLease()
 	}, nil
 }
 
-func (api *API) getFolder(folderName string) (folder *internal.Folder, unlock func(), cached bool, err error) {
+// lockFolder locks the config root of a folder for reading (shared) or writing (exclusive),
+// and write locks also get the lease of the folder.
+// It waits until the context is done or the lock timeout expires.
+// The returned context of a write lock is canceled if the lease is lost.
+// Unlocking a write lock returns the new fencing token of the folder.
+func (api *API) lockFolder(ctx context.Context, folder *internal.Folder, write bool) (lockCtx context.Context, unlock func() (fence uint64), err error) {
+	configRoot := folder.ConfigRootFolder
+	waitCtx, cancel := api.withLockTimeout(ctx)
+	defer cancel()
+	unlockLocal, err := api.folderLocks.lock(waitCtx, configRoot, write)
+	if err != nil {
+		return nil, nil, &ErrFolderBusy{}
+	}
+	if !write {
+		return ctx, func() uint64 {
+			unlockLocal()
+			return 0
+		}, nil
+	}
+
+	lockCtx, release, err := api.leaseFolder(ctx, configRoot)
+	if err != nil {
+		unlockLocal()
+		return nil, nil, err
+	}
+	var once sync.Once
+	var fence uint64
+	return lockCtx, func() uint64 {
+		once.Do(func() {
+			fence = release()
+			unlockLocal()
+		})
+		return fence
+	}, nil
+}
+
+// getFolder returns a folder that is locked for reading
+func (api *API) getFolder(sess Session, folderName string) (folder *internal.Folder, unlock func(), cached bool, err error) {
+	return api.getLockedFolder(sess.Context(), folderName, false)
+}
+
+// getFolderForWrite returns a folder that is locked for writing
+func (api *API) getFolderForWrite(sess Session, folderName string) (folder *internal.Folder, unlock func(), cached bool, err error) {
+	return api.getLockedFolder(sess.Context(), folderName, true)
+}
+
+func (api *API) getLockedFolder(ctx context.Context, folderName string, write bool) (folder *internal.Folder, unlock func(), cached bool, err error) {
+	writes := api.folderLocks.getWriteCount()
 	folder, cached, err = api.getFolderNoLock(folderName)
-	if folder != nil {
-		unlock, err = api.lockFolder(folder)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	_, release, err := api.lockFolder(ctx, folder, write)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	unlock = func() {
+		if write && api.cache != nil {
+			// readers reload the config and subfolders (but not the files) unless the folder is cached again
+			folder.CacheVersion, _ = api.cache.TouchFolder(folder.RelPath)
+		}
+		if fence := release(); write {
+			// the folder is cached with the fencing token of its last change
+			folder.Fence = fence
+		}
+	}
+
+	// the folder could have changed before the lock was acquired
+	// (writers of other instances are only excluded by the lease)
+	if api.folderLocks.getWriteCount() != writes || (write && api.redisLocks != nil) {
+		configRoot := folder.ConfigRootFolder
+		folder, cached, err = api.getFolderNoLock(folderName)
+		if err == nil && folder.ConfigRootFolder != configRoot {
+			err = &ErrFolderBusy{} // got a config of its own in the meantime
+		}
 		if err != nil {
-			folder = nil
+			release()
+			return nil, nil, false, err
 		}
 	}
-	return
+	return folder, unlock, cached, nil
 }
 
 func (api *API) getFolderNoLock(folderName string) (folder *internal.Folder, cached bool, err error) {
-	cached = true
-	if api.db != nil {
-		folder, _ = internal.GetCachedFolder(api.db, folderName)
+	if internal.IsReservedPath(folderName) {
+		return nil, false, &ErrNotFound{}
 	}
-	if folder == nil {
-		cached = false
-		folder, err = internal.GetFolder(api.root, folderName)
-		if err != nil {
-			err = &ErrNotFound{}
+
+	// the fencing tokens are read before the folder, so a change that happens meanwhile makes it stale
+	var fences map[string]uint64
+	if api.redisLocks != nil {
+		fences, _ = api.redisLocks.getFences(folderName)
+	}
+
+	var version uint64
+	if api.cache != nil {
+		folder, version, _ = api.cache.GetCachedFolder(folderName)
+		if folder != nil && folder.CacheVersion == version &&
+			(fences == nil || folder.Fence == fences[folder.ConfigRootFolder]) {
+			return folder, true, nil
 		}
 	}
-	return
+
+	// the files of an outdated cached folder are still up to date, since they are cached one by one
+	cachedFolder := folder
+	folder, err = internal.GetFolder(api.root, folderName)
+	if err != nil {
+		return nil, false, &ErrNotFound{}
+	}
+	if cachedFolder != nil {
+		folder.CachedFiles = cachedFolder.CachedFiles
+	}
+	folder.CacheVersion = version
+	if fences != nil {
+		folder.Fence = fences[folder.ConfigRootFolder]
+	}
+	return folder, false, nil
 }
 
 func (api *API) goCacheFolder(folder *internal.Folder) {
-	if api.db != nil {
-		go internal.CacheFolder(api.db, folder)
+	if api.cache != nil {
+		go api.cache.CacheFolder(folder)
+	}
+}
+
+// cacheFile adds or updates a file both in a folder and in the cache
+func (api *API) cacheFile(folder *internal.Folder, file *internal.File) {
+	folder.CacheFile(file)
+	if api.cache != nil {
+		if err := api.cache.CacheFile(folder.RelPath, file); err != nil {
+			log.Print("failed to cache file: ", file.RelPath, " ", err)
+		}
+	}
+}
+
+// uncacheFile removes a file both from a folder and from the cache
+func (api *API) uncacheFile(folder *internal.Folder, filename string) {
+	folder.UncacheFile(filename)
+	if api.cache != nil {
+		if err := api.cache.UncacheFile(folder.RelPath, filename); err != nil {
+			log.Print("failed to uncache file: ", path.Join(folder.RelPath, filename), " ", err)
+		}
 	}
 }
 
 // GetFolderFlags ...
-func (api *API) GetFolderFlags(sess *beepboop.Session, folderName string) (*FolderFlags, error) {
-	folder, unlock, cached, err := api.getFolder(folderName)
+func (api *API) GetFolderFlags(sess Session, folderName string) (*FolderFlags, error) {
+	folder, unlock, cached, err := api.getFolder(sess, folderName)
 	if err != nil {
 		return nil, err
 	}
@@ -98,10 +262,65 @@ func (api *API) GetFolderFlags(sess *beepboop.Session, folderName string) (*Fold
 	return getFolderFlags(sess, folder), nil
 }
 
+func getUploader(sess Session, folder *internal.Folder) string {
+	if m := folder.GetSessionMember(sess); m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+// ensureUploadAccess returns an error if the session can't upload files to the folder,
+// otherwise it returns whether the session can only upload files (but not list, download or modify them)
+func ensureUploadAccess(sess Session, folder *internal.Folder, folderName string) (uploadOnly bool, err error) {
+	hasReadAccess := folder.EnsureReadAccess(sess) == nil
+	hasWriteAccess := folder.EnsureWriteAccess(sess) == nil
+	if hasReadAccess && hasWriteAccess {
+		return false, nil
+	}
+
+	if folder.EnsureUploadAccess(sess) == nil {
+		return true, nil
+	}
+	if len(folder.Config.UploadPassword) > 0 {
+		return false, &ErrNoUploadAccess{Folder: folderName}
+	}
+	if !hasReadAccess {
+		return false, &ErrNoReadAccess{Folder: folderName}
+	}
+	return false, &ErrNoWriteAccess{Folder: folderName}
+}
+
+// GetUploadFlags returns the flags of a folder for sessions that may only have upload access to it
+// (the flags of upload-only sessions don't reveal more than the upload size limit)
+func (api *API) GetUploadFlags(sess Session, folderName string) (*FolderFlags, error) {
+	folder, unlock, cached, err := api.getFolder(sess, folderName)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		defer api.goCacheFolder(folder)
+	}
+	defer unlock()
+
+	uploadOnly, err := ensureUploadAccess(sess, folder, folderName)
+	if err != nil {
+		return nil, err
+	}
+
+	if uploadOnly {
+		return &FolderFlags{
+			UploadMode:      true,
+			Uploadable:      true,
+			MaxUploadSizeMB: folder.GetMaxUploadSizeMB(),
+		}, nil
+	}
+	return getFolderFlags(sess, folder), nil
+}
+
 // ChangeFolderPassword ...
-func (api *API) ChangeFolderPassword(sess *beepboop.Session, folderName, accessType, password string) error {
+func (api *API) ChangeFolderPassword(sess Session, folderName, accessType, password string) error {
 	changed := false
-	folder, unlock, cached, err := api.getFolder(folderName)
+	folder, unlock, cached, err := api.getFolderForWrite(sess, folderName)
 	if err != nil {
 		return err
 	}
@@ -117,7 +336,7 @@ func (api *API) ChangeFolderPassword(sess *beepboop.Session, folderName, accessT
 		return &ErrNoReadAccess{Folder: folderName}
 	}
 
-	err = folder.EnsureWriteAccess(sess)
+	err = folder.EnsureAdminAccess(sess)
 	if err != nil {
 		return &ErrNoWriteAccess{Folder: folderName}
 	}
@@ -127,6 +346,7 @@ func (api *API) ChangeFolderPassword(sess *beepboop.Session, folderName, accessT
 		return err
 	}
 	changed = true
+	api.audit(sess, folder, internal.AuditChangePassword, folder.RelPath, accessType)
 
 	newToken, err := folder.GetAccessToken(accessType)
 	if err != nil {
@@ -138,7 +358,7 @@ func (api *API) ChangeFolderPassword(sess *beepboop.Session, folderName, accessT
 }
 
 // GetSubfolders ...
-func (api *API) GetSubfolders(sess *beepboop.Session, folderName string) ([]string, error) {
+func (api *API) GetSubfolders(sess Session, folderName string) ([]string, error) {
 	subfolders, err := api.getSubfoldersRecursive(sess, folderName, true, false)
 	if err != nil {
 		return nil, err
@@ -155,7 +375,7 @@ func (api *API) GetSubfolders(sess *beepboop.Session, folderName string) ([]stri
 	return relSubfolders, nil
 }
 
-func (api *API) getSubfoldersRecursive(sess *beepboop.Session, folderName string, fromConfigRoot, inheritedOnly bool) ([]string, error) {
+func (api *API) getSubfoldersRecursive(sess Session, folderName string, fromConfigRoot, inheritedOnly bool) ([]string, error) {
 	folder, cached, err := api.getFolderNoLock(folderName)
 	if err != nil {
 		return nil, err
@@ -193,9 +413,25 @@ func (api *API) getSubfoldersRecursive(sess *beepboop.Session, folderName string
 }
 
 // CreateSubfolder ...
-func (api *API) CreateSubfolder(sess *beepboop.Session, folderName, subfolder string) (string, error) {
+func (api *API) CreateSubfolder(sess Session, folderName, subfolder string) (string, error) {
+	return api.CreateSubfolderWithOptions(sess, folderName, &CreateSubfolderOptions{Name: subfolder})
+}
+
+// CreateSubfolderOptions ...
+type CreateSubfolderOptions struct {
+	Name           string
+	OwnConfig      bool // the subfolder has its own passwords and limits instead of inheriting the config of the folder
+	ReadPassword   string
+	WritePassword  string // optional if the subfolder is managed by the folder
+	UploadPassword string
+	ParentManaged  bool            // write access to the folder permits managing the subfolder
+	Settings       *FolderSettings // nil = the settings of the folder
+}
+
+// CreateSubfolderWithOptions creates a subfolder that either inherits the config of the folder or has its own
+func (api *API) CreateSubfolderWithOptions(sess Session, folderName string, o *CreateSubfolderOptions) (string, error) {
 	changed := false
-	folder, unlock, cached, err := api.getFolder(folderName)
+	folder, unlock, cached, err := api.getFolderForWrite(sess, folderName)
 	if err != nil {
 		return "", err
 	}
@@ -220,23 +456,83 @@ func (api *API) CreateSubfolder(sess *beepboop.Session, folderName, subfolder st
 		return "", &ErrSubfoldersDisabled{Folder: folderName}
 	}
 
-	safeName, err := getSafeFilename(subfolder)
+	safeName, err := getSafeFilename(o.Name)
 	if err != nil {
 		return "", err
 	}
+	if internal.IsReservedPath(safeName) {
+		return "", &ErrInvalidName{Name: o.Name}
+	}
 
-	err = os.Mkdir(path.Join(api.root, folder.RelPath, safeName), 0755)
+	subfolderPath := path.Join(folder.RelPath, safeName)
+	if _, err := api.storage.Stat(subfolderPath); err == nil {
+		return "", &os.PathError{Op: "mkdir", Path: subfolderPath, Err: os.ErrExist}
+	}
+	err = api.storage.MkdirAll(subfolderPath)
 	if err != nil {
 		return "", err
 	}
 
+	details := ""
+	if o.OwnConfig {
+		err = api.setSubfolderConfig(sess, folder.NewConfiguredSubfolder(safeName, o.ParentManaged), o)
+		if err != nil {
+			internal.RemoveFolderTree(api.root, subfolderPath)
+			return "", err
+		}
+		details = "own config"
+		if o.ParentManaged {
+			details += " (managed by parent)"
+		}
+	}
+
 	folder.CacheSubfolder(safeName)
 	changed = true
-	return path.Join(folder.RelPath, safeName), nil
+	api.audit(sess, folder, internal.AuditCreateSubfolder, subfolderPath, details)
+	return subfolderPath, nil
+}
+
+func (api *API) setSubfolderConfig(sess Session, subfolder *internal.Folder, o *CreateSubfolderOptions) error {
+	err := subfolder.SetPasswords(o.ReadPassword, "")
+	if err != nil {
+		return err
+	}
+	// a write password is required unless the parent folder manages the subfolder
+	if len(o.WritePassword) > 0 || !o.ParentManaged {
+		err = subfolder.SetWritePassword(o.WritePassword)
+		if err != nil {
+			return err
+		}
+	}
+	if len(o.UploadPassword) > 0 {
+		err = subfolder.SetUploadPassword(o.UploadPassword)
+		if err != nil {
+			return err
+		}
+	}
+	if o.Settings != nil {
+		err = subfolder.SetSettings(o.Settings.toInternal())
+		if err != nil {
+			return err
+		}
+	}
+
+	// the creator knows the passwords, so the session gets access right away
+	if len(o.ReadPassword) > 0 {
+		token, _ := subfolder.GetAccessToken("read")
+		if err := sess.MergeAccess(token); err != nil {
+			return err
+		}
+	}
+	if len(o.WritePassword) > 0 {
+		token, _ := subfolder.GetAccessToken("write")
+		return sess.MergeAccess(token)
+	}
+	return nil
 }
 
 // DeleteSubfolder ...
-func (api *API) DeleteSubfolder(sess *beepboop.Session, folderName, subfolder string) error {
+func (api *API) DeleteSubfolder(sess Session, folderName, subfolder string) error {
 	flags, err := api.GetFolderFlags(sess, path.Join(folderName, subfolder))
 	if err != nil {
 		return err
@@ -250,18 +546,156 @@ func (api *API) DeleteSubfolder(sess *beepboop.Session, folderName, subfolder st
 		return &ErrNotDeletable{Name: subfolder}
 	}
 
-	parent, unlock, _, err := api.getFolder(folderName)
+	parent, unlock, _, err := api.getFolderForWrite(sess, folderName)
 	if err != nil {
 		return &ErrNotDeletable{Name: subfolder}
 	}
 	defer unlock()
 
-	err = os.Remove(path.Join(api.root, folderName, subfolder))
+	subfolderPath := path.Join(folderName, subfolder)
+	err = internal.RemoveAuditLog(api.root, subfolderPath)
+	if err != nil {
+		return err
+	}
+	err = api.storage.Remove(subfolderPath)
 	if err != nil {
 		return err
 	}
 
 	parent.UncacheSubfolder(subfolder)
+	api.audit(sess, parent, internal.AuditDeleteSubfolder, subfolderPath, "")
 	api.goCacheFolder(parent)
 	return nil
 }
+
+// RenameSubfolder renames a subfolder and returns its new path
+func (api *API) RenameSubfolder(sess Session, folderName, newName string) (string, error) {
+	safeName, err := getSafeFilename(newName)
+	if err != nil {
+		return "", err
+	}
+	if internal.IsReservedPath(safeName) {
+		return "", &ErrInvalidName{Name: newName}
+	}
+	return api.moveSubfolder(sess, folderName, path.Join(path.Dir(path.Clean(folderName)), safeName))
+}
+
+// MoveSubfolder moves a subfolder under another folder of the same config root and returns its new path
+func (api *API) MoveSubfolder(sess Session, folderName, moveTo string) (string, error) {
+	return api.moveSubfolder(sess, folderName, path.Join(path.Clean(moveTo), path.Base(path.Clean(folderName))))
+}
+
+func (api *API) moveSubfolder(sess Session, folderName, newPath string) (string, error) {
+	folder, unlock, _, err := api.getFolderForWrite(sess, folderName)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	err = folder.EnsureReadAccess(sess)
+	if err != nil {
+		return "", &ErrNoReadAccess{Folder: folderName}
+	}
+
+	err = folder.EnsureWriteAccess(sess)
+	if err != nil {
+		return "", &ErrNoWriteAccess{Folder: folderName}
+	}
+
+	oldPath := folder.RelPath
+	if !folder.ConfigInherited || internal.HasNestedConfig(api.root, oldPath) {
+		return "", &ErrInvalidMoveLocation{Location: newPath}
+	}
+	if newPath == oldPath {
+		return newPath, nil
+	}
+
+	// the new parent has to be the config root or one of its subfolders that inherit its config
+	newParent := path.Dir(newPath)
+	if internal.IsReservedPath(newPath) || newParent == oldPath || strings.HasPrefix(newParent, oldPath+"/") {
+		return "", &ErrInvalidMoveLocation{Location: newPath}
+	}
+	parent, _, err := api.getFolderNoLock(newParent)
+	if err != nil || parent.ConfigRootFolder != folder.ConfigRootFolder || !internal.IsFolder(api.root, newParent) {
+		return "", &ErrInvalidMoveLocation{Location: newPath}
+	}
+	if _, err := api.storage.Stat(newPath); err == nil {
+		return "", &ErrFolderExists{Folder: newPath}
+	}
+
+	tree, err := internal.GetFolderTree(api.root, oldPath)
+	if err != nil {
+		return "", err
+	}
+	err = folder.MoveFolderTree(oldPath, newPath)
+	if err != nil {
+		return "", err
+	}
+
+	if api.cache != nil {
+		for _, subfolder := range tree {
+			api.cache.UncacheFolder(subfolder)
+		}
+		// the parents have different subfolders and the config root might have updated share links
+		if configRoot, err := internal.GetFolder(api.root, folder.ConfigRootFolder); err == nil {
+			api.cache.TouchFolder(configRoot.RelPath)
+			api.uncacheInheritedSubfolders(configRoot)
+		}
+	}
+	if parent, err := internal.GetFolder(api.root, path.Dir(oldPath)); err == nil {
+		api.audit(sess, parent, internal.AuditMoveSubfolder, oldPath, "moved to "+newPath)
+	}
+	if newParent != path.Dir(oldPath) {
+		api.audit(sess, parent, internal.AuditMoveSubfolder, newPath, "moved from "+oldPath)
+	}
+	return newPath, nil
+}
+
+// DeleteSubfolderRecursive moves the files of a subfolder (and its subfolders) to the trash and deletes the folders.
+// It returns the number of trashed files.
+func (api *API) DeleteSubfolderRecursive(sess Session, folderName string) (int, error) {
+	folder, unlock, _, err := api.getFolderForWrite(sess, folderName)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	err = folder.EnsureReadAccess(sess)
+	if err != nil {
+		return 0, &ErrNoReadAccess{Folder: folderName}
+	}
+
+	err = folder.EnsureWriteAccess(sess)
+	if err != nil {
+		return 0, &ErrNoWriteAccess{Folder: folderName}
+	}
+
+	if !folder.ConfigInherited || internal.HasNestedConfig(api.root, folder.RelPath) {
+		return 0, &ErrNotDeletable{Name: folderName}
+	}
+
+	tree, err := internal.GetFolderTree(api.root, folder.RelPath)
+	if err != nil {
+		return 0, err
+	}
+	trashed, err := folder.TrashFolderTree(folder.RelPath)
+
+	if api.cache != nil {
+		for _, subfolder := range tree {
+			api.cache.UncacheFolder(subfolder)
+		}
+		api.cache.TouchFolder(path.Dir(folder.RelPath))
+	}
+	if err != nil {
+		return trashed, err
+	}
+
+	if parent, err := internal.GetFolder(api.root, path.Dir(folder.RelPath)); err == nil {
+		details := fmt.Sprintf("%d files moved to trash", trashed)
+		if folder.Config.TrashRetentionDays < 0 {
+			details = fmt.Sprintf("%d files deleted", trashed)
+		}
+		api.audit(sess, parent, internal.AuditDeleteSubfolder, folder.RelPath, details)
+	}
+	return trashed, nil
+}
@@ -9,7 +9,6 @@ import (
 	"time"
 
 	"github.com/mholt/archiver"
-	"github.com/razzie/beepboop"
 	"github.com/razzie/razbox/internal"
 )
 
@@ -25,7 +24,9 @@ type FolderEntry struct {
 	Extension     string           `json:"extension"`
 	Tags          []string         `json:"tags,omitempty"`
 	Size          int64            `json:"size,omitempty"`
+	SHA256        string           `json:"sha256,omitempty"`
 	Uploaded      int64            `json:"uploaded,omitempty"`
+	Uploader      string           `json:"uploader,omitempty"`
 	Public        bool             `json:"public,omitempty"`
 	EditMode      bool             `json:"edit_mode,omitempty"`
 	HasThumbnail  bool             `json:"has_thumbnail,omitempty"`
@@ -54,7 +55,9 @@ func newFileEntry(uri string, file *internal.File, thumbnailRetryAfter time.Dura
 		Extension:     extension,
 		Tags:          file.Tags,
 		Size:          file.Size,
+		SHA256:        file.Hash,
 		Uploaded:      file.Uploaded.Unix(),
+		Uploader:      file.Uploader,
 		Public:        file.Public,
 		HasThumbnail:  internal.IsThumbnailSupported(file.MIME),
 		Archive:       primaryType == "archive",
@@ -102,7 +105,7 @@ func (f *FolderEntry) HasTag(tag string) bool {
 }
 
 // GetFolderEntries ...
-func (api *API) GetFolderEntries(sess *beepboop.Session, folderOrFilename string) ([]*FolderEntry, *FolderFlags, error) {
+func (api *API) GetFolderEntries(sess Session, folderOrFilename string) ([]*FolderEntry, *FolderFlags, error) {
 	folderOrFilename = path.Clean(folderOrFilename)
 
 	var filename string
@@ -112,7 +115,7 @@ func (api *API) GetFolderEntries(sess *beepboop.Session, folderOrFilename string
 		filename = folderOrFilename
 	}
 
-	folder, unlock, cached, err := api.getFolder(dir)
+	folder, unlock, cached, err := api.getFolder(sess, dir)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -122,6 +125,13 @@ func (api *API) GetFolderEntries(sess *beepboop.Session, folderOrFilename string
 	defer unlock()
 
 	hasViewAccess := folder.EnsureReadAccess(sess) == nil
+	if !hasViewAccess {
+		share, err := api.checkShareAccess(sess, folder, folderOrFilename)
+		if err != nil {
+			return nil, nil, err
+		}
+		hasViewAccess = share != nil
+	}
 	hasEditAccess := folder.EnsureWriteAccess(sess) == nil
 
 	if len(filename) > 0 {
@@ -3,11 +3,13 @@ module github.com/razzie/razbox
 go 1.16
 
 require (
+	github.com/alicebob/miniredis/v2 v2.30.0
 	github.com/asaskevich/govalidator v0.0.0-20200907205600-7a23bdc65eef
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/dsnet/compress v0.0.1 // indirect
 	github.com/frankban/quicktest v1.11.1 // indirect
 	github.com/gabriel-vasile/mimetype v1.1.1
+	github.com/go-redis/redis/v7 v7.4.0
 	github.com/golang/snappy v0.0.2 // indirect
 	github.com/google/uuid v1.1.2
 	github.com/kjk/lzmadec v0.0.0-20200118223809-980b947af806
@@ -17,9 +19,11 @@ require (
 	github.com/nwaples/rardecode v1.1.0
 	github.com/pierrec/lz4 v2.5.2+incompatible // indirect
 	github.com/razzie/beepboop v0.0.0-20220727153421-2c4dc6572fb5
+	github.com/razzie/reqip v0.0.0-20201102012254-b5eb0ae76a05
 	github.com/stretchr/testify v1.6.1 // indirect
 	github.com/ulikunitz/xz v0.5.8 // indirect
 	github.com/xi2/xz v0.0.0-20171230120015-48954b6210f8 // indirect
+	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
 	golang.org/x/image v0.0.0-20200927104501-e162460cd6b5
 	golang.org/x/net v0.0.0-20201006153459-a7d1128ccaa0 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776 // indirect
@@ -0,0 +1,184 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Admin holds the credentials of the server administrator
+type Admin struct {
+	hash       []byte
+	accessCode string
+}
+
+// NewAdmin returns a new Admin with the given password (or key)
+func NewAdmin(pw string) (*Admin, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pw), BcryptCost)
+	if err != nil {
+		return nil, err
+	}
+	// the access code only changes with the password, so admin sessions survive restarts
+	mac := hmac.New(sha256.New, []byte(pw))
+	mac.Write([]byte("razbox admin"))
+	return &Admin{
+		hash:       hash,
+		accessCode: hex.EncodeToString(mac.Sum(nil)),
+	}, nil
+}
+
+// TestPassword returns whether the given password matches the admin password
+func (a *Admin) TestPassword(pw string) bool {
+	return bcrypt.CompareHashAndPassword(a.hash, []byte(pw)) == nil
+}
+
+// GetAccessCode returns the code that admin access tokens carry
+func (a *Admin) GetAccessCode() string {
+	return a.accessCode
+}
+
+// EnsureAccess returns an error if the access token doesn't permit admin access
+func (a *Admin) EnsureAccess(sess AccessProvider) error {
+	code, ok := sess.GetAccessCode("admin", "razbox")
+	if !ok || subtle.ConstantTimeCompare([]byte(code), []byte(a.accessCode)) != 1 {
+		return &ErrWrongPassword{}
+	}
+	return nil
+}
+
+func isInternalFolderName(name string) bool {
+	return name == TrashFolderName || name == BlobFolderName || name == QuarantineFolderName
+}
+
+// FindConfigRoots returns the folders of a root that have their own config
+func FindConfigRoots(root string) ([]string, error) {
+	var roots []string
+	err := walkStorage(storage(root), ".", func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if isInternalFolderName(info.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Name() == ".razbox" {
+			roots = append(roots, path.Dir(name))
+		}
+		return nil
+	})
+	sort.Strings(roots)
+	return roots, err
+}
+
+// FolderUsage is the storage usage of a config root folder (and its subfolders that inherit its config)
+type FolderUsage struct {
+	Size       int64
+	Files      int
+	Subfolders int
+}
+
+// GetUsage returns the storage usage of the folder's config root
+func (f *Folder) GetUsage() (*FolderUsage, error) {
+	s := storage(f.Root)
+	usage := new(FolderUsage)
+	err := walkStorage(s, f.ConfigRootFolder, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if isInternalFolderName(info.Name()) {
+				return filepath.SkipDir
+			}
+			if name == f.ConfigRootFolder {
+				return nil
+			}
+			if _, err := s.Stat(path.Join(name, ".razbox")); err == nil {
+				return filepath.SkipDir // has its own config
+			}
+			usage.Subfolders++
+			return nil
+		}
+
+		base := strings.TrimSuffix(info.Name(), ".json")
+		if path.Ext(info.Name()) != ".json" || isVersionName(base) {
+			return nil
+		}
+		file, err := getFile(f.Root, path.Join(path.Dir(name), base))
+		if err != nil {
+			return nil
+		}
+		usage.Files++
+		usage.Size += file.Size
+		return nil
+	})
+	return usage, err
+}
+
+// RemoveFolderTree permanently deletes a folder with all of its files, versions, trash and subfolders
+func RemoveFolderTree(root, relPath string) error {
+	s := storage(root)
+	var files, dirs []string
+	err := walkStorage(s, relPath, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			dirs = append(dirs, name)
+		} else {
+			files = append(files, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range files {
+		if path.Ext(name) == ".json" {
+			releaseBlob(s, name)
+		}
+	}
+	for _, name := range files {
+		if err := s.Remove(name); err != nil && !isNotExist(err) {
+			return err
+		}
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := s.Remove(dirs[i]); err != nil && !isNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseBlob removes the blob reference of a file, version or trashed file metadata (if it has any)
+func releaseBlob(s Storage, jsonName string) {
+	data, err := s.ReadFile(jsonName)
+	if err != nil {
+		return
+	}
+	var metadata struct {
+		Blob string `json:"blob"`
+		File *File  `json:"file"`
+	}
+	if json.Unmarshal(data, &metadata) != nil {
+		return
+	}
+	switch {
+	case len(metadata.Blob) > 0:
+		unrefBlob(s, metadata.Blob)
+	case metadata.File != nil && len(metadata.File.Blob) > 0:
+		unrefBlob(s, metadata.File.Blob)
+	}
+}
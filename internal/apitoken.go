@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"time"
+
+	"github.com/razzie/beepboop"
+)
+
+// APIToken is a named bearer token that grants read or write access to a folder
+type APIToken struct {
+	Name       string    `json:"name"`
+	Hash       string    `json:"hash"`
+	AccessType string    `json:"access_type"`
+	Created    time.Time `json:"created"`
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetAPIToken returns the API token with the given name
+func (f *Folder) GetAPIToken(name string) (*APIToken, error) {
+	for _, t := range f.Config.APITokens {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return nil, &ErrAPITokenNotFound{Name: name}
+}
+
+// CreateAPIToken generates a new API token and returns its plain text value
+func (f *Folder) CreateAPIToken(name, accessType string) (string, error) {
+	if f.ConfigInherited {
+		return "", &ErrInheritedConfigChange{}
+	}
+
+	if accessType != "read" && accessType != "write" && accessType != "upload" {
+		return "", &ErrInvalidAccessType{AccessType: accessType}
+	}
+
+	if t, _ := f.GetAPIToken(name); t != nil {
+		return "", &ErrAPITokenAlreadyExists{Name: name}
+	}
+
+	var secret [24]byte
+	if _, err := rand.Read(secret[:]); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(secret[:])
+
+	f.Config.APITokens = append(f.Config.APITokens, &APIToken{
+		Name:       name,
+		Hash:       hashAPIToken(token),
+		AccessType: accessType,
+		Created:    time.Now(),
+	})
+	if err := f.save(); err != nil {
+		f.Config.APITokens = f.Config.APITokens[:len(f.Config.APITokens)-1]
+		return "", err
+	}
+
+	return token, nil
+}
+
+// RevokeAPIToken removes the API token with the given name
+func (f *Folder) RevokeAPIToken(name string) error {
+	if f.ConfigInherited {
+		return &ErrInheritedConfigChange{}
+	}
+
+	for i, t := range f.Config.APITokens {
+		if t.Name == name {
+			f.Config.APITokens = append(f.Config.APITokens[:i], f.Config.APITokens[i+1:]...)
+			return f.save()
+		}
+	}
+	return &ErrAPITokenNotFound{Name: name}
+}
+
+// FindAPIToken returns the API token that matches the given plain text token
+func (f *Folder) FindAPIToken(token string) (*APIToken, error) {
+	hash := []byte(hashAPIToken(token))
+	for _, t := range f.Config.APITokens {
+		if subtle.ConstantTimeCompare(hash, []byte(t.Hash)) == 1 {
+			return t, nil
+		}
+	}
+	return nil, &ErrInvalidAPIToken{}
+}
+
+// GetAPITokenAccess returns the access that the given API token permits
+func (f *Folder) GetAPITokenAccess(t *APIToken) (beepboop.AccessMap, error) {
+	if t.AccessType == "upload" {
+		return f.GetAccessToken("upload")
+	}
+
+	access, err := f.GetAccessToken("read")
+	if err != nil {
+		return nil, err
+	}
+	if t.AccessType == "write" {
+		writeAccess, err := f.GetAccessToken("write")
+		if err != nil {
+			return nil, err
+		}
+		access.Merge(writeAccess)
+	}
+	return access, nil
+}
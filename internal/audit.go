@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditLogName is the name of the append-only audit log in each folder
+const AuditLogName = ".audit.jsonl"
+
+// Audited actions
+const (
+	AuditAuth            = "auth"
+	AuditAuthFailed      = "auth-failed"
+	AuditUpload          = "upload"
+	AuditEdit            = "edit"
+	AuditDelete          = "delete"
+	AuditRestore         = "restore"
+	AuditCreateSubfolder = "create-subfolder"
+	AuditDeleteSubfolder = "delete-subfolder"
+	AuditMoveSubfolder   = "move-subfolder"
+	AuditChangePassword  = "change-password"
+	AuditChangeSettings  = "change-settings"
+	AuditAPIToken        = "api-token"
+	AuditMember          = "member"
+	AuditShareLink       = "share-link"
+)
+
+// AuditActions lists the audited actions
+var AuditActions = []string{
+	AuditAuth,
+	AuditAuthFailed,
+	AuditUpload,
+	AuditEdit,
+	AuditDelete,
+	AuditRestore,
+	AuditCreateSubfolder,
+	AuditDeleteSubfolder,
+	AuditMoveSubfolder,
+	AuditChangePassword,
+	AuditChangeSettings,
+	AuditAPIToken,
+	AuditMember,
+	AuditShareLink,
+}
+
+// AuditEntry is a line of the audit log
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	IP      string    `json:"ip,omitempty"`
+	Session string    `json:"session,omitempty"`
+	Action  string    `json:"action"`
+	Path    string    `json:"path,omitempty"`
+	Details string    `json:"details,omitempty"`
+}
+
+// AuditFilter selects audit log entries (zero values match everything)
+type AuditFilter struct {
+	Action string
+	Query  string // case insensitive substring of the IP, session, path or details
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+// Matches returns whether the entry passes the filter
+func (filter *AuditFilter) Matches(e *AuditEntry) bool {
+	if len(filter.Action) > 0 && e.Action != filter.Action {
+		return false
+	}
+	if !filter.Since.IsZero() && e.Time.Before(filter.Since) {
+		return false
+	}
+	if !filter.Until.IsZero() && e.Time.After(filter.Until) {
+		return false
+	}
+	if len(filter.Query) > 0 {
+		q := strings.ToLower(filter.Query)
+		for _, field := range []string{e.IP, e.Session, e.Path, e.Details} {
+			if strings.Contains(strings.ToLower(field), q) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// auditLock serializes the appends (S3 storages implement Append as read + write)
+var auditLock sync.Mutex
+
+// AppendAuditLog appends an entry to the audit log of a folder
+func AppendAuditLog(root, folder string, e *AuditEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	auditLock.Lock()
+	defer auditLock.Unlock()
+	_, err = storage(root).Append(path.Join(folder, AuditLogName), bytes.NewReader(data))
+	return err
+}
+
+// GetAuditLog returns the entries of a folder's audit log that pass the filter (newest first)
+func GetAuditLog(root, folder string, filter *AuditFilter) ([]*AuditEntry, error) {
+	data, err := storage(root).ReadFile(path.Join(folder, AuditLogName))
+	if err != nil {
+		if isNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []*AuditEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		e := new(AuditEntry)
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			continue
+		}
+		if filter.Matches(e) {
+			entries = append(entries, e)
+		}
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+	return entries, nil
+}
+
+// RemoveAuditLog removes the audit log of a folder (so the empty folder can be removed)
+func RemoveAuditLog(root, folder string) error {
+	err := storage(root).Remove(path.Join(folder, AuditLogName))
+	if isNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// BlobFolderName is the name of the hidden directory in the root that contains the deduplicated file contents
+const BlobFolderName = ".blobs"
+
+// blobs are shared between folders of different config roots, so folder locks don't protect them
+var blobLock sync.Mutex
+
+// getBlobName returns the storage name of a blob identified by the SHA-256 hash of its content
+func getBlobName(hash string) string {
+	return path.Join(BlobFolderName, hash[:2], hash)
+}
+
+func getBlobRefs(s Storage, hash string) int {
+	data, err := s.ReadFile(getBlobName(hash) + ".refs")
+	if err != nil {
+		return 0
+	}
+	refs, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return refs
+}
+
+func setBlobRefs(s Storage, hash string, refs int) error {
+	return s.WriteFile(getBlobName(hash)+".refs", []byte(strconv.Itoa(refs)))
+}
+
+// putBlob stores the content in the blob store (unless a blob with the same content already exists)
+// and adds a reference to the blob
+func putBlob(s Storage, content io.Reader) (hash string, n int64, err error) {
+	if err := s.MkdirAll(BlobFolderName); err != nil {
+		return "", 0, err
+	}
+
+	var tmpName string
+	if staged, ok := content.(*StagedContent); ok {
+		// the content is already stored and hashed
+		tmpName, hash, n = staged.name, staged.hash, staged.size
+	} else {
+		tmpName = path.Join(BlobFolderName, "tmp-"+uuid.New().String())
+		hasher := sha256.New()
+		n, err = s.Write(tmpName, io.TeeReader(content, hasher))
+		if err != nil {
+			_ = s.Remove(tmpName)
+			return "", n, err
+		}
+		hash = hex.EncodeToString(hasher.Sum(nil))
+	}
+
+	blobLock.Lock()
+	defer blobLock.Unlock()
+
+	blobName := getBlobName(hash)
+	if _, err := s.Stat(blobName); err == nil {
+		_ = s.Remove(tmpName)
+	} else {
+		if err := s.MkdirAll(path.Dir(blobName)); err != nil {
+			_ = s.Remove(tmpName)
+			return "", n, err
+		}
+		if err := s.Rename(tmpName, blobName); err != nil {
+			_ = s.Remove(tmpName)
+			return "", n, err
+		}
+	}
+
+	return hash, n, setBlobRefs(s, hash, getBlobRefs(s, hash)+1)
+}
+
+func refBlob(s Storage, hash string) error {
+	blobLock.Lock()
+	defer blobLock.Unlock()
+
+	if _, err := s.Stat(getBlobName(hash)); err != nil {
+		return err
+	}
+	return setBlobRefs(s, hash, getBlobRefs(s, hash)+1)
+}
+
+// unrefBlob removes a reference to the blob and deletes the blob if it's not referenced anymore
+func unrefBlob(s Storage, hash string) error {
+	blobLock.Lock()
+	defer blobLock.Unlock()
+
+	blobName := getBlobName(hash)
+	refs := getBlobRefs(s, hash) - 1
+	if refs > 0 {
+		return setBlobRefs(s, hash, refs)
+	}
+
+	_ = s.Remove(blobName + ".refs")
+	return s.Remove(blobName)
+}
+
+// releaseContent deletes the .bin file or removes the blob reference of a stored file metadata
+func releaseContent(s Storage, relPath string) error {
+	data, err := s.ReadFile(relPath + ".json")
+	if err == nil {
+		var f File
+		if json.Unmarshal(data, &f) == nil && len(f.Blob) > 0 {
+			return unrefBlob(s, f.Blob)
+		}
+	}
+	return s.Remove(relPath + ".bin")
+}
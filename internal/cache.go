@@ -0,0 +1,273 @@
+package internal
+
+import (
+	"container/list"
+	"encoding/json"
+	"path"
+	"sync"
+	"time"
+)
+
+// FolderCache stores folders so they don't have to be read from the storage every time.
+// The files of a folder are cached one by one, so a changed file doesn't rewrite the whole folder.
+// Every change increments the version of the folder, and a folder read from the storage is only cached
+// if its version is still the same as before it was read (so it can't overwrite a newer change).
+type FolderCache interface {
+	// GetCachedFolder returns a cached folder with its files (or ErrNotCached) and the current version of the folder.
+	// If CacheVersion of the returned folder is older, its config and subfolders could have changed since it was cached
+	// (but its files are up to date).
+	GetCachedFolder(folderName string) (folder *Folder, version uint64, err error)
+	// CacheFolder caches a folder of the given CacheVersion, or updates the config and subfolders of a cached folder
+	CacheFolder(folder *Folder) error
+	// TouchFolder increments the version of a folder whose config or subfolders changed and returns the new version
+	TouchFolder(folderName string) (uint64, error)
+	// CacheFile adds or updates a file of a cached folder (and increments the version of the folder)
+	CacheFile(folderName string, file *File) error
+	// UncacheFile removes a file of a cached folder (and increments the version of the folder)
+	UncacheFile(folderName, filename string) error
+	// UncacheFolder removes a cached folder with its files (and increments the version of the folder)
+	UncacheFolder(folderName string) error
+}
+
+func encodeCachedFolder(folder *Folder) ([]byte, error) {
+	header := *folder
+	header.CachedFiles = nil
+	return json.Marshal(&header)
+}
+
+func encodeCachedFile(file *File) ([]byte, error) {
+	f := *file
+	f.Thumbnail = nil
+	return json.Marshal(&f)
+}
+
+func decodeCachedFolder(header []byte, files [][]byte) (*Folder, error) {
+	folder := new(Folder)
+	if err := json.Unmarshal(header, folder); err != nil {
+		return nil, err
+	}
+	folder.CachedFiles = make([]*File, 0, len(files))
+	for _, data := range files {
+		file := new(File)
+		if err := json.Unmarshal(data, file); err != nil {
+			return nil, err
+		}
+		folder.CachedFiles = append(folder.CachedFiles, file)
+	}
+	return folder, nil
+}
+
+// MemoryFolderCache is an in-process FolderCache that evicts the least recently used folders when it's full.
+// Folders and files are stored as JSON, so they can't be changed through the cached or returned values.
+// Only the cached folders have versions of their own, the other folders share a version that changes
+// whenever any of them changes (so the versions don't use memory for every folder ever changed).
+type MemoryFolderCache struct {
+	mu              sync.Mutex
+	duration        time.Duration
+	maxEntries      int
+	entries         map[string]*list.Element
+	lru             *list.List
+	lastVersion     uint64 // every change gets a new version, so a version is never reused
+	uncachedVersion uint64
+}
+
+type memoryFolderEntry struct {
+	name    string
+	version uint64
+	header  []byte
+	files   map[string][]byte
+	expires time.Time
+}
+
+// NewMemoryFolderCache returns a new MemoryFolderCache that keeps folders for the given duration
+func NewMemoryFolderCache(duration time.Duration, maxEntries int) *MemoryFolderCache {
+	return &MemoryFolderCache{
+		duration:   duration,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// GetCachedFolder returns a cached folder with its files and the current version of the folder
+func (c *MemoryFolderCache) GetCachedFolder(folderName string) (*Folder, uint64, error) {
+	name := path.Clean(folderName)
+
+	c.mu.Lock()
+	entry := c.get(name)
+	version := c.version(name)
+	if entry == nil {
+		c.mu.Unlock()
+		return nil, version, &ErrNotCached{}
+	}
+	header := entry.header
+	files := make([][]byte, 0, len(entry.files))
+	for _, data := range entry.files {
+		files = append(files, data)
+	}
+	c.mu.Unlock()
+
+	folder, err := decodeCachedFolder(header, files)
+	return folder, version, err
+}
+
+// CacheFolder caches a folder (reading its files only if it's not cached yet)
+func (c *MemoryFolderCache) CacheFolder(folder *Folder) error {
+	name := path.Clean(folder.RelPath)
+	folder.GetSubfolders()
+	header, err := encodeCachedFolder(folder)
+	if err != nil {
+		return err
+	}
+
+	if c.updateHeader(name, folder.CacheVersion, header) {
+		return nil
+	}
+
+	files := make(map[string][]byte)
+	for _, file := range folder.GetFiles() {
+		data, err := encodeCachedFile(file)
+		if err != nil {
+			return err
+		}
+		files[file.Name] = data
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry := c.get(name); entry != nil || c.version(name) != folder.CacheVersion {
+		if entry != nil && entry.version == folder.CacheVersion {
+			entry.header = header
+		}
+		return nil
+	}
+	c.entries[name] = c.lru.PushFront(&memoryFolderEntry{
+		name:    name,
+		version: folder.CacheVersion,
+		header:  header,
+		files:   files,
+		expires: time.Now().Add(c.duration),
+	})
+	for c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+	return nil
+}
+
+// updateHeader replaces the header of a cached folder of the same version.
+// It returns false if the folder isn't cached yet, so it has to be cached with its files.
+func (c *MemoryFolderCache) updateHeader(name string, version uint64, header []byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.get(name)
+	if c.version(name) != version {
+		return true
+	}
+	if entry == nil {
+		return false
+	}
+	entry.header = header
+	return true
+}
+
+// TouchFolder increments the version of a folder
+func (c *MemoryFolderCache) TouchFolder(folderName string) (uint64, error) {
+	name := path.Clean(folderName)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.touch(name), nil
+}
+
+// CacheFile adds or updates a file of a cached folder
+func (c *MemoryFolderCache) CacheFile(folderName string, file *File) error {
+	name := path.Clean(folderName)
+	data, err := encodeCachedFile(file)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.touch(name)
+	if entry := c.get(name); entry != nil {
+		entry.files[file.Name] = data
+	}
+	return nil
+}
+
+// UncacheFile removes a file of a cached folder
+func (c *MemoryFolderCache) UncacheFile(folderName, filename string) error {
+	name := path.Clean(folderName)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.touch(name)
+	if entry := c.get(name); entry != nil {
+		delete(entry.files, filename)
+	}
+	return nil
+}
+
+// UncacheFolder removes a cached folder with its files
+func (c *MemoryFolderCache) UncacheFolder(folderName string) error {
+	name := path.Clean(folderName)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[name]; ok {
+		c.remove(elem)
+	} else {
+		c.touch(name)
+	}
+	return nil
+}
+
+// get returns the entry of a folder (or nil if it's not cached or expired)
+func (c *MemoryFolderCache) get(name string) *memoryFolderEntry {
+	elem, ok := c.entries[name]
+	if !ok {
+		return nil
+	}
+	entry := elem.Value.(*memoryFolderEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(elem)
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	return entry
+}
+
+// remove removes the entry of a folder, which changes the version of the uncached folders,
+// so the folder can't be cached again by a reader that read it before it was removed
+func (c *MemoryFolderCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*memoryFolderEntry).name)
+	c.lastVersion++
+	c.uncachedVersion = c.lastVersion
+}
+
+// version returns the current version of a folder
+func (c *MemoryFolderCache) version(name string) uint64 {
+	if elem, ok := c.entries[name]; ok {
+		return elem.Value.(*memoryFolderEntry).version
+	}
+	return c.uncachedVersion
+}
+
+// touch gives a folder a new version and returns it
+func (c *MemoryFolderCache) touch(name string) uint64 {
+	c.lastVersion++
+	if elem, ok := c.entries[name]; ok {
+		elem.Value.(*memoryFolderEntry).version = c.lastVersion
+	} else {
+		c.uncachedVersion = c.lastVersion
+	}
+	return c.lastVersion
+}
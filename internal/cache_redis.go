@@ -0,0 +1,256 @@
+package internal
+
+import (
+	"encoding/json"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+)
+
+var (
+	// KEYS: version, header, files; ARGV: file key prefix
+	redisGetFolder = redis.NewScript(`
+local version = redis.call("GET", KEYS[1]) or "0"
+local header = redis.call("GET", KEYS[2])
+if not header then
+	return {version}
+end
+local result = {version, header}
+for _, name in ipairs(redis.call("SMEMBERS", KEYS[3])) do
+	local file = redis.call("HGETALL", ARGV[1] .. name)
+	if #file == 0 then
+		-- a file expired before the folder, so the folder has to be cached again
+		redis.call("DEL", KEYS[2])
+		return {version}
+	end
+	table.insert(result, file)
+end
+return result`)
+
+	// KEYS: version, header, files; ARGV: expected version, header, TTL in ms, file key prefix,
+	// whether files follow, then the name, the number of fields and the fields of every file
+	redisCacheFolder = redis.NewScript(`
+if (redis.call("GET", KEYS[1]) or "0") ~= ARGV[1] then
+	return 0
+end
+local ttl = redis.call("PTTL", KEYS[2])
+if ttl > 0 then
+	redis.call("SET", KEYS[2], ARGV[2], "PX", ttl)
+	return 1
+elseif ttl == -1 then
+	redis.call("SET", KEYS[2], ARGV[2])
+	return 1
+elseif ARGV[5] == "0" then
+	return -1
+end
+local function expire(key)
+	if ARGV[3] ~= "0" then
+		redis.call("PEXPIRE", key, ARGV[3])
+	end
+end
+for _, name in ipairs(redis.call("SMEMBERS", KEYS[3])) do
+	redis.call("DEL", ARGV[4] .. name)
+end
+redis.call("DEL", KEYS[3])
+local i = 6
+while i <= #ARGV do
+	local name, n = ARGV[i], tonumber(ARGV[i + 1])
+	local key = ARGV[4] .. name
+	redis.call("SADD", KEYS[3], name)
+	redis.call("HSET", key, unpack(ARGV, i + 2, i + 1 + 2 * n))
+	expire(key)
+	i = i + 2 + 2 * n
+end
+expire(KEYS[3])
+redis.call("SET", KEYS[2], ARGV[2])
+expire(KEYS[2])
+return 1`)
+
+	// KEYS: version, header, files, file; ARGV: name, fields
+	redisCacheFile = redis.NewScript(`
+local ttl = redis.call("PTTL", KEYS[2])
+if ttl ~= -2 then
+	redis.call("SADD", KEYS[3], ARGV[1])
+	redis.call("DEL", KEYS[4])
+	redis.call("HSET", KEYS[4], unpack(ARGV, 2))
+	if ttl > 0 then
+		redis.call("PEXPIRE", KEYS[3], ttl)
+		redis.call("PEXPIRE", KEYS[4], ttl)
+	end
+end
+return redis.call("INCR", KEYS[1])`)
+
+	// KEYS: version, files, file; ARGV: name
+	redisUncacheFile = redis.NewScript(`
+redis.call("SREM", KEYS[2], ARGV[1])
+redis.call("DEL", KEYS[3])
+return redis.call("INCR", KEYS[1])`)
+
+	// KEYS: version, header, files; ARGV: file key prefix
+	redisUncacheFolder = redis.NewScript(`
+for _, name in ipairs(redis.call("SMEMBERS", KEYS[3])) do
+	redis.call("DEL", ARGV[1] .. name)
+end
+redis.call("DEL", KEYS[2], KEYS[3])
+return redis.call("INCR", KEYS[1])`)
+)
+
+// RedisFolderCache is a FolderCache shared by every razbox instance using the same Redis.
+// A folder is stored as a header (the folder without its files), the set of its filenames
+// and a hash of every file, which has the JSON encoded attributes of the file as fields.
+type RedisFolderCache struct {
+	client   *redis.Client
+	duration time.Duration
+}
+
+// NewRedisFolderCache returns a new RedisFolderCache that keeps folders for the given duration
+func NewRedisFolderCache(client *redis.Client, duration time.Duration) *RedisFolderCache {
+	return &RedisFolderCache{
+		client:   client,
+		duration: duration,
+	}
+}
+
+func getFolderKeys(name string) []string {
+	return []string{
+		"razbox-folder-version:" + name,
+		"razbox-folder:" + name,
+		"razbox-folder-files:" + name,
+	}
+}
+
+func getFileKeyPrefix(folderName string) string {
+	return "razbox-file:" + folderName + "/"
+}
+
+// GetCachedFolder returns a cached folder with its files and the current version of the folder
+func (c *RedisFolderCache) GetCachedFolder(folderName string) (*Folder, uint64, error) {
+	name := path.Clean(folderName)
+	result, err := redisGetFolder.Run(c.client, getFolderKeys(name), getFileKeyPrefix(name)).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	values, _ := result.([]interface{})
+	if len(values) == 0 {
+		return nil, 0, &ErrNotCached{}
+	}
+	versionStr, _ := values[0].(string)
+	version, _ := strconv.ParseUint(versionStr, 10, 64)
+	if len(values) < 2 {
+		return nil, version, &ErrNotCached{}
+	}
+
+	header, _ := values[1].(string)
+	files := make([][]byte, 0, len(values)-2)
+	for _, value := range values[2:] {
+		fields, _ := value.([]interface{})
+		data, err := fieldsToJSON(fields)
+		if err != nil {
+			return nil, version, err
+		}
+		files = append(files, data)
+	}
+	folder, err := decodeCachedFolder([]byte(header), files)
+	return folder, version, err
+}
+
+// CacheFolder caches a folder (reading its files only if it's not cached yet)
+func (c *RedisFolderCache) CacheFolder(folder *Folder) error {
+	name := path.Clean(folder.RelPath)
+	folder.GetSubfolders()
+	header, err := encodeCachedFolder(folder)
+	if err != nil {
+		return err
+	}
+
+	args := []interface{}{
+		strconv.FormatUint(folder.CacheVersion, 10),
+		header,
+		int64(c.duration / time.Millisecond),
+		getFileKeyPrefix(name),
+		"0",
+	}
+	result, err := redisCacheFolder.Run(c.client, getFolderKeys(name), args...).Int()
+	if err != nil || result >= 0 {
+		return err
+	}
+
+	args[4] = "1"
+	for _, file := range folder.GetFiles() {
+		data, err := encodeCachedFile(file)
+		if err != nil {
+			return err
+		}
+		fields, err := jsonToFields(data)
+		if err != nil {
+			return err
+		}
+		args = append(args, file.Name, len(fields)/2)
+		args = append(args, fields...)
+	}
+	return redisCacheFolder.Run(c.client, getFolderKeys(name), args...).Err()
+}
+
+// TouchFolder increments the version of a folder
+func (c *RedisFolderCache) TouchFolder(folderName string) (uint64, error) {
+	version, err := c.client.Incr(getFolderKeys(path.Clean(folderName))[0]).Result()
+	return uint64(version), err
+}
+
+// CacheFile adds or updates a file of a cached folder
+func (c *RedisFolderCache) CacheFile(folderName string, file *File) error {
+	name := path.Clean(folderName)
+	data, err := encodeCachedFile(file)
+	if err != nil {
+		return err
+	}
+	fields, err := jsonToFields(data)
+	if err != nil {
+		return err
+	}
+
+	keys := append(getFolderKeys(name), getFileKeyPrefix(name)+file.Name)
+	args := append([]interface{}{file.Name}, fields...)
+	return redisCacheFile.Run(c.client, keys, args...).Err()
+}
+
+// UncacheFile removes a file of a cached folder
+func (c *RedisFolderCache) UncacheFile(folderName, filename string) error {
+	name := path.Clean(folderName)
+	keys := getFolderKeys(name)
+	keys = []string{keys[0], keys[2], getFileKeyPrefix(name) + filename}
+	return redisUncacheFile.Run(c.client, keys, filename).Err()
+}
+
+// UncacheFolder removes a cached folder with its files
+func (c *RedisFolderCache) UncacheFolder(folderName string) error {
+	name := path.Clean(folderName)
+	return redisUncacheFolder.Run(c.client, getFolderKeys(name), getFileKeyPrefix(name)).Err()
+}
+
+// jsonToFields returns the attributes of a JSON object as hash fields and values
+func jsonToFields(data []byte) ([]interface{}, error) {
+	var attrs map[string]json.RawMessage
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		return nil, err
+	}
+	fields := make([]interface{}, 0, 2*len(attrs))
+	for attr, value := range attrs {
+		fields = append(fields, attr, string(value))
+	}
+	return fields, nil
+}
+
+// fieldsToJSON is the reverse of jsonToFields
+func fieldsToJSON(fields []interface{}) ([]byte, error) {
+	attrs := make(map[string]json.RawMessage, len(fields)/2)
+	for i := 0; i+1 < len(fields); i += 2 {
+		attr, _ := fields[i].(string)
+		value, _ := fields[i+1].(string)
+		attrs[attr] = json.RawMessage(value)
+	}
+	return json.Marshal(attrs)
+}
@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryFolderCacheVersions(t *testing.T) {
+	tests := []struct {
+		name       string
+		change     func(c *MemoryFolderCache) // runs after the folder was read, before it's cached
+		wantCached bool
+	}{
+		{"unchanged", func(c *MemoryFolderCache) {}, true},
+		{"touched", func(c *MemoryFolderCache) { c.TouchFolder("a") }, false},
+		{"file uncached", func(c *MemoryFolderCache) { c.UncacheFile("a", "x") }, false},
+		{"folder uncached", func(c *MemoryFolderCache) { c.UncacheFolder("a") }, false},
+		{"other folder touched", func(c *MemoryFolderCache) { c.TouchFolder("b") }, false},
+		{"cached and evicted", func(c *MemoryFolderCache) {
+			c.CacheFolder(&Folder{RelPath: "a", CachedFiles: []*File{}})
+			c.TouchFolder("a")
+			c.CacheFolder(&Folder{RelPath: "b", CachedFiles: []*File{}})
+			c.CacheFolder(&Folder{RelPath: "c", CachedFiles: []*File{}})
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewMemoryFolderCache(time.Hour, 2)
+			_, version, _ := c.GetCachedFolder("a")
+			folder := &Folder{RelPath: "a", CachedFiles: []*File{{Name: "x"}}, CacheVersion: version}
+			tt.change(c)
+
+			if err := c.CacheFolder(folder); err != nil {
+				t.Fatal(err)
+			}
+			cached, _, err := c.GetCachedFolder("a")
+			if gotCached := err == nil && len(cached.CachedFiles) == 1; gotCached != tt.wantCached {
+				t.Errorf("cached = %t, want %t", gotCached, tt.wantCached)
+			}
+		})
+	}
+}
+
+func TestMemoryFolderCacheEviction(t *testing.T) {
+	c := NewMemoryFolderCache(time.Hour, 2)
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprint("f", i)
+		_, version, _ := c.GetCachedFolder(name)
+		if err := c.CacheFolder(&Folder{RelPath: name, CacheVersion: version, CachedFiles: []*File{}}); err != nil {
+			t.Fatal(err)
+		}
+		c.TouchFolder(name)
+		c.UncacheFile(fmt.Sprint("g", i), "x")
+	}
+	if len(c.entries) != 2 || c.lru.Len() != 2 {
+		t.Errorf("%d folders are cached, want 2", len(c.entries))
+	}
+	for _, name := range []string{"f98", "f99"} {
+		if _, _, err := c.GetCachedFolder(name); err != nil {
+			t.Errorf("%s isn't cached: %v", name, err)
+		}
+	}
+}
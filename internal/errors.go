@@ -2,6 +2,7 @@ package internal
 
 import (
 	"fmt"
+	"net/http"
 )
 
 // ErrFileAlreadyExists ...
@@ -13,6 +14,14 @@ func (err ErrFileAlreadyExists) Error() string {
 	return "File already exists: " + err.File
 }
 
+func (err ErrFileAlreadyExists) Code() string {
+	return "file_already_exists"
+}
+
+func (err ErrFileAlreadyExists) HTTPStatus() int {
+	return http.StatusConflict
+}
+
 // ErrFolderConfigNotFound ...
 type ErrFolderConfigNotFound struct {
 	Folder string
@@ -22,6 +31,14 @@ func (err ErrFolderConfigNotFound) Error() string {
 	return "Config file not found for folder: " + err.Folder
 }
 
+func (err ErrFolderConfigNotFound) Code() string {
+	return "folder_config_not_found"
+}
+
+func (err ErrFolderConfigNotFound) HTTPStatus() int {
+	return http.StatusNotFound
+}
+
 // ErrInheritedConfigPasswordChange ...
 type ErrInheritedConfigPasswordChange struct{}
 
@@ -29,6 +46,14 @@ func (err ErrInheritedConfigPasswordChange) Error() string {
 	return "Cannot change password of folders that inherit parent configuration"
 }
 
+func (err ErrInheritedConfigPasswordChange) Code() string {
+	return "inherited_config_password_change"
+}
+
+func (err ErrInheritedConfigPasswordChange) HTTPStatus() int {
+	return http.StatusConflict
+}
+
 // ErrReadWritePasswordMatch ...
 type ErrReadWritePasswordMatch struct{}
 
@@ -36,6 +61,14 @@ func (err ErrReadWritePasswordMatch) Error() string {
 	return "Read and write passwords cannot match"
 }
 
+func (err ErrReadWritePasswordMatch) Code() string {
+	return "read_write_password_match"
+}
+
+func (err ErrReadWritePasswordMatch) HTTPStatus() int {
+	return http.StatusBadRequest
+}
+
 // ErrWrongPassword ...
 type ErrWrongPassword struct{}
 
@@ -43,6 +76,14 @@ func (err ErrWrongPassword) Error() string {
 	return "Wrong password"
 }
 
+func (err ErrWrongPassword) Code() string {
+	return "wrong_password"
+}
+
+func (err ErrWrongPassword) HTTPStatus() int {
+	return http.StatusUnauthorized
+}
+
 // ErrPasswordScoreTooLow ...
 type ErrPasswordScoreTooLow struct {
 	Score int
@@ -52,6 +93,29 @@ func (err ErrPasswordScoreTooLow) Error() string {
 	return fmt.Sprintf("Password scored too low (%d) on zxcvbn test", err.Score)
 }
 
+func (err ErrPasswordScoreTooLow) Code() string {
+	return "password_score_too_low"
+}
+
+func (err ErrPasswordScoreTooLow) HTTPStatus() int {
+	return http.StatusBadRequest
+}
+
+// ErrUploadPasswordMatch ...
+type ErrUploadPasswordMatch struct{}
+
+func (err ErrUploadPasswordMatch) Error() string {
+	return "Upload password cannot match the read or write password"
+}
+
+func (err ErrUploadPasswordMatch) Code() string {
+	return "upload_password_match"
+}
+
+func (err ErrUploadPasswordMatch) HTTPStatus() int {
+	return http.StatusBadRequest
+}
+
 // ErrInvalidAccessType ...
 type ErrInvalidAccessType struct {
 	AccessType string
@@ -61,6 +125,14 @@ func (err ErrInvalidAccessType) Error() string {
 	return "Invalid access type: " + err.AccessType
 }
 
+func (err ErrInvalidAccessType) Code() string {
+	return "invalid_access_type"
+}
+
+func (err ErrInvalidAccessType) HTTPStatus() int {
+	return http.StatusBadRequest
+}
+
 // ErrFolderNotWritable ...
 type ErrFolderNotWritable struct{}
 
@@ -68,6 +140,29 @@ func (err ErrFolderNotWritable) Error() string {
 	return "Folder not writable"
 }
 
+func (err ErrFolderNotWritable) Code() string {
+	return "folder_not_writable"
+}
+
+func (err ErrFolderNotWritable) HTTPStatus() int {
+	return http.StatusForbidden
+}
+
+// ErrFolderNotUploadable ...
+type ErrFolderNotUploadable struct{}
+
+func (err ErrFolderNotUploadable) Error() string {
+	return "Folder doesn't accept uploads"
+}
+
+func (err ErrFolderNotUploadable) Code() string {
+	return "folder_not_uploadable"
+}
+
+func (err ErrFolderNotUploadable) HTTPStatus() int {
+	return http.StatusForbidden
+}
+
 // ErrUnsupportedFileFormat ...
 type ErrUnsupportedFileFormat struct {
 	MIME string
@@ -76,3 +171,375 @@ type ErrUnsupportedFileFormat struct {
 func (err ErrUnsupportedFileFormat) Error() string {
 	return "Unsupported file format: " + err.MIME
 }
+
+func (err ErrUnsupportedFileFormat) Code() string {
+	return "unsupported_file_format"
+}
+
+func (err ErrUnsupportedFileFormat) HTTPStatus() int {
+	return http.StatusUnsupportedMediaType
+}
+
+// ErrInheritedConfigChange ...
+type ErrInheritedConfigChange struct{}
+
+func (err ErrInheritedConfigChange) Error() string {
+	return "Cannot change configuration of folders that inherit parent configuration"
+}
+
+func (err ErrInheritedConfigChange) Code() string {
+	return "inherited_config_change"
+}
+
+func (err ErrInheritedConfigChange) HTTPStatus() int {
+	return http.StatusConflict
+}
+
+// ErrInvalidAPIToken ...
+type ErrInvalidAPIToken struct{}
+
+func (err ErrInvalidAPIToken) Error() string {
+	return "Invalid API token"
+}
+
+func (err ErrInvalidAPIToken) Code() string {
+	return "invalid_api_token"
+}
+
+func (err ErrInvalidAPIToken) HTTPStatus() int {
+	return http.StatusUnauthorized
+}
+
+// ErrAPITokenNotFound ...
+type ErrAPITokenNotFound struct {
+	Name string
+}
+
+func (err ErrAPITokenNotFound) Error() string {
+	return "API token not found: " + err.Name
+}
+
+func (err ErrAPITokenNotFound) Code() string {
+	return "api_token_not_found"
+}
+
+func (err ErrAPITokenNotFound) HTTPStatus() int {
+	return http.StatusNotFound
+}
+
+// ErrAPITokenAlreadyExists ...
+type ErrAPITokenAlreadyExists struct {
+	Name string
+}
+
+func (err ErrAPITokenAlreadyExists) Error() string {
+	return "API token already exists: " + err.Name
+}
+
+func (err ErrAPITokenAlreadyExists) Code() string {
+	return "api_token_already_exists"
+}
+
+func (err ErrAPITokenAlreadyExists) HTTPStatus() int {
+	return http.StatusConflict
+}
+
+// ErrTrashedFileNotFound ...
+type ErrTrashedFileNotFound struct {
+	ID string
+}
+
+func (err ErrTrashedFileNotFound) Error() string {
+	return "Trashed file not found: " + err.ID
+}
+
+func (err ErrTrashedFileNotFound) Code() string {
+	return "trashed_file_not_found"
+}
+
+func (err ErrTrashedFileNotFound) HTTPStatus() int {
+	return http.StatusNotFound
+}
+
+// ErrFileVersionNotFound ...
+type ErrFileVersionNotFound struct {
+	File    string
+	Version int
+}
+
+func (err ErrFileVersionNotFound) Error() string {
+	return fmt.Sprintf("Version %d of %s not found", err.Version, err.File)
+}
+
+func (err ErrFileVersionNotFound) Code() string {
+	return "file_version_not_found"
+}
+
+func (err ErrFileVersionNotFound) HTTPStatus() int {
+	return http.StatusNotFound
+}
+
+// ErrUnsupportedStorage ...
+type ErrUnsupportedStorage struct {
+	Root string
+}
+
+func (err ErrUnsupportedStorage) Error() string {
+	return "Unsupported storage: " + err.Root
+}
+
+func (err ErrUnsupportedStorage) Code() string {
+	return "unsupported_storage"
+}
+
+func (err ErrUnsupportedStorage) HTTPStatus() int {
+	return http.StatusInternalServerError
+}
+
+// ErrS3Request ...
+type ErrS3Request struct {
+	Method     string
+	Key        string
+	StatusCode int
+	S3Code     string
+	Message    string
+}
+
+func (err ErrS3Request) Error() string {
+	return fmt.Sprintf("S3 %s %s failed with status %d: %s %s", err.Method, err.Key, err.StatusCode, err.S3Code, err.Message)
+}
+
+func (err ErrS3Request) Code() string {
+	return "storage_error"
+}
+
+func (err ErrS3Request) HTTPStatus() int {
+	return http.StatusBadGateway
+}
+
+// ErrInvalidShareToken ...
+type ErrInvalidShareToken struct{}
+
+func (err ErrInvalidShareToken) Error() string {
+	return "Invalid share link"
+}
+
+func (err ErrInvalidShareToken) Code() string {
+	return "invalid_share_token"
+}
+
+func (err ErrInvalidShareToken) HTTPStatus() int {
+	return http.StatusForbidden
+}
+
+// ErrShareLinkNotFound ...
+type ErrShareLinkNotFound struct {
+	ID string
+}
+
+func (err ErrShareLinkNotFound) Error() string {
+	return "Share link not found: " + err.ID
+}
+
+func (err ErrShareLinkNotFound) Code() string {
+	return "share_link_not_found"
+}
+
+func (err ErrShareLinkNotFound) HTTPStatus() int {
+	return http.StatusNotFound
+}
+
+// ErrShareLinkExpired ...
+type ErrShareLinkExpired struct{}
+
+func (err ErrShareLinkExpired) Error() string {
+	return "Share link expired"
+}
+
+func (err ErrShareLinkExpired) Code() string {
+	return "share_link_expired"
+}
+
+func (err ErrShareLinkExpired) HTTPStatus() int {
+	return http.StatusGone
+}
+
+// ErrShareLinkExhausted ...
+type ErrShareLinkExhausted struct{}
+
+func (err ErrShareLinkExhausted) Error() string {
+	return "Share link reached its download limit"
+}
+
+func (err ErrShareLinkExhausted) Code() string {
+	return "share_link_exhausted"
+}
+
+func (err ErrShareLinkExhausted) HTTPStatus() int {
+	return http.StatusGone
+}
+
+// ErrShareLinkRangeNotAllowed ...
+type ErrShareLinkRangeNotAllowed struct{}
+
+func (err ErrShareLinkRangeNotAllowed) Error() string {
+	return "Share links with a download limit don't support range requests"
+}
+
+func (err ErrShareLinkRangeNotAllowed) Code() string {
+	return "share_link_range_not_allowed"
+}
+
+func (err ErrShareLinkRangeNotAllowed) HTTPStatus() int {
+	return http.StatusRequestedRangeNotSatisfiable
+}
+
+// ErrSharePasswordRequired ...
+type ErrSharePasswordRequired struct{}
+
+func (err ErrSharePasswordRequired) Error() string {
+	return "Share link password required"
+}
+
+func (err ErrSharePasswordRequired) Code() string {
+	return "share_password_required"
+}
+
+func (err ErrSharePasswordRequired) HTTPStatus() int {
+	return http.StatusUnauthorized
+}
+
+// ErrMemberNotFound ...
+type ErrMemberNotFound struct {
+	Name string
+}
+
+func (err ErrMemberNotFound) Error() string {
+	return "Member not found: " + err.Name
+}
+
+func (err ErrMemberNotFound) Code() string {
+	return "member_not_found"
+}
+
+func (err ErrMemberNotFound) HTTPStatus() int {
+	return http.StatusNotFound
+}
+
+// ErrMemberAlreadyExists ...
+type ErrMemberAlreadyExists struct {
+	Name string
+}
+
+func (err ErrMemberAlreadyExists) Error() string {
+	return "Member already exists: " + err.Name
+}
+
+func (err ErrMemberAlreadyExists) Code() string {
+	return "member_already_exists"
+}
+
+func (err ErrMemberAlreadyExists) HTTPStatus() int {
+	return http.StatusConflict
+}
+
+// ErrInvalidMemberName ...
+type ErrInvalidMemberName struct {
+	Name string
+}
+
+func (err ErrInvalidMemberName) Error() string {
+	return "Invalid member name: " + err.Name
+}
+
+func (err ErrInvalidMemberName) Code() string {
+	return "invalid_member_name"
+}
+
+func (err ErrInvalidMemberName) HTTPStatus() int {
+	return http.StatusBadRequest
+}
+
+// ErrInvalidRole ...
+type ErrInvalidRole struct {
+	Role string
+}
+
+func (err ErrInvalidRole) Error() string {
+	return "Invalid role: " + err.Role
+}
+
+func (err ErrInvalidRole) Code() string {
+	return "invalid_role"
+}
+
+func (err ErrInvalidRole) HTTPStatus() int {
+	return http.StatusBadRequest
+}
+
+// ErrInvalidFolderSetting ...
+type ErrInvalidFolderSetting struct {
+	Setting string
+}
+
+func (err ErrInvalidFolderSetting) Error() string {
+	return "Invalid folder setting: " + err.Setting
+}
+
+func (err ErrInvalidFolderSetting) Code() string {
+	return "invalid_folder_setting"
+}
+
+func (err ErrInvalidFolderSetting) HTTPStatus() int {
+	return http.StatusBadRequest
+}
+
+// ErrFileTypeNotAllowed ...
+type ErrFileTypeNotAllowed struct {
+	Filename string
+	MIME     string
+}
+
+func (err ErrFileTypeNotAllowed) Error() string {
+	return fmt.Sprintf("File type not allowed in this folder: %s (%s)", err.Filename, err.MIME)
+}
+
+func (err ErrFileTypeNotAllowed) Code() string {
+	return "file_type_not_allowed"
+}
+
+func (err ErrFileTypeNotAllowed) HTTPStatus() int {
+	return http.StatusUnsupportedMediaType
+}
+
+// ErrNotCached ...
+type ErrNotCached struct{}
+
+func (err ErrNotCached) Error() string {
+	return "Not cached"
+}
+
+func (err ErrNotCached) Code() string {
+	return "not_cached"
+}
+
+func (err ErrNotCached) HTTPStatus() int {
+	return http.StatusNotFound
+}
+
+// ErrWatchNotSupported ...
+type ErrWatchNotSupported struct {
+	Root string
+}
+
+func (err ErrWatchNotSupported) Error() string {
+	return "Watching storage root is not supported: " + err.Root
+}
+
+func (err ErrWatchNotSupported) Code() string {
+	return "watch_not_supported"
+}
+
+func (err ErrWatchNotSupported) HTTPStatus() int {
+	return http.StatusNotImplemented
+}
@@ -1,12 +1,12 @@
 package internal
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
-	"fmt"
 	"image"
 	"io"
-	"io/ioutil"
-	"os"
+	"log"
 	"path"
 	"path/filepath"
 	"time"
@@ -21,12 +21,15 @@ type File struct {
 	MIME      string     `json:"mime"`
 	Size      int64      `json:"size"`
 	Uploaded  time.Time  `json:"uploaded"`
+	Uploader  string     `json:"uploader,omitempty"` // name of the member who uploaded the file
 	Public    bool       `json:"public"`
 	Thumbnail *Thumbnail `json:"thumbnail,omitempty"`
+	Blob      string     `json:"blob,omitempty"` // SHA-256 of the content in the blob store (if deduplicated)
+	Hash      string     `json:"sha256,omitempty"`
 }
 
 func getFile(root, relPath string) (*File, error) {
-	data, err := ioutil.ReadFile(path.Join(root, relPath+".json"))
+	data, err := storage(root).ReadFile(relPath + ".json")
 	if err != nil {
 		return nil, err
 	}
@@ -40,12 +43,24 @@ func getFile(root, relPath string) (*File, error) {
 		return nil, err
 	}
 	f.Root = root // overwrite root with possible new root
+	if len(f.Hash) == 0 {
+		f.Hash = f.Blob // blobs are named after the SHA-256 of their content
+	}
 	return f, nil
 }
 
-// GetInternalFilename ...
-func (f *File) GetInternalFilename() string {
-	return path.Join(f.Root, f.RelPath+".bin")
+// GetContentName returns the name of the file content in the storage
+func (f *File) GetContentName() string {
+	if len(f.Blob) > 0 {
+		return getBlobName(f.Blob)
+	}
+	return f.RelPath + ".bin"
+}
+
+// GetLocalFilename returns the name of a local file with the content
+// (and a cleanup function that must be called when the file is not needed anymore)
+func (f *File) GetLocalFilename() (filename string, cleanup func(), err error) {
+	return getLocalFilename(storage(f.Root), f.GetContentName())
 }
 
 // GetThumbnail ...
@@ -54,12 +69,13 @@ func (f *File) GetThumbnail(retryAfter time.Duration) (*Thumbnail, error) {
 		return nil, &ErrUnsupportedFileFormat{MIME: f.MIME}
 	}
 
-	thumbFilename := path.Join(f.Root, f.RelPath+".thumb")
+	s := storage(f.Root)
+	thumbFilename := f.RelPath + ".thumb"
 
 	// migrate thumbnails to .thumb files
 	if f.Thumbnail != nil && len(f.Thumbnail.Data) > 0 {
 		data, _ := json.MarshalIndent(f.Thumbnail, "", "  ")
-		if err := ioutil.WriteFile(thumbFilename, data, 0644); err != nil {
+		if err := s.WriteFile(thumbFilename, data); err != nil {
 			return f.Thumbnail, err
 		}
 		thumb := f.Thumbnail
@@ -68,9 +84,9 @@ func (f *File) GetThumbnail(retryAfter time.Duration) (*Thumbnail, error) {
 		return thumb, nil
 	}
 
-	data, err := ioutil.ReadFile(thumbFilename)
+	data, err := s.ReadFile(thumbFilename)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if isNotExist(err) {
 			return f.createThumbnail()
 		}
 		return nil, err
@@ -98,16 +114,17 @@ func (f *File) GetThumbnailBounds(retryAfter time.Duration) (*image.Rectangle, e
 }
 
 func (f *File) createThumbnail() (*Thumbnail, error) {
-	thumbFilename := path.Join(f.Root, f.RelPath+".thumb")
-	thumb, err := GetThumbnail(f.GetInternalFilename(), f.MIME)
+	s := storage(f.Root)
+	thumbFilename := f.RelPath + ".thumb"
+	thumb, err := f.getThumbnail()
 	if err != nil {
 		thumb = &Thumbnail{Timestamp: time.Now()}
 		data, _ := json.MarshalIndent(thumb, "", "  ")
-		ioutil.WriteFile(thumbFilename, data, 0644)
+		s.WriteFile(thumbFilename, data)
 		return nil, err
 	}
 	data, _ := json.MarshalIndent(thumb, "", "  ")
-	ioutil.WriteFile(thumbFilename, data, 0644)
+	s.WriteFile(thumbFilename, data)
 	f.Thumbnail = &Thumbnail{
 		MIME:      thumb.MIME,
 		Bounds:    thumb.Bounds,
@@ -117,6 +134,15 @@ func (f *File) createThumbnail() (*Thumbnail, error) {
 	return thumb, nil
 }
 
+func (f *File) getThumbnail() (*Thumbnail, error) {
+	filename, cleanup, err := f.GetLocalFilename()
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	return GetThumbnail(filename, f.MIME)
+}
+
 // Open ...
 func (f *File) Open() (FileReader, error) {
 	return newFileReader(f)
@@ -125,18 +151,32 @@ func (f *File) Open() (FileReader, error) {
 // Save ...
 func (f *File) Save() error {
 	data, _ := json.MarshalIndent(f, "", "  ")
-	return ioutil.WriteFile(path.Join(f.Root, f.RelPath+".json"), data, 0644)
+	return storage(f.Root).WriteFile(f.RelPath+".json", data)
 }
 
 // Create ...
 func (f *File) Create(content io.Reader, overwrite bool) error {
-	absPath := path.Join(f.Root, f.RelPath)
-	dataFilename := absPath + ".bin"
-	jsonFilename := absPath + ".json"
+	return f.create(content, overwrite, false)
+}
+
+// CreateDeduplicated is like Create, but stores the content in the blob store of the root
+func (f *File) CreateDeduplicated(content io.Reader, overwrite bool) error {
+	return f.create(content, overwrite, true)
+}
 
-	if _, err := os.Stat(jsonFilename); os.IsNotExist(err) || overwrite {
+func (f *File) create(content io.Reader, overwrite, dedup bool) error {
+	s := storage(f.Root)
+	dataFilename := f.RelPath + ".bin"
+	jsonFilename := f.RelPath + ".json"
+
+	var old *File
+	if _, err := s.Stat(jsonFilename); isNotExist(err) || overwrite {
+		if content != nil {
+			old, _ = getFile(f.Root, f.RelPath)
+			f.Blob = ""
+		}
 		data, _ := json.MarshalIndent(f, "", "  ")
-		err := ioutil.WriteFile(jsonFilename, data, 0644)
+		err := s.WriteFile(jsonFilename, data)
 		if err != nil {
 			return err
 		}
@@ -145,32 +185,40 @@ func (f *File) Create(content io.Reader, overwrite bool) error {
 	}
 
 	if content != nil {
-		tmpfile, err := ioutil.TempFile(f.Root, fmt.Sprintf("razbox-upload-*-%s", f.Name))
-		if err != nil {
-			return err
+		if len(f.MIME) == 0 {
+			var err error
+			f.MIME, content, err = SniffContentType(content)
+			if err != nil {
+				s.Remove(jsonFilename)
+				return err
+			}
 		}
-		defer os.Remove(tmpfile.Name())
-		defer tmpfile.Close()
 
-		n, err := io.Copy(tmpfile, content)
+		var n int64
+		var err error
+		if dedup {
+			f.Blob, n, err = putBlob(s, content)
+			if err == nil {
+				f.Hash = f.Blob
+				_ = s.Remove(dataFilename) // in case a regular file got overwritten
+			}
+		} else if staged, ok := content.(*StagedContent); ok {
+			n, f.Hash = staged.size, staged.hash
+			err = staged.moveTo(dataFilename)
+		} else {
+			hasher := sha256.New()
+			n, err = s.Write(dataFilename, io.TeeReader(content, hasher))
+			f.Hash = hex.EncodeToString(hasher.Sum(nil))
+		}
 		if err != nil {
-			os.Remove(jsonFilename)
+			s.Remove(jsonFilename)
 			return err
 		}
+		f.Size = n
+		f.Save()
 
-		if len(f.MIME) == 0 || f.Size == 0 {
-			tmpfile.Seek(0, io.SeekStart)
-			f.MIME, _ = DetectContentType(tmpfile)
-			f.Size = n
-			f.Save()
-		}
-
-		tmpfile.Close()
-		os.Chmod(tmpfile.Name(), 0644)
-		err = os.Rename(tmpfile.Name(), dataFilename)
-		if err != nil {
-			os.Remove(jsonFilename)
-			return err
+		if old != nil && len(old.Blob) > 0 {
+			unrefBlob(s, old.Blob)
 		}
 
 		if IsThumbnailSupported(f.MIME) {
@@ -195,25 +243,31 @@ func (f *File) Move(relPath string) error {
 		return err
 	}
 
-	err = os.Rename(
-		path.Join(f.Root, oldRelPath+".bin"),
-		path.Join(f.Root, f.RelPath+".bin"),
-	)
+	s := storage(f.Root)
+	if len(f.Blob) == 0 {
+		err = s.Rename(oldRelPath+".bin", f.RelPath+".bin")
+	}
 	if err != nil {
-		_ = os.Remove(path.Join(f.Root, f.RelPath+".json"))
+		_ = s.Remove(f.RelPath + ".json")
 		f.Name = oldName
 		f.RelPath = oldRelPath
 		return err
 	}
 
-	_ = os.Remove(path.Join(f.Root, oldRelPath+".json"))
+	_ = s.Remove(oldRelPath + ".json")
+	if err := moveVersions(s, oldRelPath, f.RelPath); err != nil {
+		log.Print("Move error:", err)
+	}
 	return nil
 }
 
 // Delete ...
 func (f *File) Delete() error {
-	_ = os.Remove(path.Join(f.Root, f.RelPath+".json"))
-	return os.Remove(path.Join(f.Root, f.RelPath+".bin"))
+	s := storage(f.Root)
+	deleteVersions(s, f.RelPath)
+	err := releaseContent(s, f.RelPath)
+	_ = s.Remove(f.RelPath + ".json")
+	return err
 }
 
 // HasTag ...
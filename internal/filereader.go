@@ -11,10 +11,11 @@ type FileReader interface {
 	http.File
 	os.FileInfo
 	MimeType() string
+	Hash() string
 }
 
 type fileReader struct {
-	file *os.File
+	file StorageObject
 	sys  *File
 }
 
@@ -66,8 +67,12 @@ func (r fileReader) MimeType() string {
 	return r.sys.MIME
 }
 
+func (r fileReader) Hash() string {
+	return r.sys.Hash
+}
+
 func newFileReader(sys *File) (*fileReader, error) {
-	file, err := os.Open(sys.GetInternalFilename())
+	file, err := storage(sys.Root).Open(sys.GetContentName())
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"path"
+	"strings"
+)
+
+// normalizeExtension returns the lowercase extension of a filename (or an extension list entry) without the dot
+func normalizeExtension(ext string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+}
+
+// normalizeMIME strips the parameters (like charset) from a MIME type
+func normalizeMIME(mime string) string {
+	if i := strings.IndexByte(mime, ';'); i >= 0 {
+		mime = mime[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(mime))
+}
+
+// matchMIME returns whether a MIME type matches a pattern like "image/png", "image/*" or "*"
+func matchMIME(pattern, mime string) bool {
+	pattern = normalizeMIME(pattern)
+	if pattern == "*" || pattern == "*/*" || pattern == mime {
+		return true
+	}
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(mime, strings.TrimSuffix(pattern, "*"))
+	}
+	return false
+}
+
+// normalizeMIMEPatterns validates and normalizes a list of MIME type patterns
+func normalizeMIMEPatterns(patterns []string, setting string) ([]string, error) {
+	var results []string
+	for _, pattern := range patterns {
+		pattern = normalizeMIME(pattern)
+		if len(pattern) == 0 {
+			continue
+		}
+		parts := strings.Split(pattern, "/")
+		if pattern != "*" && (len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 || parts[0] == "*") {
+			return nil, &ErrInvalidFolderSetting{Setting: setting}
+		}
+		results = append(results, pattern)
+	}
+	return results, nil
+}
+
+// normalizeExtensions validates and normalizes a list of file extensions
+func normalizeExtensions(exts []string, setting string) ([]string, error) {
+	var results []string
+	for _, ext := range exts {
+		ext = normalizeExtension(ext)
+		if len(ext) == 0 {
+			continue
+		}
+		if strings.ContainsAny(ext, "/\\*. ") {
+			return nil, &ErrInvalidFolderSetting{Setting: setting}
+		}
+		results = append(results, ext)
+	}
+	return results, nil
+}
+
+// CheckFileType returns an error if the folder config doesn't permit a file with the given name and MIME type.
+// Blocked types and extensions take precedence over the allowed ones, and empty allow lists permit everything.
+// An empty MIME type (not known yet, like before the content of an upload arrives) only checks the extension.
+func (f *Folder) CheckFileType(filename, mime string) error {
+	ext := normalizeExtension(path.Ext(filename))
+	mime = normalizeMIME(mime)
+	notAllowed := &ErrFileTypeNotAllowed{Filename: filename, MIME: mime}
+
+	for _, blocked := range f.Config.BlockedExtensions {
+		if len(ext) > 0 && normalizeExtension(blocked) == ext {
+			return notAllowed
+		}
+	}
+	for _, blocked := range f.Config.BlockedTypes {
+		if len(mime) > 0 && matchMIME(blocked, mime) {
+			return notAllowed
+		}
+	}
+
+	if len(f.Config.AllowedExtensions) > 0 {
+		allowed := false
+		for _, allowedExt := range f.Config.AllowedExtensions {
+			if normalizeExtension(allowedExt) == ext {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return notAllowed
+		}
+	}
+	if len(f.Config.AllowedTypes) > 0 && len(mime) > 0 {
+		allowed := false
+		for _, allowedType := range f.Config.AllowedTypes {
+			if matchMIME(allowedType, mime) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return notAllowed
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckFileType(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      FolderConfig
+		filename    string
+		mime        string
+		wantAllowed bool
+	}{
+		{"no restrictions", FolderConfig{}, "a.exe", "application/octet-stream", true},
+		{"allowed type", FolderConfig{AllowedTypes: []string{"image/png"}}, "a.png", "image/png", true},
+		{"allowed type with charset", FolderConfig{AllowedTypes: []string{"text/plain"}}, "a.txt", "Text/Plain; charset=utf-8", true},
+		{"allowed type wildcard", FolderConfig{AllowedTypes: []string{"image/*"}}, "a.jpg", "image/jpeg", true},
+		{"not allowed type", FolderConfig{AllowedTypes: []string{"image/*"}}, "a.txt", "text/plain", false},
+		{"blocked type", FolderConfig{BlockedTypes: []string{"text/html"}}, "a.htm", "text/html; charset=utf-8", false},
+		{"blocked type wildcard", FolderConfig{BlockedTypes: []string{"application/*"}}, "a.zip", "application/zip", false},
+		{"blocked before allowed", FolderConfig{AllowedTypes: []string{"image/*"}, BlockedTypes: []string{"image/svg+xml"}}, "a.svg", "image/svg+xml", false},
+		{"allowed extension", FolderConfig{AllowedExtensions: []string{"pdf"}}, "a.PDF", "application/pdf", true},
+		{"not allowed extension", FolderConfig{AllowedExtensions: []string{"pdf"}}, "a.doc", "application/msword", false},
+		{"no extension", FolderConfig{AllowedExtensions: []string{"pdf"}}, "README", "text/plain", false},
+		{"blocked extension", FolderConfig{BlockedExtensions: []string{"exe"}}, "a.Exe", "application/octet-stream", false},
+		{"blocked extension without extension", FolderConfig{BlockedExtensions: []string{"exe"}}, "exe", "application/octet-stream", true},
+		{"allowed extension but not type", FolderConfig{AllowedExtensions: []string{"png"}, AllowedTypes: []string{"image/png"}}, "a.png", "text/html", false},
+		{"unknown type", FolderConfig{AllowedTypes: []string{"image/*"}, BlockedTypes: []string{"image/svg+xml"}}, "a.svg", "", true},
+		{"unknown type and not allowed extension", FolderConfig{AllowedExtensions: []string{"png"}, AllowedTypes: []string{"image/*"}}, "a.svg", "", false},
+		{"unknown type and blocked extension", FolderConfig{BlockedExtensions: []string{"exe"}}, "a.exe", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folder := &Folder{Config: tt.config}
+			err := folder.CheckFileType(tt.filename, tt.mime)
+			if _, notAllowed := err.(*ErrFileTypeNotAllowed); err != nil && !notAllowed {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if allowed := err == nil; allowed != tt.wantAllowed {
+				t.Errorf("allowed = %t, want %t", allowed, tt.wantAllowed)
+			}
+		})
+	}
+}
+
+func TestNormalizeFileTypeSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings FolderSettings
+		wantErr  bool
+		want     FolderConfig
+	}{
+		{"empty", FolderSettings{}, false, FolderConfig{}},
+		{"types", FolderSettings{AllowedTypes: []string{" Image/* ", "", "text/plain; charset=utf-8"}, BlockedTypes: []string{"*"}}, false,
+			FolderConfig{AllowedTypes: []string{"image/*", "text/plain"}, BlockedTypes: []string{"*"}}},
+		{"extensions", FolderSettings{AllowedExtensions: []string{".JPG", "png", " "}, BlockedExtensions: []string{"exe"}}, false,
+			FolderConfig{AllowedExtensions: []string{"jpg", "png"}, BlockedExtensions: []string{"exe"}}},
+		{"type without subtype", FolderSettings{AllowedTypes: []string{"image"}}, true, FolderConfig{}},
+		{"wildcard type", FolderSettings{BlockedTypes: []string{"*/html"}}, true, FolderConfig{}},
+		{"extension with dot", FolderSettings{AllowedExtensions: []string{"tar.gz"}}, true, FolderConfig{}},
+		{"extension wildcard", FolderSettings{BlockedExtensions: []string{"*"}}, true, FolderConfig{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := "mem://filetype-test-" + tt.name
+			writeTestConfig(t, root, "a", &FolderConfig{})
+			folder, err := GetFolder(root, "a")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = folder.SetSettings(&tt.settings)
+			if _, invalid := err.(*ErrInvalidFolderSetting); invalid != tt.wantErr || (err != nil && !invalid) {
+				t.Fatalf("got error %v, want invalid setting = %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := FolderConfig{
+				AllowedTypes:      folder.Config.AllowedTypes,
+				BlockedTypes:      folder.Config.BlockedTypes,
+				AllowedExtensions: folder.Config.AllowedExtensions,
+				BlockedExtensions: folder.Config.BlockedExtensions,
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
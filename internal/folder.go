@@ -2,11 +2,11 @@ package internal
 
 import (
 	"encoding/json"
-	"io/ioutil"
 	"log"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/nbutton23/zxcvbn-go"
 	"github.com/razzie/beepboop"
@@ -14,12 +14,49 @@ import (
 
 // FolderConfig stores the folder's passwords and other congfiguration
 type FolderConfig struct {
-	Salt            string `json:"salt"`
-	ReadPassword    string `json:"read_pw"`
-	WritePassword   string `json:"write_pw"`
-	MaxFileSizeMB   int64  `json:"max_file_size"`
-	MaxFolderSizeMB int64  `json:"max_folder_size"`
-	Subfolders      bool   `json:"subfolders"`
+	Salt                    string       `json:"salt,omitempty"` // only used by legacy SHA-1 password hashes
+	ReadPassword            string       `json:"read_pw"`
+	ReadPasswordAlgorithm   string       `json:"read_pw_algorithm,omitempty"` // empty means legacy SHA-1
+	ReadAccessCode          string       `json:"read_access_code,omitempty"`
+	WritePassword           string       `json:"write_pw"`
+	WritePasswordAlgorithm  string       `json:"write_pw_algorithm,omitempty"` // empty means legacy SHA-1
+	WriteAccessCode         string       `json:"write_access_code,omitempty"`
+	UploadPassword          string       `json:"upload_pw,omitempty"` // permits uploads only (drop box)
+	UploadPasswordAlgorithm string       `json:"upload_pw_algorithm,omitempty"`
+	UploadAccessCode        string       `json:"upload_access_code,omitempty"`
+	MaxFileSizeMB           int64        `json:"max_file_size"`
+	MaxFolderSizeMB         int64        `json:"max_folder_size"`
+	Subfolders              bool         `json:"subfolders"`
+	APITokens               []*APIToken  `json:"api_tokens,omitempty"`
+	Members                 []*Member    `json:"members,omitempty"`
+	TrashRetentionDays      int          `json:"trash_retention_days,omitempty"` // 0 = default, negative = no trash
+	MaxFileVersions         int          `json:"max_file_versions,omitempty"`    // 0 = default, negative = no versions
+	Dedup                   bool         `json:"dedup,omitempty"`                // store file contents in the deduplicated blob store
+	ShareSecret             string       `json:"share_secret,omitempty"`         // HMAC key of share tokens
+	ShareLinks              []*ShareLink `json:"share_links,omitempty"`
+	ParentManaged           bool         `json:"parent_managed,omitempty"`     // write access to the parent folder permits managing this folder
+	SharedQuota             bool         `json:"shared_quota,omitempty"`       // the folder also counts towards the size limit of the parent folder
+	AllowedTypes            []string     `json:"allowed_types,omitempty"`      // MIME types like image/png or image/* (empty = all)
+	BlockedTypes            []string     `json:"blocked_types,omitempty"`      // MIME types like text/html or application/*
+	AllowedExtensions       []string     `json:"allowed_extensions,omitempty"` // file extensions without the dot (empty = all)
+	BlockedExtensions       []string     `json:"blocked_extensions,omitempty"`
+}
+
+func readFolderConfig(s Storage, relPath string) (*FolderConfig, error) {
+	data, err := s.ReadFile(path.Join(relPath, ".razbox"))
+	if err != nil {
+		return nil, err
+	}
+	config := new(FolderConfig)
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// AccessProvider provides the access codes of a requester (like a beepboop.Session)
+type AccessProvider interface {
+	GetAccessCode(accessType, resource string) (string, bool)
 }
 
 // Folder ...
@@ -31,33 +68,40 @@ type Folder struct {
 	ConfigRootFolder string       `json:"config_root"`
 	CachedSubfolders []string     `json:"cached_subfolders"`
 	CachedFiles      []*File      `json:"cached_files"`
+	Fence            uint64       `json:"fence,omitempty"`
+	CacheVersion     uint64       `json:"cache_version,omitempty"`
+	parent           *Folder
 }
 
 // GetFolder returns a new Folder from a handle to a .razbox file
 func GetFolder(root, relPath string) (*Folder, error) {
 	relPath = path.Clean(relPath)
-	searchPath := path.Join(root, relPath)
+	searchPath := relPath
+	s := storage(root)
 	var data []byte
 	var configInherited bool
 	var configFound bool
 	var configRoot string
 
-	for len(searchPath) >= len(root) {
-		data, _ = ioutil.ReadFile(path.Join(searchPath, ".razbox"))
+	for {
+		data, _ = s.ReadFile(path.Join(searchPath, ".razbox"))
 		if data != nil {
 			configFound = true
 			break
 		}
+		if searchPath == "." || searchPath == "/" {
+			break
+		}
 		configInherited = true
-		searchPath = path.Join(searchPath, "..")
+		searchPath = path.Dir(searchPath)
 	}
 
 	if !configFound {
 		return nil, &ErrFolderConfigNotFound{Folder: relPath}
 	}
 
-	if len(searchPath) > len(root) {
-		configRoot = searchPath[len(root)+1:]
+	if searchPath != "." && searchPath != "/" {
+		configRoot = searchPath
 	} else {
 		configRoot = relPath
 	}
@@ -79,6 +123,28 @@ func GetFolder(root, relPath string) (*Folder, error) {
 	return folder, nil
 }
 
+// getParent returns the folder that contains the config root of this folder (or nil)
+func (f *Folder) getParent() *Folder {
+	if f.parent == nil && f.ConfigRootFolder != "." {
+		f.parent, _ = GetFolder(f.Root, path.Dir(f.ConfigRootFolder))
+	}
+	return f.parent
+}
+
+// hasParentAccess returns whether the folder is managed by its parent and the access token permits writing it
+func (f *Folder) hasParentAccess(sess AccessProvider) bool {
+	if !f.Config.ParentManaged {
+		return false
+	}
+	parent := f.getParent()
+	return parent != nil && parent.EnsureWriteAccess(sess) == nil
+}
+
+// GetStorage returns the storage of the folder
+func (f *Folder) GetStorage() Storage {
+	return storage(f.Root)
+}
+
 // GetFile returns the file in the folder with the given basename
 func (f *Folder) GetFile(basename string) (*File, error) {
 	for _, f := range f.CachedFiles {
@@ -118,9 +184,8 @@ func (f *Folder) GetFiles() []*File {
 		return f.CachedFiles
 	}
 
-	filenames, _ := filepath.Glob(path.Join(f.Root, f.RelPath, "????????-????-????-????-????????????.json"))
+	filenames := globStorage(storage(f.Root), f.RelPath, "????????-????-????-????-????????????.json")
 	for _, filename := range filenames {
-		filename = filename[len(f.Root)+1:]
 		file, err := getFile(f.Root, filename[:len(filename)-5]) // - .json
 		if err != nil {
 			log.Print("GetFile error:", err)
@@ -158,9 +223,9 @@ func (f *Folder) GetSubfolders() []string {
 		return f.CachedSubfolders
 	}
 
-	files, _ := ioutil.ReadDir(path.Join(f.Root, f.RelPath))
+	files, _ := storage(f.Root).List(f.RelPath)
 	for _, fi := range files {
-		if fi.IsDir() {
+		if fi.IsDir() && !IsReservedPath(fi.Name()) {
 			f.CachedSubfolders = append(f.CachedSubfolders, fi.Name())
 		}
 	}
@@ -179,7 +244,7 @@ func (f *Folder) Search(tag string) []*File {
 	return results
 }
 
-// SetPasswords generates a random salt and sets and read and write passwords
+// SetPasswords sets the read and write passwords
 func (f *Folder) SetPasswords(readPw, writePw string) error {
 	if f.ConfigInherited {
 		return &ErrInheritedConfigPasswordChange{}
@@ -189,17 +254,15 @@ func (f *Folder) SetPasswords(readPw, writePw string) error {
 		return &ErrReadWritePasswordMatch{}
 	}
 
-	f.Config.Salt = Salt()
-	if len(readPw) == 0 {
-		f.Config.ReadPassword = ""
-	} else {
-		f.Config.ReadPassword = Hash(f.Config.Salt + readPw)
+	read, _ := f.Config.getPassword("read")
+	if err := read.set(readPw); err != nil {
+		return err
 	}
-	if len(writePw) == 0 {
-		f.Config.WritePassword = ""
-	} else {
-		f.Config.WritePassword = Hash(f.Config.Salt + writePw)
+	write, _ := f.Config.getPassword("write")
+	if err := write.set(writePw); err != nil {
+		return err
 	}
+	f.Config.Salt = ""
 
 	return f.save()
 }
@@ -210,14 +273,16 @@ func (f *Folder) SetReadPassword(readPw string) error {
 		return &ErrInheritedConfigPasswordChange{}
 	}
 
-	if len(readPw) == 0 {
-		f.Config.ReadPassword = ""
-	} else {
-		hash := Hash(f.Config.Salt + readPw)
-		if hash == f.Config.WritePassword {
-			return &ErrReadWritePasswordMatch{}
-		}
-		f.Config.ReadPassword = hash
+	if len(readPw) > 0 && f.TestWritePassword(readPw) {
+		return &ErrReadWritePasswordMatch{}
+	}
+	if len(readPw) > 0 && f.TestUploadPassword(readPw) {
+		return &ErrUploadPasswordMatch{}
+	}
+
+	read, _ := f.Config.getPassword("read")
+	if err := read.set(readPw); err != nil {
+		return err
 	}
 
 	return f.save()
@@ -234,11 +299,36 @@ func (f *Folder) SetWritePassword(writePw string) error {
 		return &ErrPasswordScoreTooLow{Score: pwtest.Score}
 	}
 
-	hash := Hash(f.Config.Salt + writePw)
-	if hash == f.Config.ReadPassword {
+	if len(f.Config.ReadPassword) > 0 && f.TestReadPassword(writePw) {
 		return &ErrReadWritePasswordMatch{}
 	}
-	f.Config.WritePassword = hash
+	if f.TestUploadPassword(writePw) {
+		return &ErrUploadPasswordMatch{}
+	}
+
+	write, _ := f.Config.getPassword("write")
+	if err := write.set(writePw); err != nil {
+		return err
+	}
+
+	return f.save()
+}
+
+// SetUploadPassword sets the password that only permits uploading files to the folder
+// (an empty password disables upload-only access)
+func (f *Folder) SetUploadPassword(uploadPw string) error {
+	if f.ConfigInherited {
+		return &ErrInheritedConfigPasswordChange{}
+	}
+
+	if len(uploadPw) > 0 && (f.TestReadPassword(uploadPw) || f.TestWritePassword(uploadPw)) {
+		return &ErrUploadPasswordMatch{}
+	}
+
+	upload, _ := f.Config.getPassword("upload")
+	if err := upload.set(uploadPw); err != nil {
+		return err
+	}
 
 	return f.save()
 }
@@ -250,6 +340,8 @@ func (f *Folder) SetPassword(accessType, pw string) error {
 		return f.SetReadPassword(pw)
 	case "write":
 		return f.SetWritePassword(pw)
+	case "upload":
+		return f.SetUploadPassword(pw)
 	default:
 		return &ErrInvalidAccessType{AccessType: accessType}
 	}
@@ -261,17 +353,46 @@ func (f *Folder) save() error {
 	}
 
 	data, _ := json.MarshalIndent(&f.Config, "", "  ")
-	return ioutil.WriteFile(path.Join(f.Root, f.RelPath, ".razbox"), data, 0644)
+	return storage(f.Root).WriteFile(path.Join(f.RelPath, ".razbox"), data)
+}
+
+func (f *Folder) hasPasswordAccess(accessType string, sess AccessProvider) bool {
+	pw, _ := sess.GetAccessCode(accessType, FilenameToUUID(f.ConfigRootFolder))
+	code, _ := f.GetAccessCode(accessType)
+	return pw == code
+}
+
+// GetSessionPassword returns the access type of the strongest folder password that the access token carries
+// (or an empty string)
+func (f *Folder) GetSessionPassword(sess AccessProvider) string {
+	for _, accessType := range []string{"write", "upload", "read"} {
+		p, _ := f.Config.getPassword(accessType)
+		if len(*p.hash) > 0 && f.hasPasswordAccess(accessType, sess) {
+			return accessType
+		}
+	}
+	return ""
 }
 
 // EnsureReadAccess returns an error if the access token doesn't permit read access
-func (f *Folder) EnsureReadAccess(sess *beepboop.Session) error {
-	if len(f.Config.ReadPassword) == 0 {
+// (folders without a read password are public, unless they have members)
+func (f *Folder) EnsureReadAccess(sess AccessProvider) error {
+	if f.hasMemberAccess("read", sess) || f.hasParentAccess(sess) {
 		return nil
 	}
 
-	pw, _ := sess.GetAccessCode("read", FilenameToUUID(f.ConfigRootFolder))
-	if pw != f.Config.ReadPassword {
+	if len(f.Config.ReadPassword) == 0 {
+		if len(f.Config.Members) == 0 {
+			return nil
+		}
+		// members-only folders are still readable with the write password
+		if len(f.Config.WritePassword) > 0 && f.hasPasswordAccess("write", sess) {
+			return nil
+		}
+		return &ErrWrongPassword{}
+	}
+
+	if !f.hasPasswordAccess("read", sess) {
 		return &ErrWrongPassword{}
 	}
 
@@ -279,13 +400,59 @@ func (f *Folder) EnsureReadAccess(sess *beepboop.Session) error {
 }
 
 // EnsureWriteAccess returns an error if the access token doesn't permit write access
-func (f *Folder) EnsureWriteAccess(sess *beepboop.Session) error {
+func (f *Folder) EnsureWriteAccess(sess AccessProvider) error {
+	if f.hasMemberAccess("write", sess) || f.hasParentAccess(sess) {
+		return nil
+	}
+
 	if len(f.Config.WritePassword) == 0 {
+		if f.HasMembers("write") {
+			return &ErrWrongPassword{}
+		}
 		return &ErrFolderNotWritable{}
 	}
 
-	pw, _ := sess.GetAccessCode("write", FilenameToUUID(f.ConfigRootFolder))
-	if pw != f.Config.WritePassword {
+	if !f.hasPasswordAccess("write", sess) {
+		return &ErrWrongPassword{}
+	}
+
+	return nil
+}
+
+// EnsureUploadAccess returns an error if the access token doesn't permit upload-only access
+// (read and write access together also permit uploads, but this doesn't check them)
+func (f *Folder) EnsureUploadAccess(sess AccessProvider) error {
+	if f.hasMemberAccess("upload", sess) {
+		return nil
+	}
+
+	if len(f.Config.UploadPassword) == 0 {
+		if f.HasMembers("upload") {
+			return &ErrWrongPassword{}
+		}
+		return &ErrFolderNotUploadable{}
+	}
+
+	if !f.hasPasswordAccess("upload", sess) {
+		return &ErrWrongPassword{}
+	}
+
+	return nil
+}
+
+// EnsureAdminAccess returns an error if the access token doesn't permit managing the folder
+// (passwords, API tokens and members), which requires the write password or an admin member
+// (or write access to the parent folder if it manages this one)
+func (f *Folder) EnsureAdminAccess(sess AccessProvider) error {
+	if f.hasMemberAccess("admin", sess) || f.hasParentAccess(sess) {
+		return nil
+	}
+
+	if len(f.Config.WritePassword) == 0 {
+		return &ErrWrongPassword{}
+	}
+
+	if !f.hasPasswordAccess("write", sess) {
 		return &ErrWrongPassword{}
 	}
 
@@ -293,12 +460,14 @@ func (f *Folder) EnsureWriteAccess(sess *beepboop.Session) error {
 }
 
 // EnsureAccess returns an error if the access token doesn't permit access for the given access type
-func (f *Folder) EnsureAccess(accessType string, sess *beepboop.Session) error {
+func (f *Folder) EnsureAccess(accessType string, sess AccessProvider) error {
 	switch accessType {
 	case "read":
 		return f.EnsureReadAccess(sess)
 	case "write":
 		return f.EnsureWriteAccess(sess)
+	case "upload":
+		return f.EnsureUploadAccess(sess)
 	default:
 		return &ErrInvalidAccessType{AccessType: accessType}
 	}
@@ -309,12 +478,20 @@ func (f *Folder) TestReadPassword(readPw string) bool {
 	if len(f.Config.ReadPassword) == 0 && len(readPw) == 0 {
 		return true
 	}
-	return Hash(f.Config.Salt+readPw) == f.Config.ReadPassword
+	read, _ := f.Config.getPassword("read")
+	return read.test(f.Config.Salt, readPw)
 }
 
 // TestWritePassword returns true if the given password matches the write password
 func (f *Folder) TestWritePassword(writePw string) bool {
-	return Hash(f.Config.Salt+writePw) == f.Config.WritePassword
+	write, _ := f.Config.getPassword("write")
+	return write.test(f.Config.Salt, writePw)
+}
+
+// TestUploadPassword returns true if the given password matches the upload password
+func (f *Folder) TestUploadPassword(uploadPw string) bool {
+	upload, _ := f.Config.getPassword("upload")
+	return upload.test(f.Config.Salt, uploadPw)
 }
 
 // TestPassword returns true if the given password matches the password for the given access type
@@ -324,74 +501,104 @@ func (f *Folder) TestPassword(accessType, pw string) bool {
 		return f.TestReadPassword(pw)
 	case "write":
 		return f.TestWritePassword(pw)
+	case "upload":
+		return f.TestUploadPassword(pw)
 	default:
 		log.Print((&ErrInvalidAccessType{AccessType: accessType}).Error())
 		return false
 	}
 }
 
-// GetPasswordHash returns the password hash of the given access type
-func (f *Folder) GetPasswordHash(accessType string) (string, error) {
-	switch accessType {
-	case "read":
-		return f.Config.ReadPassword, nil
-	case "write":
-		return f.Config.WritePassword, nil
-	default:
-		return "", &ErrInvalidAccessType{AccessType: accessType}
+// GetAccessCode returns the code that access tokens of the given access type carry
+func (f *Folder) GetAccessCode(accessType string) (string, error) {
+	p, err := f.Config.getPassword(accessType)
+	if err != nil {
+		return "", err
 	}
+	return p.getAccessCode(), nil
 }
 
 // GetAccessToken returns an access token that permits access of the given access type
 func (f *Folder) GetAccessToken(accessType string) (beepboop.AccessMap, error) {
 	switch accessType {
 	case "read":
-		pw, _ := f.GetPasswordHash(accessType)
+		pw, _ := f.GetAccessCode(accessType)
 		token := make(beepboop.AccessMap)
 		token.Add("read", FilenameToUUID(f.ConfigRootFolder), pw)
 		return token, nil
 
 	case "write":
-		pw, _ := f.GetPasswordHash(accessType)
+		pw, _ := f.GetAccessCode(accessType)
 		token := make(beepboop.AccessMap)
 		token.Add("write", FilenameToUUID(f.ConfigRootFolder), pw)
 		return token, nil
 
+	case "upload":
+		pw, _ := f.GetAccessCode(accessType)
+		token := make(beepboop.AccessMap)
+		token.Add("upload", FilenameToUUID(f.ConfigRootFolder), pw)
+		return token, nil
+
 	default:
 		return nil, &ErrInvalidAccessType{AccessType: accessType}
 	}
 }
 
+// calcFolderStructureSizeMB returns the size of the config root folder and its subfolders,
+// except the ones that have their own config and don't share its quota
 func (f *Folder) calcFolderStructureSizeMB() int64 {
 	var sum int64
-	var excludes []string
-	sizes := make(map[string]int64)
-	filepath.Walk(path.Join(f.Root, f.ConfigRootFolder), func(p string, info os.FileInfo, err error) error {
-		if info.IsDir() {
+	counted := make(map[interface{}]bool) // hard links (of versions) are only charged once
+	contents := make(map[string]bool)     // files and versions with a non-empty .bin
+	var metadata []string
+	s := storage(f.Root)
+	walkStorage(s, f.ConfigRootFolder, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
 			return err
 		}
-		dir := path.Dir(p)
-		switch path.Ext(p) {
-		case ".razbox":
-			if path.Clean(dir) != f.ConfigRootFolder {
-				excludes = append(excludes)
+		if info.IsDir() {
+			if isInternalFolderName(info.Name()) {
+				return filepath.SkipDir // trashed files don't count towards the folder size
+			}
+			if path.Clean(p) != path.Clean(f.ConfigRootFolder) {
+				if config, err := readFolderConfig(s, p); err == nil && !config.SharedQuota {
+					return filepath.SkipDir
+				}
 			}
+			return nil
+		}
+		switch path.Ext(p) {
 		case ".bin":
-			sizes[dir] = sizes[dir] + info.Size()
+			if info.Size() > 0 {
+				contents[strings.TrimSuffix(p, ".bin")] = true
+			}
+			if id, ok := contentID(info); ok {
+				if counted[id] {
+					return nil
+				}
+				counted[id] = true
+			}
+			sum += info.Size()
+		case ".json":
+			metadata = append(metadata, strings.TrimSuffix(p, ".json"))
 		}
-		return err
+		return nil
 	})
-	for _, exclude := range excludes {
-		delete(sizes, exclude)
-	}
-	for _, size := range sizes {
-		sum += size
+
+	// deduplicated files are charged with their logical size,
+	// but only the files (and versions) without a content of their own can be deduplicated
+	for _, relPath := range metadata {
+		if contents[relPath] {
+			continue
+		}
+		if file, err := getFile(f.Root, relPath); err == nil && len(file.Blob) > 0 {
+			sum += file.Size
+		}
 	}
 	return sum >> 20
 }
 
-// GetMaxUploadSizeMB returns the maximum allowed upload size in MBs
-func (f *Folder) GetMaxUploadSizeMB() int64 {
+func (f *Folder) getMaxUploadSizeMB() int64 {
 	if f.Config.MaxFolderSizeMB > 0 {
 		size := f.calcFolderStructureSizeMB()
 		if size >= f.Config.MaxFolderSizeMB {
@@ -410,3 +617,20 @@ func (f *Folder) GetMaxUploadSizeMB() int64 {
 	}
 	return 1
 }
+
+// GetMaxUploadSizeMB returns the maximum allowed upload size in MBs
+// (subfolders that share the quota of their parent can't exceed its limits either)
+func (f *Folder) GetMaxUploadSizeMB() int64 {
+	size := f.getMaxUploadSizeMB()
+	if !f.Config.SharedQuota {
+		return size
+	}
+	parent := f.getParent()
+	if parent == nil || (parent.Config.MaxFolderSizeMB <= 0 && parent.Config.MaxFileSizeMB <= 0) {
+		return size
+	}
+	if parentSize := parent.GetMaxUploadSizeMB(); parentSize < size {
+		return parentSize
+	}
+	return size
+}
@@ -0,0 +1,126 @@
+package internal
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestFolderStructureSize(t *testing.T) {
+	tests := []struct {
+		name      string
+		dir       string        // where a 1 MB file is stored besides the one in the config root
+		configDir string        // subfolder that has a config of its own (if any)
+		config    *FolderConfig // config of configDir
+		wantMB    int64
+	}{
+		{"config root", "a", "", nil, 2},
+		{"subfolder", "a/sub", "", nil, 2},
+		{"subfolder with own quota", "a/sub", "a/sub", &FolderConfig{}, 1},
+		{"subfolder with shared quota", "a/sub", "a/sub", &FolderConfig{SharedQuota: true}, 2},
+		{"nested in subfolder with own quota", "a/sub/nested", "a/sub", &FolderConfig{}, 1},
+		{"nested in subfolder with shared quota", "a/sub/nested", "a/sub", &FolderConfig{SharedQuota: true}, 2},
+		{"trash", "a/" + TrashFolderName, "", nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := "mem://folder-size-test-" + tt.name
+			writeTestConfig(t, root, "a", &FolderConfig{MaxFolderSizeMB: 10})
+			if len(tt.configDir) > 0 {
+				writeTestConfig(t, root, tt.configDir, tt.config)
+			}
+			s, err := GetStorage(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			content := bytes.Repeat([]byte("x"), 1<<20)
+			for i, dir := range []string{"a", tt.dir} {
+				if err := s.MkdirAll(dir); err != nil {
+					t.Fatal(err)
+				}
+				if err := s.WriteFile(path.Join(dir, string(rune('0'+i))+".bin"), content); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			folder, err := GetFolder(root, "a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size := folder.calcFolderStructureSizeMB(); size != tt.wantMB {
+				t.Errorf("size = %d MB, want %d MB", size, tt.wantMB)
+			}
+		})
+	}
+}
+
+func TestFolderStructureSizeSharedContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		dedup    bool
+		hardLink bool
+		create   func(folder *Folder, newFile func(name string) *File, content func() *bytes.Reader) error
+		wantMB   int64
+	}{
+		{"hard linked version", false, true, func(folder *Folder, newFile func(string) *File, content func() *bytes.Reader) error {
+			file := newFile("a")
+			if err := folder.CreateFile(file, content(), false); err != nil {
+				return err
+			}
+			_, err := file.addVersion()
+			return err
+		}, 1},
+		{"overwritten version", false, false, func(folder *Folder, newFile func(string) *File, content func() *bytes.Reader) error {
+			if err := folder.CreateFile(newFile("a"), content(), false); err != nil {
+				return err
+			}
+			return folder.CreateFile(newFile("a"), content(), true)
+		}, 2},
+		// every file is charged with its logical size, even if the blob is shared
+		{"deduplicated copies", true, false, func(folder *Folder, newFile func(string) *File, content func() *bytes.Reader) error {
+			if err := folder.CreateFile(newFile("a"), content(), false); err != nil {
+				return err
+			}
+			return folder.CreateFile(newFile("b"), content(), false)
+		}, 2},
+		// the metadata is only read for files without a content of their own
+		{"file with content", false, false, func(folder *Folder, newFile func(string) *File, content func() *bytes.Reader) error {
+			file := newFile("a")
+			if err := folder.CreateFile(file, content(), false); err != nil {
+				return err
+			}
+			file.Blob = strings.Repeat("0", 64)
+			return file.Save()
+		}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			if info, err := os.Stat(root); err == nil && tt.hardLink {
+				if _, ok := contentID(info); !ok {
+					t.Skip("hard links aren't detected on this platform")
+				}
+			}
+			writeTestConfig(t, root, "f", &FolderConfig{MaxFolderSizeMB: 10, Dedup: tt.dedup})
+			folder, err := GetFolder(root, "f")
+			if err != nil {
+				t.Fatal(err)
+			}
+			newFile := func(name string) *File {
+				return &File{Name: name, Root: root, RelPath: path.Join("f", FilenameToUUID(name)), MIME: "text/plain"}
+			}
+			content := func() *bytes.Reader {
+				return bytes.NewReader(bytes.Repeat([]byte("x"), 1<<20))
+			}
+			if err := tt.create(folder, newFile, content); err != nil {
+				t.Fatal(err)
+			}
+
+			if size := folder.calcFolderStructureSizeMB(); size != tt.wantMB {
+				t.Errorf("size = %d MB, want %d MB", size, tt.wantMB)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// QuarantineFolderName is the name of the hidden directory in the root where fsck moves the orphaned files
+const QuarantineFolderName = ".quarantine"
+
+// FsckTempFileGracePeriod is how old a temporary file has to be before Fsck reports it,
+// so the temporary files of uploads in progress are left alone
+var FsckTempFileGracePeriod = time.Hour
+
+// Problems found by Fsck
+const (
+	FsckMissingContent   = "missing content"
+	FsckOrphanedContent  = "orphaned content"
+	FsckStaleThumbnail   = "stale thumbnail"
+	FsckTempFile         = "temporary file"
+	FsckMisnamedFile     = "misnamed file"
+	FsckInvalidMetadata  = "invalid metadata"
+	FsckBlobRefCount     = "wrong blob reference count"
+	FsckUnreferencedBlob = "unreferenced blob"
+)
+
+// FsckIssue is an inconsistency in the storage of a root
+type FsckIssue struct {
+	Problem string
+	Name    string // storage name of the affected object
+	Folder  string // folder that contains the object (empty for the blob store)
+	Detail  string
+	s       Storage
+	repair  func(dispose func(name string) error) error
+}
+
+func (i *FsckIssue) String() string {
+	if len(i.Detail) > 0 {
+		return fmt.Sprintf("%s: %s (%s)", i.Problem, i.Name, i.Detail)
+	}
+	return fmt.Sprintf("%s: %s", i.Problem, i.Name)
+}
+
+// Repair fixes the issue. Orphaned objects are moved to the quarantine directory
+// of the root if quarantine is true, otherwise they are deleted.
+func (i *FsckIssue) Repair(quarantine bool) error {
+	dispose := i.s.Remove
+	if quarantine {
+		dispose = func(name string) error {
+			return quarantineObject(i.s, name)
+		}
+	}
+	return i.repair(dispose)
+}
+
+func quarantineObject(s Storage, name string) error {
+	dst := path.Join(QuarantineFolderName, name)
+	if err := s.MkdirAll(path.Dir(dst)); err != nil {
+		return err
+	}
+	return s.Rename(name, dst)
+}
+
+type fsckChecker struct {
+	s        Storage
+	issues   []*FsckIssue
+	blobRefs map[string]int
+	blobs    []string
+}
+
+// Fsck walks the storage of a root and returns the inconsistencies it finds
+func Fsck(root string) ([]*FsckIssue, error) {
+	s, err := GetStorage(root)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := make(map[string]map[string]bool)
+	err = walkStorage(s, ".", func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == QuarantineFolderName {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		dir := path.Dir(name)
+		if dirs[dir] == nil {
+			dirs[dir] = make(map[string]bool)
+		}
+		dirs[dir][path.Base(name)] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &fsckChecker{
+		s:        s,
+		blobRefs: make(map[string]int),
+	}
+	dirNames := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		dirNames = append(dirNames, dir)
+	}
+	sort.Strings(dirNames)
+	for _, dir := range dirNames {
+		c.checkDir(dir, dirs[dir])
+	}
+	c.checkBlobs()
+	return c.issues, nil
+}
+
+func (c *fsckChecker) addIssue(problem, name, folder, detail string, repair func(dispose func(name string) error) error) {
+	c.issues = append(c.issues, &FsckIssue{
+		Problem: problem,
+		Name:    name,
+		Folder:  folder,
+		Detail:  detail,
+		s:       c.s,
+		repair:  repair,
+	})
+}
+
+func (c *fsckChecker) disposeAll(names ...string) func(dispose func(name string) error) error {
+	return func(dispose func(name string) error) error {
+		for _, name := range names {
+			if _, err := c.s.Stat(name); isNotExist(err) {
+				continue
+			}
+			if err := dispose(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (c *fsckChecker) removeAll(names ...string) func(dispose func(name string) error) error {
+	return func(func(name string) error) error {
+		return c.disposeAll(names...)(c.s.Remove)
+	}
+}
+
+func isVersionName(base string) bool {
+	i := strings.LastIndex(base, ".v")
+	return i > 0 && len(strings.Trim(base[i+2:], "0123456789")) == 0
+}
+
+func (c *fsckChecker) checkDir(dir string, names map[string]bool) {
+	folder := dir
+	inTrash := path.Base(dir) == TrashFolderName
+	if inTrash {
+		folder = path.Dir(dir)
+	}
+	if dir == BlobFolderName || strings.HasPrefix(dir, BlobFolderName+"/") {
+		c.checkBlobDir(dir, names)
+		return
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		full := path.Join(dir, name)
+		if strings.HasPrefix(name, "razbox-upload-") {
+			c.checkTempFile(full, folder)
+			continue
+		}
+
+		ext := path.Ext(name)
+		base := strings.TrimSuffix(name, ext)
+		switch ext {
+		case ".json":
+			c.checkMetadata(dir, folder, base, names, inTrash)
+		case ".bin":
+			if !names[base+".json"] {
+				c.addIssue(FsckOrphanedContent, full, folder, "no .json", c.disposeAll(full))
+			}
+		case ".thumb":
+			if !names[base+".json"] {
+				c.addIssue(FsckStaleThumbnail, full, folder, "", c.removeAll(full))
+			}
+		case ".part":
+			if !names[base+".tus"] {
+				c.addIssue(FsckOrphanedContent, full, folder, "no .tus", c.disposeAll(full))
+			}
+		case ".tus":
+			if !names[base+".part"] {
+				c.addIssue(FsckMissingContent, full, folder, "no .part", c.disposeAll(full))
+			}
+		}
+	}
+}
+
+// checkTempFile reports a temporary file unless it could still belong to an upload in progress
+func (c *fsckChecker) checkTempFile(name, folder string) {
+	info, err := c.s.Stat(name)
+	if err != nil || time.Since(info.ModTime()) < FsckTempFileGracePeriod {
+		return
+	}
+	c.addIssue(FsckTempFile, name, folder, "", c.removeAll(name))
+}
+
+func (c *fsckChecker) checkMetadata(dir, folder, base string, names map[string]bool, inTrash bool) {
+	relPath := path.Join(dir, base)
+	jsonName := relPath + ".json"
+	objects := []string{jsonName, relPath + ".bin", relPath + ".thumb"}
+
+	data, err := c.s.ReadFile(jsonName)
+	if err != nil {
+		c.addIssue(FsckInvalidMetadata, jsonName, folder, err.Error(), c.disposeAll(objects...))
+		return
+	}
+
+	var file *File
+	if inTrash && !isVersionName(base) {
+		var t TrashedFile
+		if err := json.Unmarshal(data, &t); err != nil || t.File == nil {
+			c.addIssue(FsckInvalidMetadata, jsonName, folder, "not a trashed file", c.disposeAll(objects...))
+			return
+		}
+		file = t.File
+	} else {
+		file = new(File)
+		if err := json.Unmarshal(data, file); err != nil || len(file.Name) == 0 {
+			c.addIssue(FsckInvalidMetadata, jsonName, folder, "not a file", c.disposeAll(objects...))
+			return
+		}
+	}
+
+	if len(file.Blob) > 0 {
+		if _, err := c.s.Stat(getBlobName(file.Blob)); err != nil {
+			c.addIssue(FsckMissingContent, jsonName, folder, "missing blob "+file.Blob, c.disposeAll(objects...))
+			return
+		}
+		c.blobRefs[file.Blob]++
+	} else if !names[base+".bin"] {
+		c.addIssue(FsckMissingContent, jsonName, folder, "no .bin", c.disposeAll(objects...))
+		return
+	}
+
+	if inTrash || isVersionName(base) {
+		return
+	}
+
+	if expected := FilenameToUUID(file.Name); base != expected {
+		detail := fmt.Sprintf("%q should be stored as %s", file.Name, expected)
+		c.addIssue(FsckMisnamedFile, jsonName, folder, detail, func(dispose func(name string) error) error {
+			return c.relink(file, relPath, path.Join(dir, expected), dispose, objects)
+		})
+	}
+}
+
+// relink moves a misnamed file (and its versions) to where GetFile looks for it,
+// or disposes it if another file already exists there
+func (c *fsckChecker) relink(file *File, oldRelPath, newRelPath string, dispose func(name string) error, objects []string) error {
+	if _, err := c.s.Stat(newRelPath + ".json"); err == nil {
+		return c.disposeAll(objects...)(dispose)
+	}
+
+	if len(file.Blob) == 0 {
+		if err := c.s.Rename(oldRelPath+".bin", newRelPath+".bin"); err != nil {
+			return err
+		}
+	}
+	_ = c.s.Rename(oldRelPath+".thumb", newRelPath+".thumb")
+	if err := moveVersions(c.s, oldRelPath, newRelPath); err != nil {
+		return err
+	}
+
+	file.RelPath = newRelPath
+	data, _ := json.MarshalIndent(file, "", "  ")
+	if err := c.s.WriteFile(newRelPath+".json", data); err != nil {
+		return err
+	}
+	return c.s.Remove(oldRelPath + ".json")
+}
+
+func (c *fsckChecker) checkBlobDir(dir string, names map[string]bool) {
+	for name := range names {
+		full := path.Join(dir, name)
+		switch {
+		case strings.HasPrefix(name, "tmp-"):
+			c.checkTempFile(full, "")
+		case len(path.Ext(name)) == 0:
+			c.blobs = append(c.blobs, name)
+		case path.Ext(name) == ".refs" && !names[strings.TrimSuffix(name, ".refs")]:
+			c.addIssue(FsckOrphanedContent, full, "", "no blob", c.removeAll(full))
+		}
+	}
+}
+
+func (c *fsckChecker) checkBlobs() {
+	sort.Strings(c.blobs)
+	for _, hash := range c.blobs {
+		hash := hash
+		blobName := getBlobName(hash)
+		refs := c.blobRefs[hash]
+		if refs == 0 {
+			c.addIssue(FsckUnreferencedBlob, blobName, "", "", c.disposeAll(blobName, blobName+".refs"))
+			continue
+		}
+		if stored := getBlobRefs(c.s, hash); stored != refs {
+			detail := fmt.Sprintf("%d instead of %d", stored, refs)
+			c.addIssue(FsckBlobRefCount, blobName, "", detail, func(func(name string) error) error {
+				blobLock.Lock()
+				defer blobLock.Unlock()
+				return setBlobRefs(c.s, hash, refs)
+			})
+		}
+	}
+}
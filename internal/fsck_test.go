@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFsckTempFileGracePeriod(t *testing.T) {
+	tests := []struct {
+		name        string
+		gracePeriod time.Duration
+		wantIssues  int
+	}{
+		{"in progress", time.Hour, 0},
+		{"abandoned", 0, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := "mem://fsck-test-" + tt.name
+			writeTestConfig(t, root, "a", &FolderConfig{})
+			s, err := GetStorage(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.MkdirAll(BlobFolderName); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"a/razbox-upload-1", BlobFolderName + "/tmp-1"} {
+				if err := s.WriteFile(name, []byte("temp")); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			defer func(gracePeriod time.Duration) { FsckTempFileGracePeriod = gracePeriod }(FsckTempFileGracePeriod)
+			FsckTempFileGracePeriod = tt.gracePeriod
+			issues, err := Fsck(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(issues) != tt.wantIssues {
+				t.Fatalf("found %v, want %d temporary files", issues, tt.wantIssues)
+			}
+			for _, issue := range issues {
+				if issue.Problem != FsckTempFile {
+					t.Errorf("unexpected issue: %v", issue)
+				}
+				if err := issue.Repair(false); err != nil {
+					t.Error(err)
+				}
+				if _, err := s.Stat(issue.Name); !isNotExist(err) {
+					t.Errorf("%s wasn't removed", issue.Name)
+				}
+			}
+		})
+	}
+}
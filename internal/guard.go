@@ -0,0 +1,141 @@
+package internal
+
+import (
+	"io"
+	"path"
+	"sync"
+	"sync/atomic"
+)
+
+// WriteGuard is called before the storage of a root changes anything in a guarded folder tree,
+// and it returns an error if the writer isn't allowed to write anymore (like when it lost the lease of the folder).
+// commit is true for the file metadata and folder configs, which make the changes visible.
+type WriteGuard func(commit bool) error
+
+type writeGuard struct {
+	guard WriteGuard
+}
+
+var (
+	writeGuardsMu    sync.RWMutex
+	writeGuards      = make(map[string]map[string]*writeGuard) // by root and folder
+	writeGuardsCount int32
+)
+
+// GuardWrites makes the storage of a root call the guard before it changes anything in the folder
+// or its subfolders (unless a subfolder has a guard of its own) until the returned function is called
+func GuardWrites(root, folder string, guard WriteGuard) (unguard func()) {
+	folder = path.Clean(folder)
+	g := &writeGuard{guard: guard}
+
+	writeGuardsMu.Lock()
+	defer writeGuardsMu.Unlock()
+	if writeGuards[root] == nil {
+		writeGuards[root] = make(map[string]*writeGuard)
+	}
+	writeGuards[root][folder] = g
+	atomic.AddInt32(&writeGuardsCount, 1)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			writeGuardsMu.Lock()
+			defer writeGuardsMu.Unlock()
+			if writeGuards[root][folder] == g {
+				delete(writeGuards[root], folder)
+			}
+			atomic.AddInt32(&writeGuardsCount, -1)
+		})
+	}
+}
+
+// checkWriteGuard calls the guard of the folder tree that contains the given name (if any)
+func checkWriteGuard(root, name string) error {
+	if atomic.LoadInt32(&writeGuardsCount) == 0 {
+		return nil
+	}
+
+	writeGuardsMu.RLock()
+	guards := writeGuards[root]
+	var g *writeGuard
+	for dir := path.Dir(path.Clean(name)); len(guards) > 0; dir = path.Dir(dir) {
+		if g = guards[dir]; g != nil || dir == "." || dir == "/" {
+			break
+		}
+	}
+	writeGuardsMu.RUnlock()
+
+	if g == nil {
+		return nil
+	}
+	return g.guard(isCommitName(name))
+}
+
+// isCommitName returns whether a stored object is file metadata or a folder config
+func isCommitName(name string) bool {
+	return path.Ext(name) == ".json" || path.Base(name) == ".razbox"
+}
+
+// guardedStorage checks the write guards of a root before changing anything
+type guardedStorage struct {
+	Storage
+	root string
+}
+
+func (s *guardedStorage) check(names ...string) error {
+	for _, name := range names {
+		if err := checkWriteGuard(s.root, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *guardedStorage) Write(name string, content io.Reader) (int64, error) {
+	if err := s.check(name); err != nil {
+		return 0, err
+	}
+	return s.Storage.Write(name, content)
+}
+
+func (s *guardedStorage) Append(name string, content io.Reader) (int64, error) {
+	if err := s.check(name); err != nil {
+		return 0, err
+	}
+	return s.Storage.Append(name, content)
+}
+
+func (s *guardedStorage) WriteFile(name string, data []byte) error {
+	if err := s.check(name); err != nil {
+		return err
+	}
+	return s.Storage.WriteFile(name, data)
+}
+
+func (s *guardedStorage) Link(oldname, newname string) error {
+	if err := s.check(newname); err != nil {
+		return err
+	}
+	return s.Storage.Link(oldname, newname)
+}
+
+func (s *guardedStorage) Rename(oldname, newname string) error {
+	if err := s.check(oldname, newname); err != nil {
+		return err
+	}
+	return s.Storage.Rename(oldname, newname)
+}
+
+func (s *guardedStorage) Remove(name string) error {
+	if err := s.check(name); err != nil {
+		return err
+	}
+	return s.Storage.Remove(name)
+}
+
+func (s *guardedStorage) MkdirAll(dir string) error {
+	if err := s.check(path.Join(dir, ".")); err != nil {
+		return err
+	}
+	return s.Storage.MkdirAll(dir)
+}
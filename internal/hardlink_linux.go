@@ -0,0 +1,18 @@
+package internal
+
+import (
+	"os"
+	"syscall"
+)
+
+type inode struct {
+	dev, ino uint64
+}
+
+// contentID returns an identifier of the content of a local file, which is the same for its hard links
+func contentID(info os.FileInfo) (interface{}, bool) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return inode{dev: uint64(st.Dev), ino: st.Ino}, true
+	}
+	return nil, false
+}
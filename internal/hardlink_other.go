@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package internal
+
+import (
+	"os"
+)
+
+// contentID returns an identifier of the content of a local file (hard links aren't detected on this platform)
+func contentID(info os.FileInfo) (interface{}, bool) {
+	return nil, false
+}
@@ -0,0 +1,190 @@
+package internal
+
+import (
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"github.com/nbutton23/zxcvbn-go"
+	"github.com/razzie/beepboop"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Member roles
+const (
+	RoleViewer   = "viewer"   // read
+	RoleUploader = "uploader" // read + upload (without replacing or modifying files)
+	RoleEditor   = "editor"   // read + write
+	RoleAdmin    = "admin"    // read + write + managing passwords, API tokens and members
+)
+
+// Member is a named user of a folder with its own password and role
+type Member struct {
+	Name       string    `json:"name"`
+	Password   string    `json:"password"` // bcrypt hash
+	AccessCode string    `json:"access_code"`
+	Role       string    `json:"role"`
+	Created    time.Time `json:"created"`
+}
+
+func isValidRole(role string) bool {
+	switch role {
+	case RoleViewer, RoleUploader, RoleEditor, RoleAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+// HasAccess returns whether the role of the member permits the given access type
+// ("read", "upload", "write" or "admin")
+func (m *Member) HasAccess(accessType string) bool {
+	switch accessType {
+	case "read":
+		return isValidRole(m.Role)
+	case "upload":
+		return m.Role == RoleUploader || m.Role == RoleEditor || m.Role == RoleAdmin
+	case "write":
+		return m.Role == RoleEditor || m.Role == RoleAdmin
+	case "admin":
+		return m.Role == RoleAdmin
+	default:
+		return false
+	}
+}
+
+// HasMembers returns whether the folder has any members with a role that permits the given access type
+func (f *Folder) HasMembers(accessType string) bool {
+	for _, m := range f.Config.Members {
+		if m.HasAccess(accessType) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetMember returns the member with the given name
+func (f *Folder) GetMember(name string) (*Member, error) {
+	for _, m := range f.Config.Members {
+		if m.Name == name {
+			return m, nil
+		}
+	}
+	return nil, &ErrMemberNotFound{Name: name}
+}
+
+// GetSessionMember returns the member who the access token belongs to (or nil)
+func (f *Folder) GetSessionMember(sess AccessProvider) *Member {
+	code, ok := sess.GetAccessCode("member", FilenameToUUID(f.ConfigRootFolder))
+	if !ok {
+		return nil
+	}
+	i := strings.LastIndex(code, ":")
+	if i < 0 {
+		return nil
+	}
+	m, err := f.GetMember(code[:i])
+	if err != nil || subtle.ConstantTimeCompare([]byte(code[i+1:]), []byte(m.AccessCode)) != 1 {
+		return nil
+	}
+	return m
+}
+
+func (f *Folder) hasMemberAccess(accessType string, sess AccessProvider) bool {
+	m := f.GetSessionMember(sess)
+	return m != nil && m.HasAccess(accessType)
+}
+
+// AddMember adds a new member to the folder
+func (f *Folder) AddMember(name, pw, role string) error {
+	if f.ConfigInherited {
+		return &ErrInheritedConfigChange{}
+	}
+
+	if len(name) == 0 || strings.ContainsAny(name, ":/") {
+		return &ErrInvalidMemberName{Name: name}
+	}
+
+	if !isValidRole(role) {
+		return &ErrInvalidRole{Role: role}
+	}
+
+	if m, _ := f.GetMember(name); m != nil {
+		return &ErrMemberAlreadyExists{Name: name}
+	}
+
+	pwtest := zxcvbn.PasswordStrength(pw, []string{name, f.RelPath})
+	if pwtest.Score < 3 {
+		return &ErrPasswordScoreTooLow{Score: pwtest.Score}
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(pw), BcryptCost)
+	if err != nil {
+		return err
+	}
+	accessCode, err := newAccessCode()
+	if err != nil {
+		return err
+	}
+
+	f.Config.Members = append(f.Config.Members, &Member{
+		Name:       name,
+		Password:   string(hash),
+		AccessCode: accessCode,
+		Role:       role,
+		Created:    time.Now(),
+	})
+	return f.save()
+}
+
+// SetMemberRole changes the role of a member
+func (f *Folder) SetMemberRole(name, role string) error {
+	if f.ConfigInherited {
+		return &ErrInheritedConfigChange{}
+	}
+
+	if !isValidRole(role) {
+		return &ErrInvalidRole{Role: role}
+	}
+
+	m, err := f.GetMember(name)
+	if err != nil {
+		return err
+	}
+	m.Role = role
+	return f.save()
+}
+
+// RemoveMember removes a member (which invalidates the access tokens of the member)
+func (f *Folder) RemoveMember(name string) error {
+	if f.ConfigInherited {
+		return &ErrInheritedConfigChange{}
+	}
+
+	for i, m := range f.Config.Members {
+		if m.Name == name {
+			f.Config.Members = append(f.Config.Members[:i], f.Config.Members[i+1:]...)
+			return f.save()
+		}
+	}
+	return &ErrMemberNotFound{Name: name}
+}
+
+// TestMemberPassword returns the member if the given password matches the password of the member
+func (f *Folder) TestMemberPassword(name, pw string) (*Member, bool) {
+	m, err := f.GetMember(name)
+	if err != nil {
+		return nil, false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(m.Password), []byte(pw)) != nil {
+		return nil, false
+	}
+	return m, true
+}
+
+// GetMemberAccessToken returns an access token that permits the access of the member's role
+func (f *Folder) GetMemberAccessToken(m *Member) beepboop.AccessMap {
+	token := make(beepboop.AccessMap)
+	token.Add("member", FilenameToUUID(f.ConfigRootFolder), m.Name+":"+m.AccessCode)
+	return token
+}
@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"path"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashing algorithms
+const (
+	PasswordAlgorithmSHA1   = "sha1" // legacy: one round of SHA-1 over salt + password
+	PasswordAlgorithmBcrypt = "bcrypt"
+)
+
+// BcryptCost is the cost of new bcrypt password hashes
+var BcryptCost = 12
+
+// folderPassword points to the config fields of a password
+type folderPassword struct {
+	hash       *string
+	algorithm  *string
+	accessCode *string
+}
+
+func (c *FolderConfig) getPassword(accessType string) (*folderPassword, error) {
+	switch accessType {
+	case "read":
+		return &folderPassword{&c.ReadPassword, &c.ReadPasswordAlgorithm, &c.ReadAccessCode}, nil
+	case "write":
+		return &folderPassword{&c.WritePassword, &c.WritePasswordAlgorithm, &c.WriteAccessCode}, nil
+	case "upload":
+		return &folderPassword{&c.UploadPassword, &c.UploadPasswordAlgorithm, &c.UploadAccessCode}, nil
+	default:
+		return nil, &ErrInvalidAccessType{AccessType: accessType}
+	}
+}
+
+func newAccessCode() (string, error) {
+	var code [20]byte
+	if _, err := rand.Read(code[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(code[:]), nil
+}
+
+// set hashes a new password and generates a new access code (which invalidates the existing access tokens)
+func (p *folderPassword) set(pw string) error {
+	if len(pw) == 0 {
+		*p.hash, *p.algorithm, *p.accessCode = "", "", ""
+		return nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(pw), BcryptCost)
+	if err != nil {
+		return err
+	}
+	accessCode, err := newAccessCode()
+	if err != nil {
+		return err
+	}
+	*p.hash, *p.algorithm, *p.accessCode = string(hash), PasswordAlgorithmBcrypt, accessCode
+	return nil
+}
+
+// upgrade rehashes a legacy password while keeping its access code, so existing access tokens keep working
+func (p *folderPassword) upgrade(pw string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(pw), BcryptCost)
+	if err != nil {
+		return err
+	}
+	*p.accessCode = p.getAccessCode()
+	*p.hash, *p.algorithm = string(hash), PasswordAlgorithmBcrypt
+	return nil
+}
+
+func (p *folderPassword) test(salt, pw string) bool {
+	if len(*p.hash) == 0 {
+		return false
+	}
+	switch *p.algorithm {
+	case PasswordAlgorithmBcrypt:
+		return bcrypt.CompareHashAndPassword([]byte(*p.hash), []byte(pw)) == nil
+	case PasswordAlgorithmSHA1, "":
+		return subtle.ConstantTimeCompare([]byte(Hash(salt+pw)), []byte(*p.hash)) == 1
+	default:
+		return false
+	}
+}
+
+func (p *folderPassword) isLegacy() bool {
+	return len(*p.hash) > 0 && *p.algorithm != PasswordAlgorithmBcrypt
+}
+
+// getAccessCode returns the code carried by access tokens
+// (legacy passwords use their hash as access code)
+func (p *folderPassword) getAccessCode() string {
+	if len(*p.accessCode) > 0 {
+		return *p.accessCode
+	}
+	return *p.hash
+}
+
+// NeedsPasswordUpgrade returns whether the password of the given access type uses a legacy hash
+func (f *Folder) NeedsPasswordUpgrade(accessType string) bool {
+	p, err := f.Config.getPassword(accessType)
+	return err == nil && p.isLegacy()
+}
+
+// UpgradePassword rehashes a legacy password with bcrypt and saves the config (even if it's inherited from a parent folder).
+// The config is reloaded first, so the folder has to be locked for writing, and the password is tested again
+// in case it changed since the folder was read.
+func (f *Folder) UpgradePassword(accessType, pw string) error {
+	config, err := readFolderConfig(storage(f.Root), f.ConfigRootFolder)
+	if err != nil {
+		return err
+	}
+	p, err := config.getPassword(accessType)
+	if err != nil {
+		return err
+	}
+	if !p.isLegacy() || !p.test(config.Salt, pw) {
+		return nil
+	}
+	if err := p.upgrade(pw); err != nil {
+		return err
+	}
+	f.Config = *config
+	return f.saveConfigRoot()
+}
+
+func (f *Folder) saveConfigRoot() error {
+	data, _ := json.MarshalIndent(&f.Config, "", "  ")
+	return storage(f.Root).WriteFile(path.Join(f.ConfigRootFolder, ".razbox"), data)
+}
@@ -0,0 +1,184 @@
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func init() {
+	BcryptCost = bcrypt.MinCost
+}
+
+func writeTestConfig(t *testing.T, root, relPath string, config *FolderConfig) {
+	t.Helper()
+	s, err := GetStorage(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.MkdirAll(relPath); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(config)
+	if err := s.WriteFile(relPath+"/.razbox", data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func legacyConfig(readPw string) *FolderConfig {
+	return &FolderConfig{
+		Salt:          "salt",
+		ReadPassword:  Hash("salt" + readPw),
+		WritePassword: Hash("salt" + "write"),
+	}
+}
+
+func TestUpgradePassword(t *testing.T) {
+	tests := []struct {
+		name         string
+		changed      *FolderConfig // the config saved after the folder was read
+		wantUpgraded bool
+		wantMaxSize  int64
+	}{
+		{"unchanged", nil, true, 0},
+		{"other setting changed", func() *FolderConfig {
+			c := legacyConfig("pw")
+			c.MaxFolderSizeMB = 42
+			return c
+		}(), true, 42},
+		{"password changed", legacyConfig("new"), false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := "mem://password-test-" + tt.name
+			writeTestConfig(t, root, "a", legacyConfig("pw"))
+			folder, err := GetFolder(root, "a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !folder.TestPassword("read", "pw") || !folder.NeedsPasswordUpgrade("read") {
+				t.Fatal("the legacy password doesn't work")
+			}
+			accessCode := folder.Config.ReadPassword // legacy access tokens carry the hash
+			if tt.changed != nil {
+				writeTestConfig(t, root, "a", tt.changed)
+			}
+
+			if err := folder.UpgradePassword("read", "pw"); err != nil {
+				t.Fatal(err)
+			}
+
+			saved, err := GetFolder(root, "a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if upgraded := !saved.NeedsPasswordUpgrade("read"); upgraded != tt.wantUpgraded {
+				t.Errorf("upgraded = %t, want %t", upgraded, tt.wantUpgraded)
+			}
+			if saved.Config.MaxFolderSizeMB != tt.wantMaxSize {
+				t.Errorf("max folder size = %d, want %d (a concurrent change was lost)",
+					saved.Config.MaxFolderSizeMB, tt.wantMaxSize)
+			}
+			if !tt.wantUpgraded {
+				return
+			}
+			if !saved.TestPassword("read", "pw") {
+				t.Error("the upgraded password doesn't work")
+			}
+			if !saved.NeedsPasswordUpgrade("write") {
+				t.Error("the write password was upgraded too")
+			}
+			if saved.Config.ReadAccessCode != accessCode {
+				t.Error("the upgrade changed the access code")
+			}
+		})
+	}
+}
+
+func TestFolderPassword(t *testing.T) {
+	bcryptConfig := func() *FolderConfig {
+		config := new(FolderConfig)
+		for accessType, pw := range map[string]string{"read": "pw", "write": "write"} {
+			p, _ := config.getPassword(accessType)
+			if err := p.set(pw); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return config
+	}
+	unknownAlgorithm := legacyConfig("pw")
+	unknownAlgorithm.ReadPasswordAlgorithm = "md5"
+
+	tests := []struct {
+		name       string
+		config     *FolderConfig
+		accessType string
+		pw         string
+		want       bool
+	}{
+		{"legacy", legacyConfig("pw"), "read", "pw", true},
+		{"legacy wrong password", legacyConfig("pw"), "read", "wrong", false},
+		{"legacy write", legacyConfig("pw"), "write", "write", true},
+		{"bcrypt", bcryptConfig(), "read", "pw", true},
+		{"bcrypt wrong password", bcryptConfig(), "read", "wrong", false},
+		{"bcrypt other access type", bcryptConfig(), "write", "pw", false},
+		{"no read password", &FolderConfig{}, "read", "", true}, // public folder
+		{"no write password", &FolderConfig{}, "write", "", false},
+		{"no upload password", legacyConfig("pw"), "upload", "", false},
+		{"unknown algorithm", unknownAlgorithm, "read", "pw", false},
+		{"invalid access type", legacyConfig("pw"), "admin", "pw", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			folder := &Folder{Config: *tt.config}
+			if got := folder.TestPassword(tt.accessType, tt.pw); got != tt.want {
+				t.Errorf("TestPassword = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetPasswordsChangesAccessCodes(t *testing.T) {
+	tests := []struct {
+		name           string
+		readPw         string
+		writePw        string
+		wantErr        bool
+		wantReadAccess bool // whether read access tokens still work after the change
+	}{
+		{"new passwords", "new", "newwrite", false, false},
+		{"read password removed", "", "newwrite", false, false},
+		{"same passwords", "same", "same", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := "mem://password-test-access-" + tt.name
+			writeTestConfig(t, root, "a", &FolderConfig{})
+			folder, err := GetFolder(root, "a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := folder.SetPasswords("read", "write"); err != nil {
+				t.Fatal(err)
+			}
+			accessCode, _ := folder.GetAccessCode("read")
+
+			err = folder.SetPasswords(tt.readPw, tt.writePw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error = %t", err, tt.wantErr)
+			}
+			saved, err := GetFolder(root, "a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			newCode, _ := saved.GetAccessCode("read")
+			if kept := newCode == accessCode; kept != tt.wantReadAccess {
+				t.Errorf("access code kept = %t, want %t", kept, tt.wantReadAccess)
+			}
+			if !tt.wantErr && !saved.TestPassword("write", tt.writePw) {
+				t.Error("the new write password doesn't work")
+			}
+		})
+	}
+}
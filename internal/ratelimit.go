@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter limits the number of requests per minute (beepboop.DB implements it with Redis)
+type RateLimiter interface {
+	IsWithinRateLimit(reqType, ip string, rate int) (bool, error)
+}
+
+// MemoryRateLimiter is an in-process RateLimiter with a token bucket for every request type and IP.
+// A bucket holds as many tokens as the rate, and it refills completely in a minute.
+type MemoryRateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewMemoryRateLimiter returns a new MemoryRateLimiter
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{
+		buckets:   make(map[string]*tokenBucket),
+		lastPrune: time.Now(),
+	}
+}
+
+// IsWithinRateLimit takes a token from the bucket of the request type and IP,
+// and returns whether there was any
+func (l *MemoryRateLimiter) IsWithinRateLimit(reqType, ip string, rate int) (bool, error) {
+	ok, _ := l.Take(reqType, ip, rate)
+	return ok, nil
+}
+
+// Take takes a token from the bucket of the request type and IP if there is any,
+// otherwise it returns the time until the bucket gets the next token
+func (l *MemoryRateLimiter) Take(reqType, ip string, rate int) (ok bool, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastPrune) > time.Minute {
+		l.prune(now)
+	}
+
+	key := reqType + ":" + ip
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(rate), updated: now}
+		l.buckets[key] = bucket
+	} else {
+		bucket.tokens += now.Sub(bucket.updated).Minutes() * float64(rate)
+		if bucket.tokens > float64(rate) {
+			bucket.tokens = float64(rate)
+		}
+		bucket.updated = now
+	}
+
+	if bucket.tokens < 1 {
+		if rate <= 0 {
+			return false, time.Minute
+		}
+		return false, time.Duration((1 - bucket.tokens) / float64(rate) * float64(time.Minute))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// prune drops the buckets that had time to refill completely, since they are the same as new ones
+func (l *MemoryRateLimiter) prune(now time.Time) {
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.updated) > time.Minute {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastPrune = now
+}
@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimiter(t *testing.T) {
+	tests := []struct {
+		name           string
+		rate           int
+		requests       int // number of requests before the checked one
+		elapsed        time.Duration
+		wantOK         bool
+		wantRetryAfter time.Duration
+	}{
+		{"first request", 3, 0, 0, true, 0},
+		{"within the rate", 3, 2, 0, true, 0},
+		{"exceeded", 3, 3, 0, false, 20 * time.Second},
+		{"exceeded with a faster rate", 60, 60, 0, false, time.Second},
+		{"partially refilled", 3, 3, 5 * time.Second, false, 15 * time.Second},
+		{"refilled", 3, 3, 20 * time.Second, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewMemoryRateLimiter()
+			for i := 0; i < tt.requests; i++ {
+				l.Take("auth", "127.0.0.1", tt.rate)
+			}
+			// the bucket is backdated instead of waiting
+			if bucket := l.buckets["auth:127.0.0.1"]; bucket != nil {
+				bucket.updated = bucket.updated.Add(-tt.elapsed)
+			}
+
+			ok, retryAfter := l.Take("auth", "127.0.0.1", tt.rate)
+			if ok != tt.wantOK {
+				t.Errorf("ok = %t, want %t", ok, tt.wantOK)
+			}
+			if diff := retryAfter - tt.wantRetryAfter; diff < -time.Millisecond*100 || diff > time.Millisecond*100 {
+				t.Errorf("retry after = %v, want %v", retryAfter, tt.wantRetryAfter)
+			}
+		})
+	}
+}
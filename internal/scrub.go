@@ -0,0 +1,132 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ScrubResult is the outcome of verifying a stored content against its recorded SHA-256
+type ScrubResult struct {
+	Name     string // storage name of the content
+	Folder   string // folder of the file (empty for blobs)
+	Filename string // name of the file (empty for blobs)
+	Expected string // recorded SHA-256 (empty if unknown)
+	Actual   string // SHA-256 of the stored content
+	Updated  bool   // the missing SHA-256 got recorded
+	Err      error
+}
+
+// OK returns whether the content matches its recorded SHA-256
+func (r *ScrubResult) OK() bool {
+	return r.Err == nil && r.Expected == r.Actual
+}
+
+func hashContent(s Storage, name string) (string, error) {
+	obj, err := s.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer obj.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, obj); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// Scrub re-hashes every stored file content (including versions, trashed files and blobs) under the root
+// and reports the results. Missing checksums can be recorded by ScrubFile (under the write lock of the folder).
+func Scrub(root string, report func(*ScrubResult)) error {
+	s, err := GetStorage(root)
+	if err != nil {
+		return err
+	}
+
+	return walkStorage(s, ".", func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			report(&ScrubResult{Name: name, Err: err})
+			return nil
+		}
+		if info.IsDir() {
+			if info.Name() == QuarantineFolderName {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		switch {
+		case path.Ext(name) == ".bin":
+			report(scrubFile(s, strings.TrimSuffix(name, ".bin"), false))
+		case strings.HasPrefix(name, BlobFolderName+"/") && len(path.Ext(name)) == 0 && !strings.HasPrefix(path.Base(name), "tmp-"):
+			result := &ScrubResult{Name: name, Expected: path.Base(name)}
+			result.Actual, result.Err = hashContent(s, name)
+			report(result)
+		}
+		return nil
+	})
+}
+
+// ScrubFile re-hashes the content of a file (relPath is its storage name without extension)
+// and records its missing checksum if update is true
+func ScrubFile(root, relPath string, update bool) *ScrubResult {
+	s, err := GetStorage(root)
+	if err != nil {
+		return &ScrubResult{Name: relPath + ".bin", Err: err}
+	}
+	return scrubFile(s, relPath, update)
+}
+
+func scrubFile(s Storage, relPath string, update bool) *ScrubResult {
+	result := &ScrubResult{Name: relPath + ".bin", Folder: path.Dir(relPath)}
+	if path.Base(result.Folder) == TrashFolderName {
+		result.Folder = path.Dir(result.Folder)
+	}
+	data, err := s.ReadFile(relPath + ".json")
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Actual, result.Err = hashContent(s, result.Name)
+	if result.Err != nil {
+		return result
+	}
+
+	// trashed files keep their metadata in a TrashedFile
+	var t TrashedFile
+	if err := json.Unmarshal(data, &t); err == nil && len(t.ID) > 0 && t.File != nil {
+		result.Filename = t.File.Name
+		result.Expected = t.File.Hash
+		if len(result.Expected) == 0 && update {
+			t.File.Hash = result.Actual
+			result.Err = s.WriteFile(relPath+".json", marshalMetadata(&t))
+			result.Expected, result.Updated = result.Actual, result.Err == nil
+		}
+		return result
+	}
+
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		result.Err = err
+		return result
+	}
+	result.Filename = f.Name
+	result.Expected = f.Hash
+	if len(result.Expected) == 0 && update {
+		f.Hash = result.Actual
+		result.Err = s.WriteFile(relPath+".json", marshalMetadata(&f))
+		result.Expected, result.Updated = result.Actual, result.Err == nil
+	}
+	return result
+}
+
+func marshalMetadata(v interface{}) []byte {
+	data, _ := json.MarshalIndent(v, "", "  ")
+	return data
+}
@@ -0,0 +1,74 @@
+package internal
+
+// FolderSettings are the options of a folder config that can be changed without shell access
+type FolderSettings struct {
+	MaxFileSizeMB      int64
+	MaxFolderSizeMB    int64
+	Subfolders         bool
+	TrashRetentionDays int // 0 = default, negative = no trash
+	MaxFileVersions    int // 0 = default, negative = no versions
+	Dedup              bool
+	AllowedTypes       []string
+	BlockedTypes       []string
+	AllowedExtensions  []string
+	BlockedExtensions  []string
+}
+
+// GetSettings returns the changeable options of the folder config
+func (f *Folder) GetSettings() *FolderSettings {
+	return &FolderSettings{
+		MaxFileSizeMB:      f.Config.MaxFileSizeMB,
+		MaxFolderSizeMB:    f.Config.MaxFolderSizeMB,
+		Subfolders:         f.Config.Subfolders,
+		TrashRetentionDays: f.Config.TrashRetentionDays,
+		MaxFileVersions:    f.Config.MaxFileVersions,
+		Dedup:              f.Config.Dedup,
+		AllowedTypes:       f.Config.AllowedTypes,
+		BlockedTypes:       f.Config.BlockedTypes,
+		AllowedExtensions:  f.Config.AllowedExtensions,
+		BlockedExtensions:  f.Config.BlockedExtensions,
+	}
+}
+
+// SetSettings changes the options of the folder config and saves it
+func (f *Folder) SetSettings(s *FolderSettings) error {
+	if f.ConfigInherited {
+		return &ErrInheritedConfigChange{}
+	}
+
+	if s.MaxFileSizeMB < 0 {
+		return &ErrInvalidFolderSetting{Setting: "max file size"}
+	}
+	if s.MaxFolderSizeMB < 0 {
+		return &ErrInvalidFolderSetting{Setting: "max folder size"}
+	}
+
+	allowedTypes, err := normalizeMIMEPatterns(s.AllowedTypes, "allowed types")
+	if err != nil {
+		return err
+	}
+	blockedTypes, err := normalizeMIMEPatterns(s.BlockedTypes, "blocked types")
+	if err != nil {
+		return err
+	}
+	allowedExtensions, err := normalizeExtensions(s.AllowedExtensions, "allowed extensions")
+	if err != nil {
+		return err
+	}
+	blockedExtensions, err := normalizeExtensions(s.BlockedExtensions, "blocked extensions")
+	if err != nil {
+		return err
+	}
+
+	f.Config.MaxFileSizeMB = s.MaxFileSizeMB
+	f.Config.MaxFolderSizeMB = s.MaxFolderSizeMB
+	f.Config.Subfolders = s.Subfolders
+	f.Config.TrashRetentionDays = s.TrashRetentionDays
+	f.Config.MaxFileVersions = s.MaxFileVersions
+	f.Config.Dedup = s.Dedup
+	f.Config.AllowedTypes = allowedTypes
+	f.Config.BlockedTypes = blockedTypes
+	f.Config.AllowedExtensions = allowedExtensions
+	f.Config.BlockedExtensions = blockedExtensions
+	return f.save()
+}
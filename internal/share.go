@@ -0,0 +1,231 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/razzie/beepboop"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ShareLink grants read access to a file or a folder (and its subfolders) to anyone who has its token
+type ShareLink struct {
+	ID           string    `json:"id"`
+	Path         string    `json:"path"` // relative to the root
+	Folder       bool      `json:"folder"`
+	Created      time.Time `json:"created"`
+	Expires      time.Time `json:"expires"`
+	MaxDownloads int       `json:"max_downloads,omitempty"` // 0 = unlimited
+	Downloads    int       `json:"downloads,omitempty"`
+	Password     string    `json:"password,omitempty"` // bcrypt hash
+}
+
+// IsExpired returns whether the share link is expired
+func (l *ShareLink) IsExpired() bool {
+	return time.Now().After(l.Expires)
+}
+
+// IsExhausted returns whether the share link reached its download limit
+func (l *ShareLink) IsExhausted() bool {
+	return l.MaxDownloads > 0 && l.Downloads >= l.MaxDownloads
+}
+
+// Covers returns whether the share link grants access to the given file or folder
+func (l *ShareLink) Covers(relPath string) bool {
+	relPath = path.Clean(relPath)
+	if relPath == l.Path {
+		return true
+	}
+	return l.Folder && strings.HasPrefix(relPath, l.Path+"/")
+}
+
+// TestPassword returns whether the given password matches the password of the share link
+func (l *ShareLink) TestPassword(pw string) bool {
+	if len(l.Password) == 0 {
+		return true
+	}
+	return bcrypt.CompareHashAndPassword([]byte(l.Password), []byte(pw)) == nil
+}
+
+func (f *Folder) signShare(parts ...string) string {
+	mac := hmac.New(sha256.New, []byte(f.Config.ShareSecret))
+	mac.Write([]byte(strings.Join(parts, "\n")))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (f *Folder) signShareLink(l *ShareLink) string {
+	return f.signShare(f.ConfigRootFolder, l.ID, strconv.FormatInt(l.Expires.Unix(), 10))
+}
+
+// GetShareAccessCode returns the code that access tokens of a password protected share link carry
+func (f *Folder) GetShareAccessCode(l *ShareLink) string {
+	return f.signShare("password", l.ID, l.Password)
+}
+
+// GetShareAccessToken returns an access token to a password protected share link
+func (f *Folder) GetShareAccessToken(l *ShareLink) beepboop.AccessMap {
+	access := make(beepboop.AccessMap)
+	access.Add("share", l.ID, f.GetShareAccessCode(l))
+	return access
+}
+
+// GetShareToken returns the signed token of a share link
+// (<config root>.<link ID>.<expiration>.<signature>)
+func (f *Folder) GetShareToken(l *ShareLink) string {
+	return strings.Join([]string{
+		base64.RawURLEncoding.EncodeToString([]byte(f.ConfigRootFolder)),
+		l.ID,
+		strconv.FormatInt(l.Expires.Unix(), 36),
+		f.signShareLink(l),
+	}, ".")
+}
+
+// GetShareTokenFolder returns the config root folder which the share token belongs to
+func GetShareTokenFolder(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 4 {
+		return "", &ErrInvalidShareToken{}
+	}
+	folder, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", &ErrInvalidShareToken{}
+	}
+	return string(folder), nil
+}
+
+// GetShareLinkByToken verifies the signature and expiration of a share token and returns its share link
+func (f *Folder) GetShareLinkByToken(token string) (*ShareLink, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 4 || len(f.Config.ShareSecret) == 0 {
+		return nil, &ErrInvalidShareToken{}
+	}
+	if folder, err := GetShareTokenFolder(token); err != nil || folder != f.ConfigRootFolder {
+		return nil, &ErrInvalidShareToken{}
+	}
+
+	l, err := f.GetShareLink(parts[1])
+	if err != nil {
+		return nil, &ErrInvalidShareToken{}
+	}
+	expires, err := strconv.ParseInt(parts[2], 36, 64)
+	if err != nil || expires != l.Expires.Unix() {
+		return nil, &ErrInvalidShareToken{}
+	}
+	if !hmac.Equal([]byte(parts[3]), []byte(f.signShareLink(l))) {
+		return nil, &ErrInvalidShareToken{}
+	}
+	if l.IsExpired() {
+		return nil, &ErrShareLinkExpired{}
+	}
+	return l, nil
+}
+
+// GetShareLink returns the share link with the given ID
+func (f *Folder) GetShareLink(id string) (*ShareLink, error) {
+	for _, l := range f.Config.ShareLinks {
+		if l.ID == id {
+			return l, nil
+		}
+	}
+	return nil, &ErrShareLinkNotFound{ID: id}
+}
+
+// CreateShareLink creates a share link to a file or folder under this folder (and drops the expired ones)
+func (f *Folder) CreateShareLink(relPath string, isFolder bool, expires time.Time, maxDownloads int, password string) (*ShareLink, error) {
+	if len(f.Config.ShareSecret) == 0 {
+		var secret [32]byte
+		if _, err := rand.Read(secret[:]); err != nil {
+			return nil, err
+		}
+		f.Config.ShareSecret = hex.EncodeToString(secret[:])
+	}
+
+	l := &ShareLink{
+		ID:           uuid.New().String(),
+		Path:         path.Clean(relPath),
+		Folder:       isFolder,
+		Created:      time.Now(),
+		Expires:      expires,
+		MaxDownloads: maxDownloads,
+	}
+	if len(password) > 0 {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), BcryptCost)
+		if err != nil {
+			return nil, err
+		}
+		l.Password = string(hash)
+	}
+
+	links := make([]*ShareLink, 0, len(f.Config.ShareLinks)+1)
+	for _, link := range f.Config.ShareLinks {
+		if !link.IsExpired() {
+			links = append(links, link)
+		}
+	}
+	oldLinks := f.Config.ShareLinks
+	f.Config.ShareLinks = append(links, l)
+	if err := f.saveConfigRoot(); err != nil {
+		f.Config.ShareLinks = oldLinks
+		return nil, err
+	}
+	return l, nil
+}
+
+// RevokeShareLink removes the share link with the given ID (and drops the expired ones)
+func (f *Folder) RevokeShareLink(id string) error {
+	links := make([]*ShareLink, 0, len(f.Config.ShareLinks))
+	found := false
+	for _, l := range f.Config.ShareLinks {
+		if l.ID == id {
+			found = true
+			continue
+		}
+		if !l.IsExpired() {
+			links = append(links, l)
+		}
+	}
+	if !found {
+		return &ErrShareLinkNotFound{ID: id}
+	}
+	f.Config.ShareLinks = links
+	return f.saveConfigRoot()
+}
+
+// CountShareLinkDownload increments the download counter of the share link
+// or returns an error if it already reached its download limit
+func (f *Folder) CountShareLinkDownload(l *ShareLink) error {
+	if l.MaxDownloads == 0 {
+		return nil
+	}
+	if l.IsExhausted() {
+		return &ErrShareLinkExhausted{}
+	}
+	l.Downloads++
+	if err := f.saveConfigRoot(); err != nil {
+		l.Downloads--
+		return err
+	}
+	return nil
+}
+
+// UncountShareLinkDownload decrements the download counter of the share link
+// (like when a counted download couldn't be completed)
+func (f *Folder) UncountShareLinkDownload(l *ShareLink) error {
+	if l.MaxDownloads == 0 || l.Downloads == 0 {
+		return nil
+	}
+	l.Downloads--
+	if err := f.saveConfigRoot(); err != nil {
+		l.Downloads++
+		return err
+	}
+	return nil
+}
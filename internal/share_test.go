@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateShareLinkDropsExpired(t *testing.T) {
+	root := "mem://share-test-prune"
+	writeTestConfig(t, root, "a", &FolderConfig{})
+	folder, err := GetFolder(root, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	expired, err := folder.CreateShareLink("a/x", false, now.Add(-time.Minute), 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, err := folder.CreateShareLink("a/y", false, now.Add(time.Hour), 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := folder.CreateShareLink("a/z", true, now.Add(time.Hour), 0, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := GetFolder(root, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := saved.GetShareLink(expired.ID); err == nil {
+		t.Error("the expired share link wasn't dropped")
+	}
+	if _, err := saved.GetShareLink(valid.ID); err != nil {
+		t.Error("a valid share link was dropped")
+	}
+	if n := len(saved.Config.ShareLinks); n != 2 {
+		t.Errorf("%d share links are saved, want 2", n)
+	}
+}
+
+func TestGetShareLinkByToken(t *testing.T) {
+	root := "mem://share-test-token"
+	writeTestConfig(t, root, "a", &FolderConfig{})
+	writeTestConfig(t, root, "b", &FolderConfig{})
+	folder, err := GetFolder(root, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := GetFolder(root, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link, err := folder.CreateShareLink("a/x", false, time.Now().Add(time.Hour), 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := folder.CreateShareLink("a/y", false, time.Now().Add(time.Hour), 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revokedToken := folder.GetShareToken(revoked)
+	if err := folder.RevokeShareLink(revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+	expired, err := folder.CreateShareLink("a/z", false, time.Now().Add(-time.Minute), 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.CreateShareLink("b/x", false, time.Now().Add(time.Hour), 0, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	token := folder.GetShareToken(link)
+	parts := strings.Split(token, ".")
+	withPart := func(i int, value string) string {
+		p := append([]string(nil), parts...)
+		p[i] = value
+		return strings.Join(p, ".")
+	}
+
+	tests := []struct {
+		name    string
+		folder  *Folder
+		token   string
+		wantErr error
+	}{
+		{"valid", folder, token, nil},
+		{"malformed", folder, "abc", &ErrInvalidShareToken{}},
+		{"other folder", other, token, &ErrInvalidShareToken{}},
+		{"other folder name", folder, withPart(0, base64.RawURLEncoding.EncodeToString([]byte("b"))), &ErrInvalidShareToken{}},
+		{"unknown link", folder, withPart(1, "x"), &ErrInvalidShareToken{}},
+		{"extended expiration", folder, withPart(2, "zzzzzz"), &ErrInvalidShareToken{}},
+		{"tampered signature", folder, withPart(3, folder.signShare("x")), &ErrInvalidShareToken{}},
+		{"revoked", folder, revokedToken, &ErrInvalidShareToken{}},
+		{"expired", folder, folder.GetShareToken(expired), &ErrShareLinkExpired{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := tt.folder.GetShareLinkByToken(tt.token)
+			if tt.wantErr == nil {
+				if err != nil || l.ID != link.ID {
+					t.Errorf("got link %v, error %v", l, err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr.Error() {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestShareLinkCovers(t *testing.T) {
+	tests := []struct {
+		name    string
+		link    ShareLink
+		relPath string
+		want    bool
+	}{
+		{"file", ShareLink{Path: "a/x"}, "a/x", true},
+		{"other file", ShareLink{Path: "a/x"}, "a/y", false},
+		{"file as folder", ShareLink{Path: "a/x"}, "a/x/y", false},
+		{"folder", ShareLink{Path: "a/b", Folder: true}, "a/b", true},
+		{"file in folder", ShareLink{Path: "a/b", Folder: true}, "a/b/x", true},
+		{"file in subfolder", ShareLink{Path: "a/b", Folder: true}, "a/b/c/x", true},
+		{"folder with same prefix", ShareLink{Path: "a/b", Folder: true}, "a/bc/x", false},
+		{"parent folder", ShareLink{Path: "a/b", Folder: true}, "a", false},
+		{"escaping path", ShareLink{Path: "a/b", Folder: true}, "a/b/../c", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.link.Covers(tt.relPath); got != tt.want {
+				t.Errorf("Covers(%q) = %t, want %t", tt.relPath, got, tt.want)
+			}
+		})
+	}
+}
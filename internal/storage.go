@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Storage is a backend that stores the file contents, metadata and folder configs.
+// Names are slash separated paths relative to the root of the storage.
+type Storage interface {
+	// Open opens a stored object for reading
+	Open(name string) (StorageObject, error)
+	// Write stores the content of the reader under the given name (replacing the previous content)
+	Write(name string, content io.Reader) (int64, error)
+	// Append appends content to a stored object (creating it if it doesn't exist)
+	Append(name string, content io.Reader) (int64, error)
+	// ReadFile returns the whole content of a stored object
+	ReadFile(name string) ([]byte, error)
+	// WriteFile is like Write, but with a byte slice
+	WriteFile(name string, data []byte) error
+	// Stat returns information about a stored object or a folder
+	Stat(name string) (os.FileInfo, error)
+	// Link makes the content of an object available under a new name too (as a hard link or a copy)
+	Link(oldname, newname string) error
+	// Rename moves a stored object to a new name
+	Rename(oldname, newname string) error
+	// Remove removes a stored object or an empty folder
+	Remove(name string) error
+	// List returns the objects and folders directly under the given folder
+	List(dir string) ([]os.FileInfo, error)
+	// MkdirAll creates a folder and its parents
+	MkdirAll(dir string) error
+}
+
+// StorageObject is a stored object opened for reading
+type StorageObject interface {
+	io.ReadSeeker
+	io.Closer
+	Stat() (os.FileInfo, error)
+}
+
+// localStorage is implemented by storages that keep the objects in the local filesystem
+type localStorage interface {
+	LocalFilename(name string) string
+}
+
+var (
+	storagesMu sync.Mutex
+	storages   = make(map[string]Storage)
+)
+
+// IsLocalRoot returns whether the root is a path in the local filesystem (and not a storage URL)
+func IsLocalRoot(root string) bool {
+	return !strings.Contains(root, "://")
+}
+
+// GetStorage returns the storage of a root, which is either a local path,
+// a mem://<name> in-memory storage or a s3://, s3+http:// S3-compatible storage URL
+func GetStorage(root string) (Storage, error) {
+	storagesMu.Lock()
+	defer storagesMu.Unlock()
+
+	if s, ok := storages[root]; ok {
+		return s, nil
+	}
+
+	var s Storage
+	switch {
+	case IsLocalRoot(root):
+		s = NewLocalStorage(root)
+	case strings.HasPrefix(root, "mem://"):
+		s = NewMemoryStorage()
+	case strings.HasPrefix(root, "s3://"), strings.HasPrefix(root, "s3+http://"):
+		var err error
+		s, err = NewS3Storage(root)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, &ErrUnsupportedStorage{Root: root}
+	}
+
+	s = &guardedStorage{Storage: s, root: root}
+	storages[root] = s
+	return s, nil
+}
+
+// storage returns the storage of a root that was already opened by GetStorage
+// (or the local filesystem storage as a fallback)
+func storage(root string) Storage {
+	s, err := GetStorage(root)
+	if err != nil {
+		return NewLocalStorage(root)
+	}
+	return s
+}
+
+func isNotExist(err error) bool {
+	return os.IsNotExist(err) || err == os.ErrNotExist
+}
+
+// globStorage returns the names of the objects in the folder that match the pattern
+func globStorage(s Storage, dir, pattern string) []string {
+	entries, _ := s.List(dir)
+	var matches []string
+	for _, entry := range entries {
+		if matched, _ := path.Match(pattern, entry.Name()); matched && !entry.IsDir() {
+			matches = append(matches, path.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// walkStorage walks the folder tree like filepath.Walk, but with storage relative names
+func walkStorage(s Storage, dir string, walkFn filepath.WalkFunc) error {
+	info, err := s.Stat(dir)
+	if err != nil {
+		return walkFn(dir, nil, err)
+	}
+	err = walkStorageDir(s, dir, info, walkFn)
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+func walkStorageDir(s Storage, name string, info os.FileInfo, walkFn filepath.WalkFunc) error {
+	if !info.IsDir() {
+		return walkFn(name, info, nil)
+	}
+
+	entries, err := s.List(name)
+	err1 := walkFn(name, info, err)
+	if err != nil || err1 != nil {
+		return err1
+	}
+
+	for _, entry := range entries {
+		err = walkStorageDir(s, path.Join(name, entry.Name()), entry, walkFn)
+		if err != nil {
+			if !entry.IsDir() || err != filepath.SkipDir {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WalkStorage walks the folder tree of a root like filepath.Walk, but with root relative names
+func WalkStorage(root, dir string, walkFn filepath.WalkFunc) error {
+	return walkStorage(storage(root), dir, walkFn)
+}
+
+// getLocalFilename returns the name of a local file with the content of a stored object.
+// Objects of non-local storages are copied to a temporary file which is removed by the cleanup function.
+func getLocalFilename(s Storage, name string) (filename string, cleanup func(), err error) {
+	if guarded, ok := s.(*guardedStorage); ok {
+		s = guarded.Storage
+	}
+	if local, ok := s.(localStorage); ok {
+		return local.LocalFilename(name), func() {}, nil
+	}
+
+	obj, err := s.Open(name)
+	if err != nil {
+		return "", nil, err
+	}
+	defer obj.Close()
+
+	tmpfile, err := ioutil.TempFile("", "razbox-*-"+path.Base(name))
+	if err != nil {
+		return "", nil, err
+	}
+	defer tmpfile.Close()
+
+	if _, err := io.Copy(tmpfile, obj); err != nil {
+		os.Remove(tmpfile.Name())
+		return "", nil, err
+	}
+	return tmpfile.Name(), func() { os.Remove(tmpfile.Name()) }, nil
+}
+
+// GetLocalFilename returns the name of a local file with the content of a stored object of a root
+// (and a cleanup function that must be called when the file is not needed anymore)
+func GetLocalFilename(root, name string) (filename string, cleanup func(), err error) {
+	return getLocalFilename(storage(root), name)
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// LocalStorage stores objects as files under a directory of the local filesystem
+type LocalStorage struct {
+	Dir string
+}
+
+// NewLocalStorage returns a new LocalStorage
+func NewLocalStorage(dir string) *LocalStorage {
+	return &LocalStorage{Dir: dir}
+}
+
+// LocalFilename returns the absolute filename of an object
+func (s *LocalStorage) LocalFilename(name string) string {
+	return filepath.Join(s.Dir, filepath.FromSlash(path.Clean("/"+name)))
+}
+
+// Open implements Storage
+func (s *LocalStorage) Open(name string) (StorageObject, error) {
+	return os.Open(s.LocalFilename(name))
+}
+
+// Write implements Storage by writing a temporary file first, then renaming it
+func (s *LocalStorage) Write(name string, content io.Reader) (int64, error) {
+	filename := s.LocalFilename(name)
+	tmpfile, err := ioutil.TempFile(filepath.Dir(filename), "razbox-upload-*-"+filepath.Base(filename))
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	n, err := io.Copy(tmpfile, content)
+	if err != nil {
+		return n, err
+	}
+
+	tmpfile.Close()
+	os.Chmod(tmpfile.Name(), 0644)
+	return n, os.Rename(tmpfile.Name(), filename)
+}
+
+// Append implements Storage
+func (s *LocalStorage) Append(name string, content io.Reader) (int64, error) {
+	file, err := os.OpenFile(s.LocalFilename(name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return io.Copy(file, content)
+}
+
+// ReadFile implements Storage
+func (s *LocalStorage) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(s.LocalFilename(name))
+}
+
+// WriteFile implements Storage by writing a temporary file first (so readers never see a partially written file)
+func (s *LocalStorage) WriteFile(name string, data []byte) error {
+	_, err := s.Write(name, bytes.NewReader(data))
+	return err
+}
+
+// Stat implements Storage
+func (s *LocalStorage) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(s.LocalFilename(name))
+}
+
+// Link implements Storage with a hard link
+func (s *LocalStorage) Link(oldname, newname string) error {
+	return os.Link(s.LocalFilename(oldname), s.LocalFilename(newname))
+}
+
+// Rename implements Storage
+func (s *LocalStorage) Rename(oldname, newname string) error {
+	return os.Rename(s.LocalFilename(oldname), s.LocalFilename(newname))
+}
+
+// Remove implements Storage
+func (s *LocalStorage) Remove(name string) error {
+	return os.Remove(s.LocalFilename(name))
+}
+
+// List implements Storage
+func (s *LocalStorage) List(dir string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(s.LocalFilename(dir))
+}
+
+// MkdirAll implements Storage
+func (s *LocalStorage) MkdirAll(dir string) error {
+	return os.MkdirAll(s.LocalFilename(dir), 0755)
+}
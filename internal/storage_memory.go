@@ -0,0 +1,298 @@
+package internal
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStorage keeps objects in memory (useful for testing)
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	objects map[string]*memoryObject
+	dirs    map[string]time.Time
+}
+
+type memoryObject struct {
+	data    []byte
+	modTime time.Time
+}
+
+// NewMemoryStorage returns a new empty MemoryStorage
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		objects: make(map[string]*memoryObject),
+		dirs:    map[string]time.Time{".": time.Now()},
+	}
+}
+
+func cleanName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+func notExist(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+}
+
+func (s *MemoryStorage) parentExists(name string) bool {
+	dir := path.Dir(name)
+	if dir == "." || len(dir) == 0 {
+		return true
+	}
+	_, ok := s.dirs[dir]
+	return ok
+}
+
+// Open implements Storage
+func (s *MemoryStorage) Open(name string) (StorageObject, error) {
+	name = cleanName(name)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	obj, ok := s.objects[name]
+	if !ok {
+		return nil, notExist("open", name)
+	}
+	return &memoryReader{
+		Reader: bytes.NewReader(obj.data),
+		info:   &memoryFileInfo{name: path.Base(name), size: int64(len(obj.data)), modTime: obj.modTime},
+	}, nil
+}
+
+// Write implements Storage
+func (s *MemoryStorage) Write(name string, content io.Reader) (int64, error) {
+	data, err := ioutil.ReadAll(content)
+	if err != nil {
+		return int64(len(data)), err
+	}
+	return int64(len(data)), s.WriteFile(name, data)
+}
+
+// Append implements Storage
+func (s *MemoryStorage) Append(name string, content io.Reader) (int64, error) {
+	data, err := ioutil.ReadAll(content)
+	name = cleanName(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.parentExists(name) {
+		return 0, notExist("append", name)
+	}
+	obj, ok := s.objects[name]
+	if !ok {
+		obj = &memoryObject{}
+		s.objects[name] = obj
+	}
+	obj.data = append(obj.data[:len(obj.data):len(obj.data)], data...)
+	obj.modTime = time.Now()
+	return int64(len(data)), err
+}
+
+// ReadFile implements Storage
+func (s *MemoryStorage) ReadFile(name string) ([]byte, error) {
+	name = cleanName(name)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	obj, ok := s.objects[name]
+	if !ok {
+		return nil, notExist("read", name)
+	}
+	return append([]byte(nil), obj.data...), nil
+}
+
+// WriteFile implements Storage
+func (s *MemoryStorage) WriteFile(name string, data []byte) error {
+	name = cleanName(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.parentExists(name) {
+		return notExist("write", name)
+	}
+	s.objects[name] = &memoryObject{
+		data:    append([]byte(nil), data...),
+		modTime: time.Now(),
+	}
+	return nil
+}
+
+// Stat implements Storage
+func (s *MemoryStorage) Stat(name string) (os.FileInfo, error) {
+	name = cleanName(name)
+	if len(name) == 0 {
+		name = "."
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if obj, ok := s.objects[name]; ok {
+		return &memoryFileInfo{name: path.Base(name), size: int64(len(obj.data)), modTime: obj.modTime}, nil
+	}
+	if modTime, ok := s.dirs[name]; ok {
+		return &memoryFileInfo{name: path.Base(name), modTime: modTime, dir: true}, nil
+	}
+	return nil, notExist("stat", name)
+}
+
+// Link implements Storage (objects are immutable, so the data can be shared)
+func (s *MemoryStorage) Link(oldname, newname string) error {
+	oldname, newname = cleanName(oldname), cleanName(newname)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj, ok := s.objects[oldname]
+	if !ok {
+		return notExist("link", oldname)
+	}
+	if _, exists := s.objects[newname]; exists || !s.parentExists(newname) {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: os.ErrExist}
+	}
+	s.objects[newname] = &memoryObject{data: obj.data, modTime: obj.modTime}
+	return nil
+}
+
+// Rename implements Storage
+func (s *MemoryStorage) Rename(oldname, newname string) error {
+	oldname, newname = cleanName(oldname), cleanName(newname)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	obj, ok := s.objects[oldname]
+	if !ok {
+		return notExist("rename", oldname)
+	}
+	if !s.parentExists(newname) {
+		return notExist("rename", newname)
+	}
+	delete(s.objects, oldname)
+	s.objects[newname] = obj
+	return nil
+}
+
+// Remove implements Storage
+func (s *MemoryStorage) Remove(name string) error {
+	name = cleanName(name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.objects[name]; ok {
+		delete(s.objects, name)
+		return nil
+	}
+	if _, ok := s.dirs[name]; ok {
+		for other := range s.objects {
+			if strings.HasPrefix(other, name+"/") {
+				return &os.PathError{Op: "remove", Path: name, Err: os.ErrExist}
+			}
+		}
+		for other := range s.dirs {
+			if strings.HasPrefix(other, name+"/") {
+				return &os.PathError{Op: "remove", Path: name, Err: os.ErrExist}
+			}
+		}
+		delete(s.dirs, name)
+		return nil
+	}
+	return notExist("remove", name)
+}
+
+// List implements Storage
+func (s *MemoryStorage) List(dir string) ([]os.FileInfo, error) {
+	dir = cleanName(dir)
+	if len(dir) == 0 {
+		dir = "."
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.dirs[dir]; !ok {
+		return nil, notExist("list", dir)
+	}
+
+	var entries []os.FileInfo
+	for name, obj := range s.objects {
+		if path.Dir(name) == dir {
+			entries = append(entries, &memoryFileInfo{name: path.Base(name), size: int64(len(obj.data)), modTime: obj.modTime})
+		}
+	}
+	for name, modTime := range s.dirs {
+		if name != "." && path.Dir(name) == dir {
+			entries = append(entries, &memoryFileInfo{name: path.Base(name), modTime: modTime, dir: true})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// MkdirAll implements Storage
+func (s *MemoryStorage) MkdirAll(dir string) error {
+	dir = cleanName(dir)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ; len(dir) > 0 && dir != "."; dir = path.Dir(dir) {
+		if _, ok := s.objects[dir]; ok {
+			return &os.PathError{Op: "mkdir", Path: dir, Err: os.ErrExist}
+		}
+		if _, ok := s.dirs[dir]; !ok {
+			s.dirs[dir] = time.Now()
+		}
+	}
+	return nil
+}
+
+type memoryReader struct {
+	*bytes.Reader
+	info os.FileInfo
+}
+
+func (r *memoryReader) Close() error {
+	return nil
+}
+
+func (r *memoryReader) Stat() (os.FileInfo, error) {
+	return r.info, nil
+}
+
+type memoryFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (fi *memoryFileInfo) Name() string {
+	return fi.name
+}
+
+func (fi *memoryFileInfo) Size() int64 {
+	return fi.size
+}
+
+func (fi *memoryFileInfo) Mode() os.FileMode {
+	if fi.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+func (fi *memoryFileInfo) ModTime() time.Time {
+	return fi.modTime
+}
+
+func (fi *memoryFileInfo) IsDir() bool {
+	return fi.dir
+}
+
+func (fi *memoryFileInfo) Sys() interface{} {
+	return nil
+}
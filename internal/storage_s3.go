@@ -0,0 +1,729 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Storage stores objects in a bucket of an S3-compatible object storage (like AWS S3 or MinIO).
+// Folders are represented by empty marker objects with a trailing slash.
+type S3Storage struct {
+	Endpoint  string // scheme and host, like https://s3.amazonaws.com
+	Bucket    string
+	Prefix    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+}
+
+// NewS3Storage returns a new S3Storage from an URL like
+// s3://access_key:secret_key@host[:port]/bucket[/prefix][?region=us-east-1]
+// (or s3+http:// for plain HTTP endpoints). Missing credentials and region are taken from
+// the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_REGION environment variables.
+func NewS3Storage(rawurl string) (*S3Storage, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := "https"
+	if u.Scheme == "s3+http" {
+		scheme = "http"
+	}
+
+	bucketAndPrefix := strings.SplitN(strings.Trim(u.Path, "/"), "/", 2)
+	if len(u.Host) == 0 || len(bucketAndPrefix[0]) == 0 {
+		return nil, &ErrUnsupportedStorage{Root: rawurl}
+	}
+
+	s := &S3Storage{
+		Endpoint:  scheme + "://" + u.Host,
+		Bucket:    bucketAndPrefix[0],
+		Region:    u.Query().Get("region"),
+		AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		Client:    &http.Client{},
+	}
+	if len(bucketAndPrefix) > 1 {
+		s.Prefix = bucketAndPrefix[1]
+	}
+	if u.User != nil {
+		s.AccessKey = u.User.Username()
+		s.SecretKey, _ = u.User.Password()
+	}
+	if len(s.Region) == 0 {
+		s.Region = os.Getenv("AWS_REGION")
+	}
+	if len(s.Region) == 0 {
+		s.Region = "us-east-1"
+	}
+	return s, nil
+}
+
+func (s *S3Storage) key(name string) string {
+	return strings.TrimPrefix(path.Join(s.Prefix, cleanName(name)), "/")
+}
+
+// uriEncode encodes a string as described in the AWS Signature Version 4 documentation
+func uriEncode(s string, encodeSlash bool) string {
+	var buf strings.Builder
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~', b == '/' && !encodeSlash:
+			buf.WriteByte(b)
+		default:
+			fmt.Fprintf(&buf, "%%%02X", b)
+		}
+	}
+	return buf.String()
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var params []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			params = append(params, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// signRequest adds the AWS Signature Version 4 authorization header to the request
+func signRequest(req *http.Request, accessKey, secretKey, region, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := now.UTC().Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if len(host) == 0 {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") || name == "range" || name == "content-md5" {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalRequestHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+secretKey), date)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+type s3Request struct {
+	method      string
+	key         string
+	query       url.Values
+	header      http.Header
+	body        io.Reader
+	length      int64
+	payloadHash string
+}
+
+func (s *S3Storage) do(r *s3Request) (*http.Response, error) {
+	objectPath := "/" + s.Bucket
+	if len(r.key) > 0 {
+		objectPath += "/" + r.key
+	}
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = objectPath
+	u.RawPath = uriEncode(objectPath, false)
+	u.RawQuery = canonicalQuery(r.query)
+
+	req, err := http.NewRequest(r.method, u.String(), r.body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range r.header {
+		req.Header[name] = values
+	}
+	if r.body != nil {
+		req.ContentLength = r.length
+		if r.length == 0 {
+			req.Body = http.NoBody
+		}
+	}
+
+	payloadHash := r.payloadHash
+	if len(payloadHash) == 0 {
+		payloadHash = emptyPayloadHash
+	}
+	signRequest(req, s.AccessKey, s.SecretKey, s.Region, payloadHash, time.Now())
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, notExist(strings.ToLower(r.method), r.key)
+		}
+		var s3err struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<16))
+		xml.Unmarshal(data, &s3err)
+		return nil, &ErrS3Request{
+			Method:     r.method,
+			Key:        r.key,
+			StatusCode: resp.StatusCode,
+			S3Code:     s3err.Code,
+			Message:    s3err.Message,
+		}
+	}
+	return resp, nil
+}
+
+func (s *S3Storage) doAndClose(r *s3Request) error {
+	resp, err := s.do(r)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+func (s *S3Storage) head(key string) (os.FileInfo, error) {
+	resp, err := s.do(&s3Request{method: "HEAD", key: key})
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return &memoryFileInfo{name: path.Base(key), size: resp.ContentLength, modTime: modTime}, nil
+}
+
+func (s *S3Storage) put(key string, data []byte) error {
+	hash := sha256.Sum256(data)
+	return s.doAndClose(&s3Request{
+		method:      "PUT",
+		key:         key,
+		body:        bytes.NewReader(data),
+		length:      int64(len(data)),
+		payloadHash: hex.EncodeToString(hash[:]),
+	})
+}
+
+// spool writes content to a temporary local file, so its length and hash are known before it's uploaded
+// (the returned file has to be closed and removed)
+func spool(write func(w io.Writer) (int64, error)) (tmpfile *os.File, n int64, hash string, err error) {
+	tmpfile, err = ioutil.TempFile("", "razbox-s3-*")
+	if err != nil {
+		return nil, 0, "", err
+	}
+
+	h := sha256.New()
+	n, err = write(io.MultiWriter(tmpfile, h))
+	if err == nil {
+		_, err = tmpfile.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		tmpfile.Close()
+		os.Remove(tmpfile.Name())
+		return nil, n, "", err
+	}
+	return tmpfile, n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// putFile uploads a (temporary) local file that was written by the given function
+func (s *S3Storage) putFile(key string, write func(w io.Writer) (int64, error)) (int64, error) {
+	tmpfile, n, hash, err := spool(write)
+	if err != nil {
+		return n, err
+	}
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	return n, s.doAndClose(&s3Request{
+		method:      "PUT",
+		key:         key,
+		body:        tmpfile,
+		length:      n,
+		payloadHash: hash,
+	})
+}
+
+func (s *S3Storage) copy(oldKey, newKey string) error {
+	header := make(http.Header)
+	header.Set("X-Amz-Copy-Source", uriEncode("/"+s.Bucket+"/"+oldKey, false))
+	return s.doAndClose(&s3Request{
+		method: "PUT",
+		key:    newKey,
+		header: header,
+	})
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3Storage) list(prefix string, maxKeys int) (*s3ListResult, error) {
+	var all s3ListResult
+	token := ""
+	for {
+		query := url.Values{
+			"list-type": {"2"},
+			"prefix":    {prefix},
+			"delimiter": {"/"},
+		}
+		if maxKeys > 0 {
+			query.Set("max-keys", strconv.Itoa(maxKeys))
+		}
+		if len(token) > 0 {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do(&s3Request{method: "GET", query: query})
+		if err != nil {
+			return nil, err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		all.Contents = append(all.Contents, result.Contents...)
+		all.CommonPrefixes = append(all.CommonPrefixes, result.CommonPrefixes...)
+		if !result.IsTruncated || maxKeys > 0 || len(result.NextContinuationToken) == 0 {
+			return &all, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (s *S3Storage) dirPrefix(name string) string {
+	key := s.key(name)
+	if len(key) == 0 {
+		return ""
+	}
+	return key + "/"
+}
+
+// Open implements Storage
+func (s *S3Storage) Open(name string) (StorageObject, error) {
+	key := s.key(name)
+	info, err := s.head(key)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Object{s: s, key: key, info: info}, nil
+}
+
+// Write implements Storage
+func (s *S3Storage) Write(name string, content io.Reader) (int64, error) {
+	return s.putFile(s.key(name), func(w io.Writer) (int64, error) {
+		return io.Copy(w, content)
+	})
+}
+
+// s3MinPartSize is the minimum size of every part of a multipart upload but the last one
+const s3MinPartSize = 5 << 20
+
+// Append implements Storage (S3 objects can't be modified).
+// Objects of at least s3MinPartSize are replaced by a multipart upload whose first part is a server side copy
+// of the object, so only the new content is uploaded. Smaller objects are uploaded again with the new content.
+func (s *S3Storage) Append(name string, content io.Reader) (int64, error) {
+	key := s.key(name)
+	info, err := s.head(key)
+	if err != nil && !isNotExist(err) {
+		return 0, err
+	}
+	if err == nil && info.Size() >= s3MinPartSize {
+		return s.appendParts(key, content)
+	}
+
+	var appended int64
+	_, err = s.putFile(key, func(w io.Writer) (int64, error) {
+		var n int64
+		if info != nil {
+			resp, err := s.do(&s3Request{method: "GET", key: key})
+			if err != nil {
+				return 0, err
+			}
+			n, err = io.Copy(w, resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return n, err
+			}
+		}
+		appended, err = io.Copy(w, content)
+		return n + appended, err
+	})
+	return appended, err
+}
+
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// appendParts appends content to an object with a multipart upload
+func (s *S3Storage) appendParts(key string, content io.Reader) (int64, error) {
+	tmpfile, n, hash, err := spool(func(w io.Writer) (int64, error) {
+		return io.Copy(w, content)
+	})
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+
+	resp, err := s.do(&s3Request{method: "POST", key: key, query: url.Values{"uploads": {""}}})
+	if err != nil {
+		return 0, err
+	}
+	var upload struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&upload)
+	resp.Body.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	parts, err := s.uploadAppendParts(key, upload.UploadID, tmpfile, n, hash)
+	if err == nil {
+		err = s.completeUpload(key, upload.UploadID, parts)
+	}
+	if err != nil {
+		s.doAndClose(&s3Request{method: "DELETE", key: key, query: url.Values{"uploadId": {upload.UploadID}}})
+		return 0, err
+	}
+	return n, nil
+}
+
+// uploadAppendParts copies the current object as the first part and uploads the new content as the second part
+func (s *S3Storage) uploadAppendParts(key, uploadID string, content io.Reader, n int64, hash string) ([]s3CompletedPart, error) {
+	partQuery := func(partNumber int) url.Values {
+		return url.Values{"partNumber": {strconv.Itoa(partNumber)}, "uploadId": {uploadID}}
+	}
+
+	header := make(http.Header)
+	header.Set("X-Amz-Copy-Source", uriEncode("/"+s.Bucket+"/"+key, false))
+	resp, err := s.do(&s3Request{method: "PUT", key: key, query: partQuery(1), header: header})
+	if err != nil {
+		return nil, err
+	}
+	var copied s3CompletedPart
+	err = xml.NewDecoder(resp.Body).Decode(&copied)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err = s.do(&s3Request{
+		method:      "PUT",
+		key:         key,
+		query:       partQuery(2),
+		body:        content,
+		length:      n,
+		payloadHash: hash,
+	})
+	if err != nil {
+		return nil, err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	return []s3CompletedPart{
+		{PartNumber: 1, ETag: copied.ETag},
+		{PartNumber: 2, ETag: resp.Header.Get("ETag")},
+	}, nil
+}
+
+func (s *S3Storage) completeUpload(key, uploadID string, parts []s3CompletedPart) error {
+	data, _ := xml.Marshal(&struct {
+		XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+		Parts   []s3CompletedPart `xml:"Part"`
+	}{Parts: parts})
+	hash := sha256.Sum256(data)
+	resp, err := s.do(&s3Request{
+		method:      "POST",
+		key:         key,
+		query:       url.Values{"uploadId": {uploadID}},
+		body:        bytes.NewReader(data),
+		length:      int64(len(data)),
+		payloadHash: hex.EncodeToString(hash[:]),
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// the upload can still fail after the response status was sent
+	var result struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if result.XMLName.Local == "Error" {
+		return &ErrS3Request{
+			Method:     "POST",
+			Key:        key,
+			StatusCode: resp.StatusCode,
+			S3Code:     result.Code,
+			Message:    result.Message,
+		}
+	}
+	return nil
+}
+
+// ReadFile implements Storage
+func (s *S3Storage) ReadFile(name string) ([]byte, error) {
+	resp, err := s.do(&s3Request{method: "GET", key: s.key(name)})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+// WriteFile implements Storage
+func (s *S3Storage) WriteFile(name string, data []byte) error {
+	return s.put(s.key(name), data)
+}
+
+// Stat implements Storage
+func (s *S3Storage) Stat(name string) (os.FileInfo, error) {
+	key := s.key(name)
+	if len(key) > 0 {
+		info, err := s.head(key)
+		if err == nil {
+			return info, nil
+		}
+		if !isNotExist(err) {
+			return nil, err
+		}
+	}
+
+	prefix := s.dirPrefix(name)
+	result, err := s.list(prefix, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(prefix) > 0 && len(result.Contents) == 0 && len(result.CommonPrefixes) == 0 {
+		return nil, notExist("stat", key)
+	}
+	return &memoryFileInfo{name: path.Base(cleanName(name)), dir: true}, nil
+}
+
+// Link implements Storage with a server side copy
+func (s *S3Storage) Link(oldname, newname string) error {
+	return s.copy(s.key(oldname), s.key(newname))
+}
+
+// Rename implements Storage with a server side copy and a delete
+func (s *S3Storage) Rename(oldname, newname string) error {
+	if err := s.copy(s.key(oldname), s.key(newname)); err != nil {
+		return err
+	}
+	return s.doAndClose(&s3Request{method: "DELETE", key: s.key(oldname)})
+}
+
+// Remove implements Storage
+func (s *S3Storage) Remove(name string) error {
+	info, err := s.Stat(name)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return s.doAndClose(&s3Request{method: "DELETE", key: s.key(name)})
+	}
+
+	entries, err := s.List(name)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrExist}
+	}
+	return s.doAndClose(&s3Request{method: "DELETE", key: s.dirPrefix(name)})
+}
+
+// List implements Storage
+func (s *S3Storage) List(dir string) ([]os.FileInfo, error) {
+	prefix := s.dirPrefix(dir)
+	result, err := s.list(prefix, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	exists := len(prefix) == 0
+	entries := make([]os.FileInfo, 0, len(result.Contents)+len(result.CommonPrefixes))
+	for _, obj := range result.Contents {
+		exists = true
+		if obj.Key == prefix { // folder marker
+			continue
+		}
+		entries = append(entries, &memoryFileInfo{
+			name:    strings.TrimPrefix(obj.Key, prefix),
+			size:    obj.Size,
+			modTime: obj.LastModified,
+		})
+	}
+	for _, p := range result.CommonPrefixes {
+		exists = true
+		entries = append(entries, &memoryFileInfo{
+			name: strings.TrimSuffix(strings.TrimPrefix(p.Prefix, prefix), "/"),
+			dir:  true,
+		})
+	}
+	if !exists {
+		return nil, notExist("list", dir)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// MkdirAll implements Storage by creating a folder marker object
+func (s *S3Storage) MkdirAll(dir string) error {
+	prefix := s.dirPrefix(dir)
+	if len(prefix) == 0 {
+		return nil
+	}
+	return s.put(prefix, nil)
+}
+
+// s3Object reads an object with ranged GET requests, so seeking doesn't download the skipped content
+type s3Object struct {
+	s      *S3Storage
+	key    string
+	info   os.FileInfo
+	offset int64
+	body   io.ReadCloser
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.info.Size() {
+		return 0, io.EOF
+	}
+
+	if o.body == nil {
+		header := make(http.Header)
+		header.Set("Range", fmt.Sprintf("bytes=%d-", o.offset))
+		resp, err := o.s.do(&s3Request{method: "GET", key: o.key, header: header})
+		if err != nil {
+			return 0, err
+		}
+		o.body = resp.Body
+	}
+
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += o.offset
+	case io.SeekEnd:
+		offset += o.info.Size()
+	}
+	if offset < 0 {
+		return o.offset, &os.PathError{Op: "seek", Path: o.key, Err: os.ErrInvalid}
+	}
+
+	if offset != o.offset && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.offset = offset
+	return offset, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body != nil {
+		return o.body.Close()
+	}
+	return nil
+}
+
+func (o *s3Object) Stat() (os.FileInfo, error) {
+	return o.info, nil
+}
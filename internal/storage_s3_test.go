@@ -0,0 +1,461 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// s3Stub is a minimal S3-compatible server that keeps objects in memory
+// and rejects requests without a valid AWS Signature Version 4
+type s3Stub struct {
+	bucket    string
+	accessKey string
+	secretKey string
+	region    string
+	pageSize  int // max number of listed keys per response (to test continuation)
+
+	mu         sync.Mutex
+	objects    map[string][]byte
+	uploads    map[string]map[int][]byte // parts of the multipart uploads in progress
+	nextUpload int
+	downloaded int // number of object bytes sent in GET responses
+	signed     int
+	denied     []error
+}
+
+func newS3Stub() *s3Stub {
+	return &s3Stub{
+		bucket:    "bucket",
+		accessKey: "AKIDEXAMPLE",
+		secretKey: "secret/key+example",
+		region:    "eu-test-1",
+		pageSize:  2,
+		objects:   make(map[string][]byte),
+		uploads:   make(map[string]map[int][]byte),
+	}
+}
+
+func s3Escape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+func signingHMAC(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// verifySignature recomputes the signature of a request from what the server received
+func (stub *s3Stub) verifySignature(r *http.Request, body []byte) error {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") {
+		return fmt.Errorf("unexpected authorization: %q", auth)
+	}
+	fields := make(map[string]string)
+	for _, field := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(kv) == 2 {
+			fields[kv[0]] = kv[1]
+		}
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	signTime, err := time.Parse("20060102T150405Z", amzDate)
+	if err != nil {
+		return fmt.Errorf("invalid X-Amz-Date: %q", amzDate)
+	}
+	if d := time.Since(signTime); d > time.Minute || d < -time.Minute {
+		return fmt.Errorf("request time is off by %v", d)
+	}
+	scope := amzDate[:8] + "/" + stub.region + "/s3/aws4_request"
+	if fields["Credential"] != stub.accessKey+"/"+scope {
+		return fmt.Errorf("unexpected credential: %q", fields["Credential"])
+	}
+
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if sum := sha256.Sum256(body); payloadHash != hex.EncodeToString(sum[:]) {
+		return fmt.Errorf("payload hash mismatch: %q", payloadHash)
+	}
+
+	signedHeaders := strings.Split(fields["SignedHeaders"], ";")
+	for _, required := range []string{"host", "x-amz-content-sha256", "x-amz-date"} {
+		found := false
+		for _, name := range signedHeaders {
+			found = found || name == required
+		}
+		if !found {
+			return fmt.Errorf("header %s isn't signed", required)
+		}
+	}
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		value := strings.Join(r.Header[http.CanonicalHeaderKey(name)], ",")
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	query := r.URL.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var params []string
+	for _, key := range keys {
+		for _, value := range query[key] {
+			params = append(params, s3Escape(key)+"="+s3Escape(value))
+		}
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		strings.Join(params, "&"),
+		canonicalHeaders.String(),
+		fields["SignedHeaders"],
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := signingHMAC([]byte("AWS4"+stub.secretKey), amzDate[:8])
+	key = signingHMAC(key, stub.region)
+	key = signingHMAC(key, "s3")
+	key = signingHMAC(key, "aws4_request")
+	if signature := hex.EncodeToString(signingHMAC(key, stringToSign)); fields["Signature"] != signature {
+		return fmt.Errorf("signature mismatch for canonical request:\n%s", canonicalRequest)
+	}
+	return nil
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+}
+
+func (stub *s3Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	if err := stub.verifySignature(r, body); err != nil {
+		stub.mu.Lock()
+		stub.denied = append(stub.denied, fmt.Errorf("%s %s: %v", r.Method, r.URL, err))
+		stub.mu.Unlock()
+		writeS3Error(w, http.StatusForbidden, "SignatureDoesNotMatch")
+		return
+	}
+
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	stub.signed++
+
+	bucketPrefix := "/" + stub.bucket
+	if r.URL.Path != bucketPrefix && !strings.HasPrefix(r.URL.Path, bucketPrefix+"/") {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, bucketPrefix), "/")
+	if query := r.URL.Query(); len(query.Get("uploadId")) > 0 || r.Method == "POST" {
+		stub.multipart(w, r, key, body)
+		return
+	}
+
+	switch {
+	case r.Method == "GET" && len(key) == 0 && r.URL.Query().Get("list-type") == "2":
+		stub.list(w, r.URL.Query())
+
+	case r.Method == "GET" || r.Method == "HEAD":
+		data, ok := stub.objects[key]
+		if !ok {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		status := http.StatusOK
+		if rng := r.Header.Get("Range"); len(rng) > 0 {
+			start, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+			if err != nil || start > len(data) {
+				writeS3Error(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+				return
+			}
+			data = data[start:]
+			status = http.StatusPartialContent
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(status)
+		if r.Method == "GET" {
+			stub.downloaded += len(data)
+			w.Write(data)
+		}
+
+	case r.Method == "PUT":
+		if source := r.Header.Get("X-Amz-Copy-Source"); len(source) > 0 {
+			source, _ = url.PathUnescape(source)
+			data, ok := stub.objects[strings.TrimPrefix(source, bucketPrefix+"/")]
+			if !ok {
+				writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+				return
+			}
+			stub.objects[key] = append([]byte(nil), data...)
+			fmt.Fprint(w, "<CopyObjectResult></CopyObjectResult>")
+			return
+		}
+		stub.objects[key] = body
+
+	case r.Method == "DELETE":
+		delete(stub.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// multipart implements the multipart upload requests (part copies only support whole objects)
+func (stub *s3Stub) multipart(w http.ResponseWriter, r *http.Request, key string, body []byte) {
+	query := r.URL.Query()
+	uploadID := query.Get("uploadId")
+	parts, ok := stub.uploads[uploadID]
+	if _, initiate := query["uploads"]; r.Method == "POST" && initiate {
+		stub.nextUpload++
+		uploadID = strconv.Itoa(stub.nextUpload)
+		stub.uploads[uploadID] = make(map[int][]byte)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", uploadID)
+		return
+	}
+	if !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+
+	switch r.Method {
+	case "PUT":
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		etag := strconv.Quote(fmt.Sprintf("part%d", partNumber))
+		if source := r.Header.Get("X-Amz-Copy-Source"); len(source) > 0 {
+			source, _ = url.PathUnescape(source)
+			data, ok := stub.objects[strings.TrimPrefix(source, "/"+stub.bucket+"/")]
+			if !ok {
+				writeS3Error(w, http.StatusNotFound, "NoSuchKey")
+				return
+			}
+			parts[partNumber] = data
+			fmt.Fprintf(w, "<CopyPartResult><ETag>%s</ETag></CopyPartResult>", etag)
+			return
+		}
+		parts[partNumber] = body
+		w.Header().Set("ETag", etag)
+
+	case "POST":
+		var complete struct {
+			Parts []struct {
+				PartNumber int    `xml:"PartNumber"`
+				ETag       string `xml:"ETag"`
+			} `xml:"Part"`
+		}
+		if err := xml.Unmarshal(body, &complete); err != nil || len(complete.Parts) == 0 {
+			writeS3Error(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		var data []byte
+		for i, part := range complete.Parts {
+			partData, ok := parts[part.PartNumber]
+			if !ok || part.ETag != strconv.Quote(fmt.Sprintf("part%d", part.PartNumber)) {
+				writeS3Error(w, http.StatusBadRequest, "InvalidPart")
+				return
+			}
+			if i < len(complete.Parts)-1 && len(partData) < s3MinPartSize {
+				writeS3Error(w, http.StatusBadRequest, "EntityTooSmall")
+				return
+			}
+			data = append(data, partData...)
+		}
+		stub.objects[key] = data
+		delete(stub.uploads, uploadID)
+		fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+
+	case "DELETE":
+		delete(stub.uploads, uploadID)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// list implements ListObjectsV2 with a "/" delimiter
+func (stub *s3Stub) list(w http.ResponseWriter, query url.Values) {
+	prefix := query.Get("prefix")
+	maxKeys := stub.pageSize
+	if n, err := strconv.Atoi(query.Get("max-keys")); err == nil && n < maxKeys {
+		maxKeys = n
+	}
+
+	type entry struct {
+		key      string
+		isPrefix bool
+	}
+	seen := make(map[string]bool)
+	var entries []entry
+	for key := range stub.objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		e := entry{key: key}
+		if i := strings.Index(key[len(prefix):], "/"); i >= 0 {
+			e = entry{key: key[:len(prefix)+i+1], isPrefix: true}
+		}
+		if !seen[e.key] {
+			seen[e.key] = true
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+	if token := query.Get("continuation-token"); len(token) > 0 {
+		for len(entries) > 0 && entries[0].key <= token {
+			entries = entries[1:]
+		}
+	}
+
+	type content struct {
+		Key          string `xml:"Key"`
+		Size         int    `xml:"Size"`
+		LastModified string `xml:"LastModified"`
+	}
+	type commonPrefix struct {
+		Prefix string `xml:"Prefix"`
+	}
+	var result struct {
+		XMLName               xml.Name       `xml:"ListBucketResult"`
+		Contents              []content      `xml:"Contents"`
+		CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+		IsTruncated           bool           `xml:"IsTruncated"`
+		NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	}
+	for i, e := range entries {
+		if i == maxKeys {
+			result.IsTruncated = true
+			result.NextContinuationToken = entries[i-1].key
+			break
+		}
+		if e.isPrefix {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: e.key})
+		} else {
+			result.Contents = append(result.Contents, content{
+				Key:          e.key,
+				Size:         len(stub.objects[e.key]),
+				LastModified: time.Now().UTC().Format(time.RFC3339),
+			})
+		}
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(&result)
+}
+
+func newTestS3Storage(t *testing.T) (*S3Storage, *s3Stub) {
+	stub := newS3Stub()
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	u, _ := url.Parse(server.URL)
+	s, err := NewS3Storage(fmt.Sprintf("s3+http://%s:%s@%s/%s/prefix dir?region=%s",
+		stub.accessKey, url.QueryEscape(stub.secretKey), u.Host, stub.bucket, stub.region))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, stub
+}
+
+func TestS3Storage(t *testing.T) {
+	s, stub := newTestS3Storage(t)
+	testStorage(t, s)
+
+	for _, err := range stub.denied {
+		t.Error(err)
+	}
+	if stub.signed == 0 {
+		t.Error("no signed request reached the stub")
+	}
+	for key := range stub.objects {
+		if !strings.HasPrefix(key, "prefix dir/") {
+			t.Errorf("object %q is outside of the prefix", key)
+		}
+	}
+}
+
+func TestS3StorageWrongSecret(t *testing.T) {
+	s, stub := newTestS3Storage(t)
+	s.SecretKey = "wrong"
+
+	_, err := s.ReadFile("x")
+	if e, ok := err.(*ErrS3Request); !ok || e.StatusCode != http.StatusForbidden || e.S3Code != "SignatureDoesNotMatch" {
+		t.Errorf("ReadFile with a wrong secret returned %v", err)
+	}
+	if len(stub.denied) != 1 || stub.signed != 0 {
+		t.Errorf("stub denied %d and accepted %d requests", len(stub.denied), stub.signed)
+	}
+}
+
+func TestS3StorageAppend(t *testing.T) {
+	tests := []struct {
+		name           string
+		size           int // size of the existing object
+		wantDownloaded bool
+	}{
+		{"new object", -1, false},
+		{"small object", 10, true},
+		{"multipart", s3MinPartSize, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, stub := newTestS3Storage(t)
+			var content []byte
+			if tt.size >= 0 {
+				content = []byte(strings.Repeat("x", tt.size))
+				if err := s.WriteFile("a", content); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			n, err := s.Append("a", strings.NewReader("appended"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != int64(len("appended")) {
+				t.Errorf("appended %d bytes, want %d", n, len("appended"))
+			}
+			if downloaded := stub.downloaded > 0; downloaded != tt.wantDownloaded {
+				t.Errorf("downloaded = %t, want %t", downloaded, tt.wantDownloaded)
+			}
+			data, err := s.ReadFile("a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := string(content) + "appended"; string(data) != want {
+				t.Errorf("content has %d bytes, want %d", len(data), len(want))
+			}
+			if len(stub.uploads) > 0 {
+				t.Errorf("%d multipart uploads weren't completed", len(stub.uploads))
+			}
+			for _, err := range stub.denied {
+				t.Error(err)
+			}
+		})
+	}
+}
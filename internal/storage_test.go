@@ -0,0 +1,204 @@
+package internal
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// testStorage checks the behavior that every Storage implementation has to provide
+func testStorage(t *testing.T, s Storage) {
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	readFile := func(name string) string {
+		t.Helper()
+		data, err := s.ReadFile(name)
+		must(err)
+		return string(data)
+	}
+
+	must(s.MkdirAll("a/b"))
+
+	t.Run("Write and Open", func(t *testing.T) {
+		n, err := s.Write("a/x.bin", strings.NewReader("hello world"))
+		must(err)
+		if n != 11 {
+			t.Errorf("Write returned %d, want 11", n)
+		}
+
+		obj, err := s.Open("a/x.bin")
+		must(err)
+		defer obj.Close()
+		info, err := obj.Stat()
+		must(err)
+		if info.Size() != 11 {
+			t.Errorf("size = %d, want 11", info.Size())
+		}
+		if _, err := obj.Seek(6, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(obj)
+		must(err)
+		if string(data) != "world" {
+			t.Errorf("read %q after seek, want %q", data, "world")
+		}
+
+		if _, err := s.Open("a/missing"); !isNotExist(err) {
+			t.Errorf("Open of a missing object returned %v, want a not exist error", err)
+		}
+	})
+
+	t.Run("WriteFile replaces content", func(t *testing.T) {
+		must(s.WriteFile("a/b/c.json", []byte("first")))
+		must(s.WriteFile("a/b/c.json", []byte("second")))
+		if got := readFile("a/b/c.json"); got != "second" {
+			t.Errorf("ReadFile = %q, want %q", got, "second")
+		}
+	})
+
+	t.Run("Append", func(t *testing.T) {
+		for _, chunk := range []string{"ab", "", "cde"} {
+			n, err := s.Append("a/log", strings.NewReader(chunk))
+			must(err)
+			if n != int64(len(chunk)) {
+				t.Errorf("Append(%q) returned %d", chunk, n)
+			}
+		}
+		if got := readFile("a/log"); got != "abcde" {
+			t.Errorf("content = %q, want %q", got, "abcde")
+		}
+	})
+
+	t.Run("Link", func(t *testing.T) {
+		must(s.Link("a/x.bin", "a/b/y.bin"))
+		if got := readFile("a/b/y.bin"); got != "hello world" {
+			t.Errorf("linked content = %q", got)
+		}
+		if got := readFile("a/x.bin"); got != "hello world" {
+			t.Errorf("original content = %q", got)
+		}
+	})
+
+	t.Run("Rename", func(t *testing.T) {
+		must(s.Rename("a/b/y.bin", "a/b/z.bin"))
+		if _, err := s.Stat("a/b/y.bin"); !isNotExist(err) {
+			t.Errorf("Stat of the old name returned %v", err)
+		}
+		if got := readFile("a/b/z.bin"); got != "hello world" {
+			t.Errorf("renamed content = %q", got)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		entries, err := s.List("a")
+		must(err)
+		want := []struct {
+			name string
+			dir  bool
+		}{
+			{"b", true},
+			{"log", false},
+			{"x.bin", false},
+		}
+		if len(entries) != len(want) {
+			t.Fatalf("List returned %d entries, want %d", len(entries), len(want))
+		}
+		for i, w := range want {
+			if entries[i].Name() != w.name || entries[i].IsDir() != w.dir {
+				t.Errorf("entry %d = %s (dir: %t), want %s (dir: %t)",
+					i, entries[i].Name(), entries[i].IsDir(), w.name, w.dir)
+			}
+		}
+
+		info, err := s.Stat("a")
+		must(err)
+		if !info.IsDir() {
+			t.Error("Stat of a folder isn't a dir")
+		}
+		if _, err := s.List("missing"); !isNotExist(err) {
+			t.Errorf("List of a missing folder returned %v", err)
+		}
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		if err := s.Remove("a/b"); err == nil {
+			t.Error("Remove of a non-empty folder succeeded")
+		}
+		must(s.Remove("a/b/z.bin"))
+		must(s.Remove("a/b/c.json"))
+		must(s.Remove("a/b"))
+		if _, err := s.Stat("a/b"); !isNotExist(err) {
+			t.Errorf("Stat of a removed folder returned %v", err)
+		}
+		if err := s.Remove("a/missing"); !isNotExist(err) {
+			t.Errorf("Remove of a missing object returned %v", err)
+		}
+	})
+}
+
+func TestMemoryStorage(t *testing.T) {
+	testStorage(t, NewMemoryStorage())
+}
+
+func TestLocalStorage(t *testing.T) {
+	testStorage(t, NewLocalStorage(t.TempDir()))
+}
+
+func TestGuardWrites(t *testing.T) {
+	root := "mem://" + t.Name()
+	s, err := GetStorage(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"a/b", "ab"} {
+		if err := s.MkdirAll(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	errDenied := errors.New("denied")
+	var commits []bool
+	deny := func(commit bool) error {
+		commits = append(commits, commit)
+		return errDenied
+	}
+	allow := func(commit bool) error { return nil }
+
+	unguardA := GuardWrites(root, "a", deny)
+	unguardB := GuardWrites(root, "a/b", allow)
+
+	tests := []struct {
+		name   string
+		write  func() error
+		want   error
+		commit bool
+	}{
+		{"folder", func() error { return s.WriteFile("a/x.bin", nil) }, errDenied, false},
+		{"subfolder", func() error { return s.WriteFile("a/c/x.json", nil) }, errDenied, true},
+		{"config", func() error { return s.WriteFile("a/.razbox", nil) }, errDenied, true},
+		{"rename out", func() error { return s.Rename("a/x.bin", "x.bin") }, errDenied, false},
+		{"mkdir", func() error { return s.MkdirAll("a/c") }, errDenied, false},
+		{"guarded subfolder", func() error { return s.WriteFile("a/b/x.json", nil) }, nil, false},
+		{"other folder", func() error { return s.WriteFile("ab/x.json", nil) }, nil, false},
+	}
+	for _, tt := range tests {
+		commits = nil
+		if err := tt.write(); err != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+		if tt.want != nil && (len(commits) != 1 || commits[0] != tt.commit) {
+			t.Errorf("%s: guard called with %v, want [%v]", tt.name, commits, tt.commit)
+		}
+	}
+
+	unguardA()
+	unguardB()
+	if err := s.WriteFile("a/x.bin", nil); err != nil {
+		t.Errorf("write after unguard failed: %v", err)
+	}
+}
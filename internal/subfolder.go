@@ -0,0 +1,209 @@
+package internal
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// HasNestedConfig returns whether a folder or any of its subfolders has its own config
+func HasNestedConfig(root, relPath string) bool {
+	found := false
+	walkStorage(storage(root), relPath, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && isInternalFolderName(info.Name()) {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && info.Name() == ".razbox" {
+			found = true
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return found
+}
+
+// GetFolderTree returns a folder and all of its subfolders (parents first)
+func GetFolderTree(root, relPath string) ([]string, error) {
+	var dirs []string
+	err := walkStorage(storage(root), relPath, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if isInternalFolderName(info.Name()) {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, name)
+		return nil
+	})
+	return dirs, err
+}
+
+func replacePathPrefix(p, oldPrefix, newPrefix string) string {
+	if p == oldPrefix {
+		return newPrefix
+	}
+	if strings.HasPrefix(p, oldPrefix+"/") {
+		return newPrefix + p[len(oldPrefix):]
+	}
+	return p
+}
+
+// MoveFolderTree moves a subfolder of this folder's config root (with its files, versions and subfolders)
+// to a new path in the same config root, and updates the trashed files and share links that refer to it
+func (f *Folder) MoveFolderTree(oldRelPath, newRelPath string) error {
+	s := storage(f.Root)
+	if _, err := s.Stat(newRelPath); err == nil {
+		return &os.PathError{Op: "move", Path: newRelPath, Err: os.ErrExist}
+	}
+
+	var files, dirs []string
+	err := walkStorage(s, oldRelPath, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			dirs = append(dirs, name)
+		} else {
+			files = append(files, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+		if err := s.MkdirAll(replacePathPrefix(dir, oldRelPath, newRelPath)); err != nil {
+			return err
+		}
+	}
+	for _, name := range files {
+		newName := replacePathPrefix(name, oldRelPath, newRelPath)
+		if path.Ext(name) == ".json" {
+			// file metadata contains its own path
+			if err := moveMetadata(s, name, newName, oldRelPath, newRelPath); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := s.Rename(name, newName); err != nil {
+			return err
+		}
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := s.Remove(dirs[i]); err != nil && !isNotExist(err) {
+			return err
+		}
+	}
+
+	f.moveTrashedFiles(oldRelPath, newRelPath)
+	return f.moveShareLinks(oldRelPath, newRelPath)
+}
+
+func moveMetadata(s Storage, oldName, newName, oldPrefix, newPrefix string) error {
+	data, err := s.ReadFile(oldName)
+	if err != nil {
+		return err
+	}
+	var metadata map[string]interface{}
+	if json.Unmarshal(data, &metadata) == nil {
+		if relPath, ok := metadata["rel_path"].(string); ok {
+			metadata["rel_path"] = replacePathPrefix(relPath, oldPrefix, newPrefix)
+			data, _ = json.MarshalIndent(metadata, "", "  ")
+		}
+	}
+	if err := s.WriteFile(newName, data); err != nil {
+		return err
+	}
+	return s.Remove(oldName)
+}
+
+// moveTrashedFiles makes the trashed files of a moved folder restorable to the new location
+func (f *Folder) moveTrashedFiles(oldRelPath, newRelPath string) {
+	for _, t := range f.GetTrash() {
+		if !t.isDeletedFrom(oldRelPath) {
+			continue
+		}
+		t.Folder = replacePathPrefix(t.Folder, oldRelPath, newRelPath)
+		t.File.RelPath = replacePathPrefix(t.File.RelPath, oldRelPath, newRelPath)
+		t.save()
+	}
+}
+
+// moveShareLinks keeps the share links of a moved folder (and its files) working
+func (f *Folder) moveShareLinks(oldRelPath, newRelPath string) error {
+	configRoot, err := GetFolder(f.Root, f.ConfigRootFolder)
+	if err != nil {
+		return err
+	}
+	changed := false
+	for _, l := range configRoot.Config.ShareLinks {
+		if p := replacePathPrefix(l.Path, oldRelPath, newRelPath); p != l.Path {
+			l.Path = p
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	f.Config.ShareLinks = configRoot.Config.ShareLinks
+	return configRoot.save()
+}
+
+// TrashFolderTree moves every file of a subfolder (and its subfolders) to the trash of the config root
+// and removes the folders
+func (f *Folder) TrashFolderTree(relPath string) (trashed int, err error) {
+	dirs, err := GetFolderTree(f.Root, relPath)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, dir := range dirs {
+		sub, err := GetFolder(f.Root, dir)
+		if err != nil {
+			return trashed, err
+		}
+		for _, file := range sub.GetFiles() {
+			if err := sub.TrashFile(file); err != nil {
+				return trashed, err
+			}
+			trashed++
+		}
+	}
+
+	// partial uploads, audit logs and orphaned thumbnails are deleted
+	return trashed, RemoveFolderTree(f.Root, relPath)
+}
+
+// NewConfiguredSubfolder returns a subfolder of this folder that has its own config, which starts with
+// the settings of this folder and shares its size limit (the config is saved when its passwords are set)
+func (f *Folder) NewConfiguredSubfolder(subfolder string, parentManaged bool) *Folder {
+	relPath := path.Join(f.RelPath, subfolder)
+	return &Folder{
+		Root:             f.Root,
+		RelPath:          relPath,
+		ConfigRootFolder: relPath,
+		Config: FolderConfig{
+			MaxFileSizeMB:      f.Config.MaxFileSizeMB,
+			MaxFolderSizeMB:    f.Config.MaxFolderSizeMB,
+			Subfolders:         f.Config.Subfolders,
+			TrashRetentionDays: f.Config.TrashRetentionDays,
+			MaxFileVersions:    f.Config.MaxFileVersions,
+			Dedup:              f.Config.Dedup,
+			AllowedTypes:       f.Config.AllowedTypes,
+			BlockedTypes:       f.Config.BlockedTypes,
+			AllowedExtensions:  f.Config.AllowedExtensions,
+			BlockedExtensions:  f.Config.BlockedExtensions,
+			ParentManaged:      parentManaged,
+			SharedQuota:        true,
+		},
+	}
+}
@@ -0,0 +1,224 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TrashFolderName is the name of the hidden directory in config root folders that contains deleted files
+const TrashFolderName = ".trash"
+
+// DefaultTrashRetentionDays is used when the folder config doesn't specify the trash retention
+const DefaultTrashRetentionDays = 30
+
+// TrashedFile is a deleted file that can be restored until the trash retention period expires
+type TrashedFile struct {
+	ID      string    `json:"id"`
+	Root    string    `json:"root"`
+	Trash   string    `json:"trash"`
+	Folder  string    `json:"folder"`
+	File    *File     `json:"file"`
+	Deleted time.Time `json:"deleted"`
+}
+
+func getTrashedFile(root, trash, id string) (*TrashedFile, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, &ErrTrashedFileNotFound{ID: id}
+	}
+
+	data, err := storage(root).ReadFile(path.Join(trash, id+".json"))
+	if err != nil {
+		if isNotExist(err) {
+			return nil, &ErrTrashedFileNotFound{ID: id}
+		}
+		return nil, err
+	}
+
+	t := new(TrashedFile)
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, err
+	}
+	t.Root = root // overwrite root with possible new root
+	t.Trash = trash
+	t.File.Root = root
+	return t, nil
+}
+
+// isDeletedFrom returns whether the file was deleted from the given folder or its subfolders
+func (t *TrashedFile) isDeletedFrom(folder string) bool {
+	return t.Folder == folder || strings.HasPrefix(t.Folder, folder+"/")
+}
+
+// GetContentName returns the name of the trashed file content in the storage
+func (t *TrashedFile) GetContentName() string {
+	if len(t.File.Blob) > 0 {
+		return getBlobName(t.File.Blob)
+	}
+	return path.Join(t.Trash, t.ID+".bin")
+}
+
+func (t *TrashedFile) save() error {
+	data, _ := json.MarshalIndent(t, "", "  ")
+	return storage(t.Root).WriteFile(path.Join(t.Trash, t.ID+".json"), data)
+}
+
+// Delete removes the trashed file permanently
+func (t *TrashedFile) Delete() error {
+	s := storage(t.Root)
+	deleteVersions(s, path.Join(t.Trash, t.ID))
+	if len(t.File.Blob) > 0 {
+		unrefBlob(s, t.File.Blob)
+	} else {
+		_ = s.Remove(t.GetContentName())
+	}
+	return s.Remove(path.Join(t.Trash, t.ID+".json"))
+}
+
+// GetTrashRetention returns how long deleted files are kept in the trash
+func (f *Folder) GetTrashRetention() time.Duration {
+	days := f.Config.TrashRetentionDays
+	if days == 0 {
+		days = DefaultTrashRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func (f *Folder) getTrashDir() string {
+	return path.Join(f.ConfigRootFolder, TrashFolderName)
+}
+
+// TrashFile moves a file of the folder to the trash of its config root folder.
+// The file is deleted permanently if the trash is disabled (negative retention).
+func (f *Folder) TrashFile(file *File) error {
+	if f.Config.TrashRetentionDays < 0 {
+		return file.Delete()
+	}
+
+	s := storage(f.Root)
+	trash := f.getTrashDir()
+	if err := s.MkdirAll(trash); err != nil {
+		return err
+	}
+
+	t := &TrashedFile{
+		ID:      uuid.New().String(),
+		Root:    f.Root,
+		Trash:   trash,
+		Folder:  f.RelPath,
+		File:    file,
+		Deleted: time.Now(),
+	}
+	if err := t.save(); err != nil {
+		return err
+	}
+
+	if len(file.Blob) == 0 {
+		err := s.Rename(file.GetContentName(), t.GetContentName())
+		if err != nil {
+			s.Remove(path.Join(trash, t.ID+".json"))
+			return err
+		}
+	}
+
+	_ = s.Remove(file.RelPath + ".thumb")
+	_ = s.Remove(file.RelPath + ".json")
+	if err := moveVersions(s, file.RelPath, path.Join(trash, t.ID)); err != nil {
+		log.Print("TrashFile error:", err)
+	}
+	return nil
+}
+
+// GetTrash returns the trashed files that were deleted from this folder or its subfolders (newest first)
+func (f *Folder) GetTrash() []*TrashedFile {
+	trash := f.getTrashDir()
+	filenames := globStorage(storage(f.Root), trash, "????????-????-????-????-????????????.json")
+	files := make([]*TrashedFile, 0, len(filenames))
+	for _, filename := range filenames {
+		id := strings.TrimSuffix(path.Base(filename), ".json")
+		t, err := getTrashedFile(f.Root, trash, id)
+		if err != nil {
+			log.Print("GetTrashedFile error:", err)
+			continue
+		}
+		if !t.isDeletedFrom(f.RelPath) {
+			continue
+		}
+		files = append(files, t)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Deleted.After(files[j].Deleted)
+	})
+	return files
+}
+
+// RestoreTrashedFile moves a trashed file back to its original folder
+func (f *Folder) RestoreTrashedFile(id string) (*File, error) {
+	t, err := getTrashedFile(f.Root, f.getTrashDir(), id)
+	if err != nil {
+		return nil, err
+	}
+	if !t.isDeletedFrom(f.RelPath) {
+		return nil, &ErrTrashedFileNotFound{ID: id}
+	}
+
+	s := storage(f.Root)
+	if err := s.MkdirAll(t.Folder); err != nil {
+		return nil, err
+	}
+
+	file := t.File
+	file.RelPath = path.Join(t.Folder, FilenameToUUID(file.Name))
+	file.Thumbnail = nil
+	if err := file.Create(nil, false); err != nil {
+		return nil, err
+	}
+
+	if len(file.Blob) == 0 {
+		err = s.Rename(t.GetContentName(), file.GetContentName())
+		if err != nil {
+			_ = s.Remove(file.RelPath + ".json")
+			return nil, err
+		}
+	}
+
+	_ = s.Remove(path.Join(t.Trash, t.ID+".json"))
+	if err := moveVersions(s, path.Join(t.Trash, t.ID), file.RelPath); err != nil {
+		log.Print("RestoreTrashedFile error:", err)
+	}
+	return file, nil
+}
+
+// PurgeTrash permanently deletes the trashed files that are older than the trash retention
+// (until the context is done)
+func (f *Folder) PurgeTrash(ctx context.Context) (purged int) {
+	trash := f.getTrashDir()
+	filenames := globStorage(storage(f.Root), trash, "????????-????-????-????-????????????.json")
+	deadline := time.Now().Add(-f.GetTrashRetention())
+	for _, filename := range filenames {
+		if ctx.Err() != nil {
+			return // the folder isn't locked anymore
+		}
+		id := strings.TrimSuffix(path.Base(filename), ".json")
+		t, err := getTrashedFile(f.Root, trash, id)
+		if err != nil {
+			log.Print("GetTrashedFile error:", err)
+			continue
+		}
+		if f.Config.TrashRetentionDays >= 0 && t.Deleted.After(deadline) {
+			continue
+		}
+		if err := t.Delete(); err != nil {
+			log.Print("PurgeTrash error:", err)
+			continue
+		}
+		purged++
+	}
+	return
+}
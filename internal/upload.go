@@ -0,0 +1,262 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PartialUploadLifetime is how long a partial upload can be resumed before it's deleted
+const PartialUploadLifetime = 24 * time.Hour
+
+// PartialUpload is a resumable upload that is stored next to the files of a folder
+// until all of its content arrives
+type PartialUpload struct {
+	ID        string    `json:"id"`
+	Root      string    `json:"-"`
+	Folder    string    `json:"folder"`
+	Filename  string    `json:"filename"`
+	Length    int64     `json:"length"`
+	Tags      []string  `json:"tags"`
+	Public    bool      `json:"public"`
+	Overwrite bool      `json:"overwrite"`
+	Uploader  string    `json:"uploader,omitempty"`
+	Creator   string    `json:"creator,omitempty"` // identifies the session that can resume the upload
+	Created   time.Time `json:"created"`
+	Expires   time.Time `json:"expires"`
+	// the SHA-256 state of the content received so far, so it doesn't have to be read again
+	HashState  []byte `json:"hash_state,omitempty"`
+	HashOffset int64  `json:"hash_offset,omitempty"`
+}
+
+// NewPartialUpload creates a new empty partial upload in the given folder
+func NewPartialUpload(root, folder, filename string, length int64) (*PartialUpload, error) {
+	now := time.Now()
+	u := &PartialUpload{
+		ID:       uuid.New().String(),
+		Root:     root,
+		Folder:   folder,
+		Filename: filename,
+		Length:   length,
+		Created:  now,
+		Expires:  now.Add(PartialUploadLifetime),
+	}
+
+	s := storage(root)
+	if err := s.WriteFile(u.GetPartName(), nil); err != nil {
+		return nil, err
+	}
+
+	if err := u.Save(); err != nil {
+		s.Remove(u.GetPartName())
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// GetPartialUpload returns an existing partial upload
+func GetPartialUpload(root, folder, id string) (*PartialUpload, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, err
+	}
+
+	data, err := storage(root).ReadFile(path.Join(folder, id+".tus"))
+	if err != nil {
+		return nil, err
+	}
+
+	u := new(PartialUpload)
+	if err := json.Unmarshal(data, u); err != nil {
+		return nil, err
+	}
+	u.Root = root
+	u.Folder = folder
+	if u.Expires.IsZero() {
+		u.Expires = u.Created.Add(PartialUploadLifetime)
+	}
+	return u, nil
+}
+
+// IsExpired returns true if the upload can't be resumed anymore
+func (u *PartialUpload) IsExpired() bool {
+	return time.Now().After(u.Expires)
+}
+
+// GetPartName returns the storage name of the partial content
+func (u *PartialUpload) GetPartName() string {
+	return path.Join(u.Folder, u.ID+".part")
+}
+
+// Save ...
+func (u *PartialUpload) Save() error {
+	data, _ := json.MarshalIndent(u, "", "  ")
+	return storage(u.Root).WriteFile(path.Join(u.Folder, u.ID+".tus"), data)
+}
+
+// Offset returns the number of bytes received so far
+func (u *PartialUpload) Offset() (int64, error) {
+	fi, err := storage(u.Root).Stat(u.GetPartName())
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// Append appends content to the partial upload without exceeding its length
+// (and saves the hash state of the content received so far)
+func (u *PartialUpload) Append(content io.Reader) (offset int64, err error) {
+	s := storage(u.Root)
+	fi, err := s.Stat(u.GetPartName())
+	if err != nil {
+		return 0, err
+	}
+
+	hasher, err := u.getHasher(s, fi.Size())
+	if err != nil {
+		return fi.Size(), err
+	}
+	n, err := s.Append(u.GetPartName(), io.TeeReader(io.LimitReader(content, u.Length-fi.Size()), hasher))
+	offset = fi.Size() + n
+	if err != nil {
+		// the hasher could have received more than what was stored, so it's restored from the content next time
+		return offset, err
+	}
+
+	u.HashState, err = hasher.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return offset, err
+	}
+	u.HashOffset = offset
+	return offset, u.Save()
+}
+
+// getHasher returns a SHA-256 hasher that already received the first offset bytes of the content,
+// either from the saved hash state or by reading the content again
+func (u *PartialUpload) getHasher(s Storage, offset int64) (hash.Hash, error) {
+	hasher := sha256.New()
+	if len(u.HashState) > 0 && u.HashOffset == offset {
+		if err := hasher.(encoding.BinaryUnmarshaler).UnmarshalBinary(u.HashState); err == nil {
+			return hasher, nil
+		}
+		hasher.Reset()
+	}
+	if offset == 0 {
+		return hasher, nil
+	}
+
+	part, err := s.Open(u.GetPartName())
+	if err != nil {
+		return nil, err
+	}
+	defer part.Close()
+	if _, err := io.CopyN(hasher, part, offset); err != nil {
+		return nil, err
+	}
+	return hasher, nil
+}
+
+// Stage returns the received content, so a file can be created from it without copying it
+func (u *PartialUpload) Stage() (*StagedContent, error) {
+	s := storage(u.Root)
+	fi, err := s.Stat(u.GetPartName())
+	if err != nil {
+		return nil, err
+	}
+	hasher, err := u.getHasher(s, fi.Size())
+	if err != nil {
+		return nil, err
+	}
+	return &StagedContent{
+		s:    s,
+		name: u.GetPartName(),
+		size: fi.Size(),
+		hash: hex.EncodeToString(hasher.Sum(nil)),
+	}, nil
+}
+
+// Open opens the partial content for reading
+func (u *PartialUpload) Open() (StorageObject, error) {
+	return storage(u.Root).Open(u.GetPartName())
+}
+
+// Delete ...
+func (u *PartialUpload) Delete() error {
+	s := storage(u.Root)
+	_ = s.Remove(path.Join(u.Folder, u.ID+".tus"))
+	return s.Remove(u.GetPartName())
+}
+
+// StagedContent is the content of a new file in a temporary object of its folder,
+// so the folder doesn't have to be locked while the content is being received.
+// Creating a file from it renames the temporary object instead of copying the content again.
+type StagedContent struct {
+	s    Storage
+	name string
+	size int64
+	hash string
+	r    StorageObject
+}
+
+// StageContent writes the content to a temporary object in the given folder
+func StageContent(root, folder string, content io.Reader) (*StagedContent, error) {
+	s := storage(root)
+	c := &StagedContent{
+		s:    s,
+		name: path.Join(folder, "razbox-upload-"+uuid.New().String()),
+	}
+
+	hasher := sha256.New()
+	n, err := s.Write(c.name, io.TeeReader(content, hasher))
+	if err != nil {
+		_ = s.Remove(c.name)
+		return nil, err
+	}
+	c.size = n
+	c.hash = hex.EncodeToString(hasher.Sum(nil))
+	return c, nil
+}
+
+// Size returns the size of the content
+func (c *StagedContent) Size() int64 {
+	return c.size
+}
+
+// Read reads the content from the temporary object (if it has to be copied after all)
+func (c *StagedContent) Read(p []byte) (int, error) {
+	if c.r == nil {
+		r, err := c.s.Open(c.name)
+		if err != nil {
+			return 0, err
+		}
+		c.r = r
+	}
+	return c.r.Read(p)
+}
+
+// moveTo renames the temporary object
+func (c *StagedContent) moveTo(name string) error {
+	if c.r != nil {
+		c.r.Close()
+		c.r = nil
+	}
+	return c.s.Rename(c.name, name)
+}
+
+// Close removes the temporary object (unless a file was created from it)
+func (c *StagedContent) Close() error {
+	if c.r != nil {
+		c.r.Close()
+	}
+	if err := c.s.Remove(c.name); err != nil && !isNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -1,31 +1,32 @@
 package internal
 
 import (
+	"bytes"
 	"crypto/md5"
+	"crypto/rand"
 	"crypto/sha1"
+	"encoding/binary"
 	"encoding/hex"
 	"io"
-	"math/rand"
 	"net/http"
-	"os"
 	"path"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/gabriel-vasile/mimetype"
 	"github.com/google/uuid"
 )
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
-}
-
 // Salt returns a random salt
 func Salt() string {
-	return strconv.FormatInt(rand.Int63(), 36)
+	var salt [8]byte
+	if _, err := rand.Read(salt[:]); err != nil {
+		panic(err)
+	}
+	return strconv.FormatUint(binary.LittleEndian.Uint64(salt[:]), 36)
 }
 
-// Hash returns the SHA1 hash of a string
+// Hash returns the SHA1 hash of a string (only used by legacy password hashes)
 func Hash(s string) string {
 	algorithm := sha1.New()
 	algorithm.Write([]byte(s))
@@ -42,9 +43,19 @@ func FilenameToUUID(filename string) string {
 	return uuid.Must(uuid.FromBytes(bytes)).String()
 }
 
+// IsReservedPath returns whether a relative path points inside a trash, blob store or quarantine directory
+func IsReservedPath(relPath string) bool {
+	for _, dir := range strings.Split(path.Clean(relPath), "/") {
+		if dir == TrashFolderName || dir == BlobFolderName || dir == QuarantineFolderName {
+			return true
+		}
+	}
+	return false
+}
+
 // IsFolder returns whether a relative path is a folder
 func IsFolder(root, relPath string) bool {
-	fi, err := os.Stat(path.Join(root, relPath))
+	fi, err := storage(root).Stat(relPath)
 	if err != nil {
 		return false
 	}
@@ -52,20 +63,39 @@ func IsFolder(root, relPath string) bool {
 	return fi.IsDir()
 }
 
+// sniffLen is the number of bytes used for MIME type detection (same as mimetype's read limit)
+const sniffLen = 3072
+
 // DetectContentType determines the MIME type of a given file
 func DetectContentType(r io.ReadSeeker) (string, error) {
 	r.Seek(0, io.SeekStart)
-	mime, err := mimetype.DetectReader(r)
+	header, err := readHeader(r)
+	return detectContentType(header), err
+}
+
+// SniffContentType determines the MIME type of a stream from its first bytes
+// and returns a reader that still yields the entire stream
+func SniffContentType(r io.Reader) (string, io.Reader, error) {
+	header, err := readHeader(r)
 	if err != nil {
-		return mime.String(), err
+		return "", nil, err
 	}
+	return detectContentType(header), io.MultiReader(bytes.NewReader(header), r), nil
+}
 
-	if mime.String() == "application/octet-stream" {
-		var header [512]byte
-		r.Seek(0, io.SeekStart)
-		_, _ = r.Read(header[:])
-		return http.DetectContentType(header[:]), nil
+func readHeader(r io.Reader) ([]byte, error) {
+	header := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, header)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
 	}
+	return header[:n], err
+}
 
-	return mime.String(), nil
+func detectContentType(header []byte) string {
+	mime := mimetype.Detect(header).String()
+	if mime == "application/octet-stream" {
+		return http.DetectContentType(header)
+	}
+	return mime
 }
@@ -0,0 +1,187 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxFileVersions is used when the folder config doesn't specify the number of kept file versions
+const DefaultMaxFileVersions = 5
+
+// previous versions of a file are stored next to it as <uuid>.v<N>.json and <uuid>.v<N>.bin
+func versionRelPath(relPath string, version int) string {
+	return fmt.Sprintf("%s.v%d", relPath, version)
+}
+
+func getVersionNumbers(s Storage, relPath string) []int {
+	prefix := relPath + ".v"
+	names := globStorage(s, path.Dir(relPath), path.Base(prefix)+"*.json")
+	versions := make([]int, 0, len(names))
+	for _, name := range names {
+		version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".json"))
+		if err != nil || version < 1 {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+	return versions
+}
+
+func moveVersions(s Storage, oldRelPath, newRelPath string) error {
+	for _, version := range getVersionNumbers(s, oldRelPath) {
+		oldPath := versionRelPath(oldRelPath, version)
+		newPath := versionRelPath(newRelPath, version)
+		if err := s.Rename(oldPath+".bin", newPath+".bin"); err != nil && !isNotExist(err) {
+			return err
+		}
+		if err := s.Rename(oldPath+".json", newPath+".json"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteVersion(s Storage, relPath string, version int) error {
+	versionPath := versionRelPath(relPath, version)
+	err := releaseContent(s, versionPath)
+	_ = s.Remove(versionPath + ".json")
+	return err
+}
+
+func deleteVersions(s Storage, relPath string) {
+	for _, version := range getVersionNumbers(s, relPath) {
+		deleteVersion(s, relPath, version)
+	}
+}
+
+// GetVersionNumbers returns the numbers of the kept previous versions of the file (newest first)
+func (f *File) GetVersionNumbers() []int {
+	return getVersionNumbers(storage(f.Root), f.RelPath)
+}
+
+// GetVersion returns a previous version of the file
+func (f *File) GetVersion(version int) (*File, error) {
+	relPath := versionRelPath(f.RelPath, version)
+	v, err := getFile(f.Root, relPath)
+	if err != nil {
+		return nil, &ErrFileVersionNotFound{File: f.Name, Version: version}
+	}
+	v.RelPath = relPath
+	return v, nil
+}
+
+// addVersion keeps the current content and metadata of the file as a new version
+func (f *File) addVersion() (int, error) {
+	version := 1
+	if versions := f.GetVersionNumbers(); len(versions) > 0 {
+		version = versions[0] + 1
+	}
+
+	s := storage(f.Root)
+	versionPath := versionRelPath(f.RelPath, version)
+	metadata, err := s.ReadFile(f.RelPath + ".json")
+	if err != nil {
+		return 0, err
+	}
+	if err := s.WriteFile(versionPath+".json", metadata); err != nil {
+		return 0, err
+	}
+
+	// the blob reference or hard link keeps the old content when the new content replaces it
+	if len(f.Blob) > 0 {
+		err = refBlob(s, f.Blob)
+	} else {
+		err = s.Link(f.RelPath+".bin", versionPath+".bin")
+	}
+	if err != nil {
+		s.Remove(versionPath + ".json")
+		return 0, err
+	}
+
+	return version, nil
+}
+
+func (f *File) pruneVersions(maxVersions int) {
+	s := storage(f.Root)
+	versions := getVersionNumbers(s, f.RelPath)
+	for i := maxVersions; i < len(versions); i++ {
+		deleteVersion(s, f.RelPath, versions[i])
+	}
+}
+
+// GetMaxFileVersions returns the number of previous versions kept of overwritten files
+func (f *Folder) GetMaxFileVersions() int {
+	switch {
+	case f.Config.MaxFileVersions == 0:
+		return DefaultMaxFileVersions
+	case f.Config.MaxFileVersions < 0:
+		return 0
+	default:
+		return f.Config.MaxFileVersions
+	}
+}
+
+// CreateFile creates a file in the folder (in the blob store if deduplication is enabled)
+// and keeps the previous content as a version if the file gets overwritten
+func (f *Folder) CreateFile(file *File, content io.Reader, overwrite bool) error {
+	create := file.Create
+	if f.Config.Dedup {
+		create = file.CreateDeduplicated
+	}
+
+	maxVersions := f.GetMaxFileVersions()
+	if !overwrite || maxVersions == 0 {
+		return create(content, overwrite)
+	}
+
+	old, err := getFile(f.Root, file.RelPath)
+	if err != nil {
+		return create(content, overwrite)
+	}
+	old.RelPath = file.RelPath
+
+	version, err := old.addVersion()
+	if err != nil {
+		return err
+	}
+
+	if err := create(content, true); err != nil {
+		old.Save() // the old content is still in place
+		deleteVersion(storage(f.Root), old.RelPath, version)
+		return err
+	}
+
+	file.pruneVersions(maxVersions)
+	return nil
+}
+
+// RestoreFileVersion makes a previous version the current content of the file
+// (and the current content becomes the newest version)
+func (f *Folder) RestoreFileVersion(file *File, version int) (*File, error) {
+	v, err := file.GetVersion(version)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := storage(f.Root).Open(v.GetContentName())
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	restored := *file
+	restored.MIME = v.MIME
+	restored.Size = v.Size
+	restored.Uploaded = time.Now()
+	restored.Thumbnail = nil
+	if err := f.CreateFile(&restored, content, true); err != nil {
+		return nil, err
+	}
+	return &restored, nil
+}
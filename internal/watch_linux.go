@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"io/ioutil"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const watchMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_CLOSE_WRITE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DONT_FOLLOW
+
+type localWatcher struct {
+	fd       int
+	dir      string
+	dirs     map[int32]string // watch descriptor -> folder
+	onChange func(folder string)
+}
+
+// WatchLocalRoot watches every folder of a local root with inotify, and calls onChange with the folders
+// whose files, subfolders or config (including an inherited one) changed. It blocks until an error happens.
+func WatchLocalRoot(root string, onChange func(folder string)) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	w := &localWatcher{
+		fd:       fd,
+		dir:      root,
+		dirs:     make(map[int32]string),
+		onChange: onChange,
+	}
+	w.addTree(".", false)
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := syscall.Read(fd, buf)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+int(event.Len)]), "\x00")
+			offset = nameStart + int(event.Len)
+			w.handleEvent(event.Wd, event.Mask, name)
+		}
+	}
+}
+
+func (w *localWatcher) handleEvent(wd int32, mask uint32, name string) {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		// events were lost, so anything could have changed
+		w.changedTree(".")
+		return
+	}
+	if mask&syscall.IN_IGNORED != 0 {
+		delete(w.dirs, wd)
+		return
+	}
+
+	folder, ok := w.dirs[wd]
+	if !ok {
+		return
+	}
+
+	if mask&syscall.IN_ISDIR != 0 {
+		if isInternalFolderName(name) {
+			return
+		}
+		subfolder := path.Join(folder, name)
+		switch {
+		case mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+			w.addTree(subfolder, true)
+		case mask&(syscall.IN_DELETE|syscall.IN_MOVED_FROM) != 0:
+			w.removeTree(subfolder)
+		}
+		w.onChange(folder)
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(name, "razbox-upload-"):
+		// temporary file
+	case name == ".razbox":
+		// the subfolders that inherit the config change too
+		w.changedTree(folder)
+	case path.Ext(name) == ".json":
+		w.onChange(folder)
+	}
+}
+
+// addTree watches a folder and its subfolders
+func (w *localWatcher) addTree(folder string, changed bool) {
+	wd, err := syscall.InotifyAddWatch(w.fd, filepath.Join(w.dir, filepath.FromSlash(folder)), watchMask)
+	if err != nil {
+		log.Print("failed to watch folder: ", folder, " ", err)
+		return
+	}
+	w.dirs[int32(wd)] = folder
+	if changed {
+		w.onChange(folder)
+	}
+
+	infos, _ := ioutil.ReadDir(filepath.Join(w.dir, filepath.FromSlash(folder)))
+	for _, info := range infos {
+		if info.IsDir() && !isInternalFolderName(info.Name()) {
+			w.addTree(path.Join(folder, info.Name()), changed)
+		}
+	}
+}
+
+// removeTree stops watching a folder and its subfolders (that were deleted or moved)
+func (w *localWatcher) removeTree(folder string) {
+	for wd, dir := range w.dirs {
+		if dir == folder || strings.HasPrefix(dir, folder+"/") {
+			syscall.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.dirs, wd)
+			w.onChange(dir)
+		}
+	}
+}
+
+func (w *localWatcher) changedTree(folder string) {
+	for _, dir := range w.dirs {
+		if folder == "." || dir == folder || strings.HasPrefix(dir, folder+"/") {
+			w.onChange(dir)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package internal
+
+// WatchLocalRoot is only supported on Linux
+func WatchLocalRoot(root string, onChange func(folder string)) error {
+	return &ErrWatchNotSupported{Root: root}
+}
@@ -0,0 +1,113 @@
+package razbox
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// folderLocks are read/write locks of config root folders.
+// Readers share a lock, writers get it exclusively in the order they arrive (new readers wait behind them),
+// and waiting can be canceled with a context.
+type folderLocks struct {
+	mu     sync.Mutex
+	locks  map[string]*folderLock
+	writes uint64 // number of released write locks and other changes (of any folder)
+}
+
+type folderLock struct {
+	readers        int
+	writer         bool
+	writersWaiting int
+	refs           int           // number of holders and waiters (the lock is dropped when it reaches zero)
+	changed        chan struct{} // closed when the lock is released or a waiting writer gives up
+}
+
+func (l *folderLock) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+func (locks *folderLocks) get(name string) *folderLock {
+	if locks.locks == nil {
+		locks.locks = make(map[string]*folderLock)
+	}
+	l, ok := locks.locks[name]
+	if !ok {
+		l = &folderLock{changed: make(chan struct{})}
+		locks.locks[name] = l
+	}
+	l.refs++
+	return l
+}
+
+func (locks *folderLocks) put(name string, l *folderLock) {
+	l.refs--
+	if l.refs == 0 {
+		delete(locks.locks, name)
+	}
+}
+
+// lock waits until it gets the lock of a folder or the context is done
+func (locks *folderLocks) lock(ctx context.Context, name string, write bool) (unlock func(), err error) {
+	locks.mu.Lock()
+	l := locks.get(name)
+	if write {
+		l.writersWaiting++
+	}
+	for {
+		if write && !l.writer && l.readers == 0 {
+			l.writersWaiting--
+			l.writer = true
+			break
+		}
+		if !write && !l.writer && l.writersWaiting == 0 {
+			l.readers++
+			break
+		}
+
+		changed := l.changed
+		locks.mu.Unlock()
+		select {
+		case <-changed:
+			locks.mu.Lock()
+		case <-ctx.Done():
+			locks.mu.Lock()
+			if write {
+				// readers might be waiting only because of this writer
+				l.writersWaiting--
+				l.notify()
+			}
+			locks.put(name, l)
+			locks.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+	locks.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			locks.mu.Lock()
+			defer locks.mu.Unlock()
+			if write {
+				l.writer = false
+				atomic.AddUint64(&locks.writes, 1)
+			} else {
+				l.readers--
+			}
+			l.notify()
+			locks.put(name, l)
+		})
+	}, nil
+}
+
+// addWrite counts a change of a folder that was made without a write lock
+func (locks *folderLocks) addWrite() {
+	atomic.AddUint64(&locks.writes, 1)
+}
+
+// getWriteCount returns the number of released write locks and other changes, which tells whether any folder could have changed
+func (locks *folderLocks) getWriteCount() uint64 {
+	return atomic.LoadUint64(&locks.writes)
+}
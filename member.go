@@ -0,0 +1,193 @@
+package razbox
+
+import (
+	"path"
+
+	"github.com/razzie/beepboop"
+	"github.com/razzie/razbox/internal"
+)
+
+// MemberInfo ...
+type MemberInfo struct {
+	Name    string `json:"name"`
+	Role    string `json:"role"`
+	Created int64  `json:"created"`
+}
+
+// AuthMember grants the session the access of a folder member
+func (api *API) AuthMember(pr *beepboop.PageRequest, folderName, name, password string) error {
+	sess := pr.Session()
+
+	if err := api.checkRateLimit("auth", sess.IP(), api.AuthsPerMin); err != nil {
+		return err
+	}
+
+	folder, unlock, cached, err := api.getFolder(sess, folderName)
+	if err != nil {
+		return err
+	}
+	if !cached {
+		defer api.goCacheFolder(folder)
+	}
+	defer unlock()
+
+	m, ok := folder.TestMemberPassword(name, password)
+	if !ok {
+		api.audit(sess, folder, internal.AuditAuthFailed, folder.RelPath, "member "+name)
+		return &ErrWrongPassword{}
+	}
+	api.audit(sess, folder, internal.AuditAuth, folder.RelPath, "member "+name)
+	return sess.MergeAccess(folder.GetMemberAccessToken(m))
+}
+
+// uncacheInheritedSubfolders makes the subfolders that inherit the config of a folder reload it
+// (so they don't keep permitting the access of removed members)
+func (api *API) uncacheInheritedSubfolders(folder *internal.Folder) {
+	if api.cache == nil {
+		return
+	}
+	for _, subfolder := range folder.GetSubfolders() {
+		sub, err := internal.GetFolder(api.root, path.Join(folder.RelPath, subfolder))
+		if err != nil || !sub.ConfigInherited {
+			continue
+		}
+		api.cache.TouchFolder(sub.RelPath)
+		api.uncacheInheritedSubfolders(sub)
+	}
+}
+
+// GetMembers ...
+func (api *API) GetMembers(sess Session, folderName string) ([]*MemberInfo, error) {
+	folder, unlock, cached, err := api.getFolder(sess, folderName)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		defer api.goCacheFolder(folder)
+	}
+	defer unlock()
+
+	err = folder.EnsureReadAccess(sess)
+	if err != nil {
+		return nil, &ErrNoReadAccess{Folder: folderName}
+	}
+
+	err = folder.EnsureAdminAccess(sess)
+	if err != nil {
+		return nil, &ErrNoWriteAccess{Folder: folderName}
+	}
+
+	members := make([]*MemberInfo, 0, len(folder.Config.Members))
+	for _, m := range folder.Config.Members {
+		members = append(members, &MemberInfo{
+			Name:    m.Name,
+			Role:    m.Role,
+			Created: m.Created.Unix(),
+		})
+	}
+	return members, nil
+}
+
+// AddMember adds a named member with its own password and role to the folder
+func (api *API) AddMember(sess Session, folderName, name, password, role string) error {
+	changed := false
+	folder, unlock, cached, err := api.getFolderForWrite(sess, folderName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if !cached || changed {
+			api.goCacheFolder(folder)
+		}
+	}()
+	defer unlock()
+
+	err = folder.EnsureReadAccess(sess)
+	if err != nil {
+		return &ErrNoReadAccess{Folder: folderName}
+	}
+
+	err = folder.EnsureAdminAccess(sess)
+	if err != nil {
+		return &ErrNoWriteAccess{Folder: folderName}
+	}
+
+	err = folder.AddMember(name, password, role)
+	if err != nil {
+		return err
+	}
+
+	changed = true
+	api.audit(sess, folder, internal.AuditMember, folder.RelPath, "added "+name+" as "+role)
+	return nil
+}
+
+// SetMemberRole ...
+func (api *API) SetMemberRole(sess Session, folderName, name, role string) error {
+	changed := false
+	folder, unlock, cached, err := api.getFolderForWrite(sess, folderName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if !cached || changed {
+			api.goCacheFolder(folder)
+		}
+	}()
+	defer unlock()
+
+	err = folder.EnsureReadAccess(sess)
+	if err != nil {
+		return &ErrNoReadAccess{Folder: folderName}
+	}
+
+	err = folder.EnsureAdminAccess(sess)
+	if err != nil {
+		return &ErrNoWriteAccess{Folder: folderName}
+	}
+
+	err = folder.SetMemberRole(name, role)
+	if err != nil {
+		return err
+	}
+
+	changed = true
+	api.audit(sess, folder, internal.AuditMember, folder.RelPath, "changed role of "+name+" to "+role)
+	api.uncacheInheritedSubfolders(folder)
+	return nil
+}
+
+// RemoveMember ...
+func (api *API) RemoveMember(sess Session, folderName, name string) error {
+	changed := false
+	folder, unlock, cached, err := api.getFolderForWrite(sess, folderName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if !cached || changed {
+			api.goCacheFolder(folder)
+		}
+	}()
+	defer unlock()
+
+	err = folder.EnsureReadAccess(sess)
+	if err != nil {
+		return &ErrNoReadAccess{Folder: folderName}
+	}
+
+	err = folder.EnsureAdminAccess(sess)
+	if err != nil {
+		return &ErrNoWriteAccess{Folder: folderName}
+	}
+
+	err = folder.RemoveMember(name)
+	if err != nil {
+		return err
+	}
+
+	changed = true
+	api.audit(sess, folder, internal.AuditMember, folder.RelPath, "removed "+name)
+	api.uncacheInheritedSubfolders(folder)
+	return nil
+}
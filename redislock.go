@@ -0,0 +1,184 @@
+package razbox
+
+import (
+	"context"
+	"log"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/google/uuid"
+)
+
+const (
+	redisLockTTL      = time.Second * 15
+	redisLockMaxRetry = time.Second
+)
+
+var (
+	redisLockAcquire = redis.NewScript(`
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0`)
+
+	redisLockRenew = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+	redisLockCheck = redis.NewScript(`
+if redis.call("GET", KEYS[2]) == ARGV[1] and redis.call("EXISTS", KEYS[1]) == 1 then
+	return 1
+end
+return 0`)
+
+	redisLockRelease = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("DEL", KEYS[1])
+	return redis.call("INCR", KEYS[2])
+end
+return 0`)
+)
+
+// redisLocks are exclusive leases of config root folders shared by every razbox instance using the same Redis.
+// A lease expires unless its holder renews it, so a crashed instance can't keep a folder locked.
+// Getting and releasing a lease both increment the fencing token of the folder, which is stored in cached folders
+// to tell whether the folder could have changed (in any instance) since it was cached.
+type redisLocks struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func newRedisLocks(client *redis.Client) *redisLocks {
+	return &redisLocks{
+		client: client,
+		ttl:    redisLockTTL,
+	}
+}
+
+func getLeaseKey(name string) string {
+	return "razbox-lease:" + name
+}
+
+func getFenceKey(name string) string {
+	return "razbox-fence:" + name
+}
+
+// lock waits until it gets the lease of a folder, the context is done or the timeout expires
+// (0 = no timeout, negative = no waiting).
+// The lease is renewed until it's released, and releasing it returns the new fencing token of the folder
+// (or 0 if the lease was lost in the meantime).
+// The returned context is derived from ctx (and not limited by the timeout), and it's canceled
+// when the lease is released or lost, so the holder can stop writing.
+// The returned fencing token identifies the lease for checkLease.
+func (locks *redisLocks) lock(ctx context.Context, name string, timeout time.Duration) (leaseCtx context.Context, token uint64, release func() (fence uint64), err error) {
+	id := uuid.New().String()
+	keys := []string{getLeaseKey(name), getFenceKey(name)}
+	ttl := int64(locks.ttl / time.Millisecond)
+
+	waitCtx, cancelWait := ctx, context.CancelFunc(func() {})
+	if timeout > 0 {
+		waitCtx, cancelWait = context.WithTimeout(ctx, timeout)
+	}
+	defer cancelWait()
+
+	retry := time.Millisecond * 20
+	for {
+		token, err = redisLockAcquire.Run(locks.client, keys, id, ttl).Uint64()
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		if token > 0 {
+			break
+		}
+		if timeout < 0 {
+			return nil, 0, nil, context.DeadlineExceeded
+		}
+		select {
+		case <-waitCtx.Done():
+			return nil, 0, nil, waitCtx.Err()
+		case <-time.After(retry):
+		}
+		if retry *= 2; retry > redisLockMaxRetry {
+			retry = redisLockMaxRetry
+		}
+	}
+
+	leaseCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(locks.ttl / 3)
+		defer ticker.Stop()
+		renewed := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ok, err := redisLockRenew.Run(locks.client, keys, id, ttl).Int64()
+				if err == nil && ok == 1 {
+					renewed = time.Now()
+					continue
+				}
+				// the lease expires if it can't be renewed in time
+				if err == nil || time.Since(renewed) >= locks.ttl {
+					log.Print("lost the lease of folder: ", name)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	return leaseCtx, token, func() uint64 {
+		close(done)
+		cancel()
+		fence, err := redisLockRelease.Run(locks.client, keys, id).Uint64()
+		if err != nil {
+			log.Print("failed to release the lease of folder: ", name, " ", err)
+			return 0
+		}
+		return fence
+	}, nil
+}
+
+// checkLease returns an error unless the lease with the given fencing token is still held,
+// which means that no other instance could have changed the folder since the lease was acquired
+func (locks *redisLocks) checkLease(name string, token uint64) error {
+	keys := []string{getLeaseKey(name), getFenceKey(name)}
+	ok, err := redisLockCheck.Run(locks.client, keys, strconv.FormatUint(token, 10)).Int64()
+	if err != nil {
+		return err
+	}
+	if ok != 1 {
+		return &ErrFolderLeaseLost{}
+	}
+	return nil
+}
+
+// getFences returns the fencing tokens of a folder and its parents (any of which can be its config root)
+func (locks *redisLocks) getFences(folderName string) (map[string]uint64, error) {
+	var names, keys []string
+	for name := path.Clean(folderName); ; name = path.Dir(name) {
+		names = append(names, name)
+		keys = append(keys, getFenceKey(name))
+		if name == "." || name == "/" {
+			break
+		}
+	}
+
+	values, err := locks.client.MGet(keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	fences := make(map[string]uint64, len(names))
+	for i, value := range values {
+		if value, ok := value.(string); ok {
+			fences[names[i]], _ = strconv.ParseUint(value, 10, 64)
+		}
+	}
+	return fences, nil
+}
@@ -0,0 +1,307 @@
+package razbox
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+	"github.com/razzie/razbox/internal"
+)
+
+func newTestRedisLocks(t *testing.T, ttl time.Duration) (*redisLocks, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	locks := newRedisLocks(client)
+	locks.ttl = ttl
+	return locks, mr
+}
+
+func TestRedisLockExclusive(t *testing.T) {
+	locks, _ := newTestRedisLocks(t, time.Second)
+
+	_, _, release, err := locks.lock(context.Background(), "a", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the lease of a different folder is independent
+	_, _, releaseB, err := locks.lock(context.Background(), "b", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	releaseB()
+
+	if _, _, _, err := locks.lock(context.Background(), "a", time.Millisecond*100); err != context.DeadlineExceeded {
+		t.Fatalf("got the lease of a held folder: %v", err)
+	}
+
+	// a waiting holder gets the lease as soon as it's released
+	acquired := make(chan func() uint64)
+	go func() {
+		_, _, release, err := locks.lock(context.Background(), "a", 0)
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- release
+	}()
+	time.Sleep(time.Millisecond * 50)
+	release()
+	select {
+	case release := <-acquired:
+		release()
+	case <-time.After(time.Second * 2):
+		t.Fatal("the released lease wasn't acquired")
+	}
+}
+
+func TestRedisLockFence(t *testing.T) {
+	locks, _ := newTestRedisLocks(t, time.Second)
+
+	var fences []uint64
+	for i := 0; i < 3; i++ {
+		_, _, release, err := locks.lock(context.Background(), "a/b", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fences = append(fences, release())
+	}
+	// both acquiring and releasing a lease increment the fencing token
+	for i, want := range []uint64{2, 4, 6} {
+		if fences[i] != want {
+			t.Errorf("fence %d = %d, want %d", i, fences[i], want)
+		}
+	}
+
+	tests := []struct {
+		folder string
+		want   map[string]uint64
+	}{
+		{"a/b", map[string]uint64{"a/b": 6}},
+		{"a/b/c/", map[string]uint64{"a/b": 6}},
+		{"x", map[string]uint64{}},
+	}
+	for _, tt := range tests {
+		got, err := locks.getFences(tt.folder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("getFences(%q) = %v, want %v", tt.folder, got, tt.want)
+		}
+		for name, fence := range tt.want {
+			if got[name] != fence {
+				t.Errorf("getFences(%q)[%q] = %d, want %d", tt.folder, name, got[name], fence)
+			}
+		}
+	}
+}
+
+func TestRedisLockRenew(t *testing.T) {
+	ttl := time.Millisecond * 300
+	locks, mr := newTestRedisLocks(t, ttl)
+
+	ctx, _, release, err := locks.lock(context.Background(), "a", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the lease is held longer than its TTL, so it has to be renewed
+	for i := 0; i < 4; i++ {
+		time.Sleep(ttl / 2)
+		mr.FastForward(ttl / 2)
+		if !mr.Exists(getLeaseKey("a")) {
+			t.Fatal("the lease expired while it was held")
+		}
+	}
+	if ctx.Err() != nil {
+		t.Fatal("the context of a held lease is canceled")
+	}
+	if fence := release(); fence != 2 {
+		t.Errorf("release returned fence %d, want 2", fence)
+	}
+	if ctx.Err() == nil {
+		t.Error("the context of a released lease isn't canceled")
+	}
+	if mr.Exists(getLeaseKey("a")) {
+		t.Error("the lease still exists after it was released")
+	}
+}
+
+func TestRedisLockLost(t *testing.T) {
+	ttl := time.Millisecond * 300
+	tests := []struct {
+		name   string
+		lose   func(mr *miniredis.Miniredis)
+		holder string // who holds the lease after it was lost
+	}{
+		// the lease expires (e.g. the holder was paused) and another instance gets it
+		{"taken over", func(mr *miniredis.Miniredis) { mr.Set(getLeaseKey("a"), "other holder") }, "other holder"},
+		// the lease can't be renewed before it expires
+		{"redis unavailable", func(mr *miniredis.Miniredis) { mr.Close() }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locks, mr := newTestRedisLocks(t, ttl)
+			ctx, _, release, err := locks.lock(context.Background(), "a", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tt.lose(mr)
+			select {
+			case <-ctx.Done():
+			case <-time.After(ttl * 3):
+				t.Fatal("the context wasn't canceled after the lease was lost")
+			}
+			if fence := release(); fence != 0 {
+				t.Errorf("release of a lost lease returned fence %d, want 0", fence)
+			}
+			if value, _ := mr.Get(getLeaseKey("a")); len(tt.holder) > 0 && value != tt.holder {
+				t.Errorf("release of a lost lease removed the lease of another holder: %q", value)
+			}
+		})
+	}
+}
+
+// newTestRedisAPI returns a test API that leases folders through a test Redis
+func newTestRedisAPI(t *testing.T, ttl time.Duration) (*API, *miniredis.Miniredis) {
+	t.Helper()
+	api := newTestAPI(t)
+	locks, mr := newTestRedisLocks(t, ttl)
+	api.redisLocks = locks
+	return api, mr
+}
+
+func TestLockFolderLeaseContext(t *testing.T) {
+	tests := []struct {
+		name        string
+		lockTimeout time.Duration
+	}{
+		{"no timeout", 0},
+		{"timeout", time.Millisecond * 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, _ := newTestRedisAPI(t, time.Second)
+			api.LockTimeout = tt.lockTimeout
+			folder, err := internal.GetFolder(api.root, "f")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, unlock, err := api.lockFolder(context.Background(), folder, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the lock timeout only limits waiting for the lock
+			time.Sleep(tt.lockTimeout + time.Millisecond*50)
+			if ctx.Err() != nil {
+				t.Fatalf("the context of a held lease is done: %v", ctx.Err())
+			}
+			unlock()
+			if ctx.Err() == nil {
+				t.Error("the context of a released lease isn't canceled")
+			}
+		})
+	}
+}
+
+func TestPurgeTrashWithLease(t *testing.T) {
+	api, _ := newTestRedisAPI(t, time.Second)
+	sess := newTestSession(t, api, "read", "write")
+	if err := writeTestUpload(t, api, sess, &ResumableUploadOptions{Folder: "f", Filename: "a.txt"}, "content"); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.DeleteFile(sess, "f/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the file was deleted before the trash retention
+	folder, err := internal.GetFolder(api.root, "f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trash := folder.GetTrash()
+	if len(trash) != 1 {
+		t.Fatalf("%d trashed files, want 1", len(trash))
+	}
+	trash[0].Deleted = time.Now().Add(-folder.GetTrashRetention() - time.Hour)
+	data, _ := json.Marshal(trash[0])
+	s, _ := internal.GetStorage(api.root)
+	if err := s.WriteFile(path.Join(trash[0].Trash, trash[0].ID+".json"), data); err != nil {
+		t.Fatal(err)
+	}
+
+	if purged, _ := api.PurgeTrash(); purged != 1 {
+		t.Errorf("purged %d files, want 1", purged)
+	}
+}
+
+func TestWriteAfterLeaseLost(t *testing.T) {
+	ttl := time.Millisecond * 300
+	tests := []struct {
+		name string
+		lose func(mr *miniredis.Miniredis)
+	}{
+		// another instance got the lease after it expired
+		{"taken over", func(mr *miniredis.Miniredis) {
+			mr.Set(getLeaseKey("f"), "other holder")
+			mr.Incr(getFenceKey("f"), 1)
+		}},
+		// the lease expired, but nobody else got it yet
+		{"expired", func(mr *miniredis.Miniredis) { mr.Del(getLeaseKey("f")) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, mr := newTestRedisAPI(t, ttl)
+			folder, err := internal.GetFolder(api.root, "f")
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, _ := internal.GetStorage(api.root)
+
+			ctx, unlock, err := api.lockFolder(context.Background(), folder, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer unlock()
+			if err := folder.SetReadPassword("read2"); err != nil {
+				t.Fatalf("write of the lease holder failed: %v", err)
+			}
+
+			// the config is only written if the lease is still held
+			tt.lose(mr)
+			if err := folder.SetReadPassword("read3"); !isErrFolderLeaseLost(err) {
+				t.Errorf("config write after the lease was lost returned %v", err)
+			}
+			if reloaded, _ := internal.GetFolder(api.root, "f"); reloaded == nil || !reloaded.TestReadPassword("read2") {
+				t.Error("the config was written after the lease was lost")
+			}
+
+			// every write is rejected once the holder knows that the lease was lost
+			select {
+			case <-ctx.Done():
+			case <-time.After(ttl * 3):
+				t.Fatal("the context wasn't canceled after the lease was lost")
+			}
+			if err := s.WriteFile("f/content.bin", []byte("content")); !isErrFolderLeaseLost(err) {
+				t.Errorf("content write after the lease was lost returned %v", err)
+			}
+
+			// the writes of other holders aren't affected
+			unlock()
+			if err := s.WriteFile("f/content.bin", []byte("content")); err != nil {
+				t.Errorf("write after the lease was released failed: %v", err)
+			}
+		})
+	}
+}
+
+func isErrFolderLeaseLost(err error) bool {
+	_, ok := err.(*ErrFolderLeaseLost)
+	return ok
+}
@@ -0,0 +1,323 @@
+package razbox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"time"
+
+	"github.com/razzie/razbox/internal"
+)
+
+// ResumableUploadOptions ...
+type ResumableUploadOptions struct {
+	Folder    string
+	Filename  string
+	Length    int64
+	Tags      []string
+	Overwrite bool
+	Public    bool
+}
+
+// ResumableUpload ...
+type ResumableUpload struct {
+	ID       string
+	Folder   string
+	Filename string
+	Offset   int64
+	Length   int64
+	Expires  time.Time
+}
+
+func newResumableUpload(u *internal.PartialUpload, offset int64) *ResumableUpload {
+	return &ResumableUpload{
+		ID:       u.ID,
+		Folder:   u.Folder,
+		Filename: u.Filename,
+		Offset:   offset,
+		Length:   u.Length,
+		Expires:  u.Expires,
+	}
+}
+
+// lockUpload makes sure that only one request writes a resumable upload at a time
+// (in every instance if the uploads are locked through Redis).
+// The returned context is canceled if the lease of the upload is lost.
+func (api *API) lockUpload(ctx context.Context, folderName, id string) (lockCtx context.Context, unlock func(), err error) {
+	key := path.Join(folderName, id)
+	if _, locked := api.uploadLock.LoadOrStore(key, nil); locked {
+		return nil, nil, &ErrUploadBusy{}
+	}
+	unlockLocal := func() {
+		api.uploadLock.Delete(key)
+	}
+	if api.redisLocks == nil {
+		return ctx, unlockLocal, nil
+	}
+
+	name := "upload:" + key
+	lockCtx, _, release, err := api.redisLocks.lock(ctx, name, -1)
+	if err != nil {
+		if err == context.DeadlineExceeded || err == context.Canceled {
+			unlockLocal()
+			return nil, nil, &ErrUploadBusy{}
+		}
+		// other instances aren't excluded until Redis is available again
+		log.Print("failed to lease upload: ", key, " ", err)
+		return ctx, unlockLocal, nil
+	}
+	return lockCtx, func() {
+		release()
+		// uploads don't need fencing tokens
+		api.redisLocks.client.Del(getFenceKey(name))
+		unlockLocal()
+	}, nil
+}
+
+// getUploadCreator identifies the session that creates a resumable upload,
+// so other sessions with upload access to the folder can't resume or delete it
+func getUploadCreator(sess Session, folder *internal.Folder) string {
+	if m := folder.GetSessionMember(sess); m != nil {
+		return "member:" + m.Name
+	}
+	if s, ok := sess.(*tokenSession); ok && len(s.tokenName) > 0 {
+		return "api-token:" + s.tokenName
+	}
+	if s, ok := sess.(interface{ SessionID() string }); ok && len(s.SessionID()) > 0 {
+		return "session:" + internal.Hash(s.SessionID())
+	}
+	return "ip:" + internal.Hash(sess.IP())
+}
+
+// leaseReader stops reading once the lease of an upload is lost
+type leaseReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *leaseReader) Read(p []byte) (int, error) {
+	if r.ctx.Err() != nil {
+		return 0, &ErrUploadBusy{}
+	}
+	return r.r.Read(p)
+}
+
+// CreateResumableUpload starts a new upload whose content can be sent in multiple requests
+func (api *API) CreateResumableUpload(sess Session, o *ResumableUploadOptions) (*ResumableUpload, error) {
+	folder, unlock, cached, err := api.getFolder(sess, o.Folder)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		defer api.goCacheFolder(folder)
+	}
+	defer unlock()
+
+	uploadOnly, err := ensureUploadAccess(sess, folder, o.Folder)
+	if err != nil {
+		return nil, err
+	}
+
+	if uploadOnly {
+		// upload-only sessions can't replace or publish files
+		o.Overwrite, o.Public = false, false
+	}
+
+	if o.Length < 0 {
+		return nil, &ErrInvalidUploadLength{}
+	}
+
+	if o.Length > folder.GetMaxUploadSizeMB()<<20 {
+		return nil, &ErrSizeLimitExceeded{}
+	}
+
+	filename, _ := getSafeFilename(o.Filename, internal.Salt())
+	// the content type is checked once the content arrives
+	if err := folder.CheckFileType(filename, ""); err != nil {
+		return nil, err
+	}
+	if !o.Overwrite {
+		if _, err := folder.GetFile(filename); err == nil {
+			return nil, &internal.ErrFileAlreadyExists{File: filename}
+		}
+	}
+
+	u, err := internal.NewPartialUpload(api.root, folder.RelPath, filename, o.Length)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Tags = o.Tags
+	u.Public = o.Public
+	u.Overwrite = o.Overwrite
+	u.Uploader = getUploader(sess, folder)
+	u.Creator = getUploadCreator(sess, folder)
+	if err := u.Save(); err != nil {
+		u.Delete()
+		return nil, err
+	}
+
+	return newResumableUpload(u, 0), nil
+}
+
+// GetResumableUpload returns the current state of a resumable upload
+func (api *API) GetResumableUpload(sess Session, folderName, id string) (*ResumableUpload, error) {
+	u, err := api.getPartialUpload(sess, folderName, id)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err := u.Offset()
+	if err != nil {
+		return nil, err
+	}
+
+	return newResumableUpload(u, offset), nil
+}
+
+// WriteResumableUpload appends content to a resumable upload at the given offset
+// and creates the file once the upload is complete
+func (api *API) WriteResumableUpload(sess Session, folderName, id string, offset int64, content io.Reader) (*ResumableUpload, error) {
+	ctx, unlock, err := api.lockUpload(sess.Context(), path.Clean(folderName), id)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	u, err := api.getPartialUpload(sess, folderName, id)
+	if err != nil {
+		return nil, err
+	}
+
+	currentOffset, err := u.Offset()
+	if err != nil {
+		return nil, err
+	}
+
+	if offset != currentOffset {
+		return nil, &ErrUploadOffsetMismatch{Offset: currentOffset}
+	}
+
+	newOffset, err := u.Append(&leaseReader{ctx: ctx, r: content})
+	if err != nil {
+		return newResumableUpload(u, newOffset), err
+	}
+
+	if newOffset == u.Length {
+		err = api.finishPartialUpload(sess, u)
+	}
+
+	return newResumableUpload(u, newOffset), err
+}
+
+// DeleteResumableUpload cancels a resumable upload and deletes its content
+func (api *API) DeleteResumableUpload(sess Session, folderName, id string) error {
+	_, unlock, err := api.lockUpload(sess.Context(), path.Clean(folderName), id)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	u, err := api.getPartialUpload(sess, folderName, id)
+	if err != nil {
+		return err
+	}
+	return u.Delete()
+}
+
+func (api *API) getPartialUpload(sess Session, folderName, id string) (*internal.PartialUpload, error) {
+	folder, cached, err := api.getFolderNoLock(folderName)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		defer api.goCacheFolder(folder)
+	}
+
+	_, err = ensureUploadAccess(sess, folder, folderName)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := internal.GetPartialUpload(api.root, folder.RelPath, id)
+	if err != nil || u.IsExpired() {
+		return nil, &ErrNotFound{} // expired uploads are deleted by the trash reaper
+	}
+	if len(u.Creator) > 0 && u.Creator != getUploadCreator(sess, folder) {
+		return nil, &ErrNotFound{}
+	}
+
+	return u, nil
+}
+
+func (api *API) finishPartialUpload(sess Session, u *internal.PartialUpload) error {
+	// the content type is detected before the folder is locked, because it reads the content
+	data, err := u.Open()
+	if err != nil {
+		return err
+	}
+	mime, err := internal.DetectContentType(data)
+	data.Close()
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	folder, unlock, cached, err := api.getFolderForWrite(sess, u.Folder)
+	if err != nil {
+		// the client can retry with an empty request once the folder is not busy
+		return err
+	}
+	defer func() {
+		if !cached || changed {
+			api.goCacheFolder(folder)
+		}
+	}()
+	defer unlock()
+	defer u.Delete()
+
+	// the folder could have changed since the upload was created
+	uploadOnly, err := ensureUploadAccess(sess, folder, u.Folder)
+	if err != nil {
+		return err
+	}
+	if uploadOnly {
+		// upload-only sessions can't replace or publish files
+		u.Overwrite, u.Public = false, false
+	}
+
+	if u.Length > folder.GetMaxUploadSizeMB()<<20 {
+		return &ErrSizeLimitExceeded{}
+	}
+	if err := folder.CheckFileType(u.Filename, mime); err != nil {
+		return err
+	}
+
+	// the content was hashed while it was received, and it's moved to its place instead of copied
+	content, err := u.Stage()
+	if err != nil {
+		return err
+	}
+
+	file := &internal.File{
+		Name:     u.Filename,
+		Root:     api.root,
+		RelPath:  path.Join(u.Folder, internal.FilenameToUUID(u.Filename)),
+		Tags:     u.Tags,
+		MIME:     mime,
+		Uploaded: time.Now(),
+		Uploader: u.Uploader,
+		Public:   u.Public,
+	}
+	err = folder.CreateFile(file, content, u.Overwrite)
+	if err != nil {
+		return err
+	}
+
+	api.cacheFile(folder, file)
+	changed = true
+	api.audit(sess, folder, internal.AuditUpload, path.Join(u.Folder, file.Name), fmt.Sprintf("%d bytes (resumable)", file.Size))
+	return nil
+}
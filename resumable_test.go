@@ -0,0 +1,321 @@
+package razbox
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/razzie/razbox/internal"
+)
+
+func writeTestUpload(t *testing.T, api *API, sess Session, o *ResumableUploadOptions, content string) error {
+	t.Helper()
+	o.Length = int64(len(content))
+	u, err := api.CreateResumableUpload(sess, o)
+	if err != nil {
+		return err
+	}
+	_, err = api.WriteResumableUpload(sess, o.Folder, u.ID, 0, strings.NewReader(content))
+	return err
+}
+
+func TestResumableUploadAccess(t *testing.T) {
+	tests := []struct {
+		name        string
+		access      []string
+		denied      bool
+		wantReplace bool // whether the session can replace and publish files
+	}{
+		{"read and write", []string{"read", "write"}, false, true},
+		{"upload only", []string{"upload"}, false, false},
+		{"read only", []string{"read"}, true, false},
+		{"no access", nil, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			owner := newTestSession(t, api, "read", "write")
+			if err := writeTestUpload(t, api, owner, &ResumableUploadOptions{Folder: "f", Filename: "old.txt"}, "old"); err != nil {
+				t.Fatal(err)
+			}
+
+			sess := newTestSession(t, api, tt.access...)
+			err := writeTestUpload(t, api, sess, &ResumableUploadOptions{Folder: "f", Filename: "new.txt", Public: true}, "new")
+			if tt.denied {
+				if _, ok := err.(*ErrNoUploadAccess); !ok {
+					t.Fatalf("got error %v, want ErrNoUploadAccess", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if public := getTestFile(t, api, "new.txt").Public; public != tt.wantReplace {
+				t.Errorf("public = %t, want %t", public, tt.wantReplace)
+			}
+
+			err = writeTestUpload(t, api, sess, &ResumableUploadOptions{Folder: "f", Filename: "old.txt", Overwrite: true}, "replaced")
+			if replaced := err == nil; replaced != tt.wantReplace {
+				t.Errorf("replaced = %t (%v), want %t", replaced, err, tt.wantReplace)
+			}
+		})
+	}
+}
+
+func TestExpiredResumableUpload(t *testing.T) {
+	tests := []struct {
+		name        string
+		expiresIn   time.Duration
+		wantExpired bool
+	}{
+		{"active", time.Hour, false},
+		{"expired", -time.Minute, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			sess := newTestSession(t, api, "read", "write")
+			upload, err := api.CreateResumableUpload(sess, &ResumableUploadOptions{Folder: "f", Filename: "a.txt", Length: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := api.WriteResumableUpload(sess, "f", upload.ID, 0, strings.NewReader("half")); err != nil {
+				t.Fatal(err)
+			}
+			u, err := internal.GetPartialUpload(api.root, "f", upload.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u.Expires = time.Now().Add(tt.expiresIn)
+			if err := u.Save(); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = api.GetResumableUpload(sess, "f", upload.ID)
+			if _, notFound := err.(*ErrNotFound); notFound != tt.wantExpired {
+				t.Errorf("got error %v, want not found = %t", err, tt.wantExpired)
+			}
+			if _, purged := api.PurgeTrash(); (purged == 1) != tt.wantExpired {
+				t.Errorf("purged %d uploads, want expired = %t", purged, tt.wantExpired)
+			}
+			s, err := internal.GetStorage(api.root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = s.Stat(u.GetPartName())
+			if kept := err == nil; kept == tt.wantExpired {
+				t.Errorf("content kept = %t after purge", kept)
+			}
+		})
+	}
+}
+
+func TestResumableUploadOffsets(t *testing.T) {
+	api := newTestAPI(t)
+	sess := newTestSession(t, api, "read", "write")
+	upload, err := api.CreateResumableUpload(sess, &ResumableUploadOptions{Folder: "f", Filename: "a.txt", Length: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the steps write the same upload one after another
+	tests := []struct {
+		name       string
+		offset     int64
+		content    string
+		wantOffset int64 // offset after the write (or the current offset reported by the mismatch error)
+		wantErr    bool
+	}{
+		{"first chunk", 0, "0123", 4, false},
+		{"repeated chunk", 0, "0123", 4, true},
+		{"skipped offset", 6, "67", 4, true},
+		{"next chunk", 4, "45", 6, false},
+		{"empty chunk", 6, "", 6, false},
+		{"last chunk exceeding the length", 6, "6789extra", 10, false},
+	}
+	for _, tt := range tests {
+		u, err := api.WriteResumableUpload(sess, "f", upload.ID, tt.offset, strings.NewReader(tt.content))
+		if tt.wantErr {
+			if e, ok := err.(*ErrUploadOffsetMismatch); !ok || e.Offset != tt.wantOffset {
+				t.Fatalf("%s: got error %v, want offset mismatch at %d", tt.name, err, tt.wantOffset)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if u.Offset != tt.wantOffset {
+			t.Fatalf("%s: offset = %d, want %d", tt.name, u.Offset, tt.wantOffset)
+		}
+		if current, err := api.GetResumableUpload(sess, "f", upload.ID); tt.wantOffset < 10 && (err != nil || current.Offset != tt.wantOffset) {
+			t.Fatalf("%s: got upload %v, error %v, want offset %d", tt.name, current, err, tt.wantOffset)
+		}
+	}
+
+	if content := readTestFile(t, getTestFile(t, api, "a.txt")); content != "0123456789" {
+		t.Errorf("content = %q, want %q", content, "0123456789")
+	}
+	if _, err := api.GetResumableUpload(sess, "f", upload.ID); err == nil {
+		t.Error("the finished upload can still be resumed")
+	}
+}
+
+func TestResumableUploadHash(t *testing.T) {
+	content := "0123456789"
+	sum := sha256.Sum256([]byte(content))
+	wantHash := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name   string
+		tamper func(u *internal.PartialUpload) // changes the hash state after the first chunk
+	}{
+		{"saved state", func(u *internal.PartialUpload) {}},
+		{"missing state", func(u *internal.PartialUpload) { u.HashState = nil }},
+		{"stale state", func(u *internal.PartialUpload) { u.HashOffset-- }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			sess := newTestSession(t, api, "read", "write")
+			upload, err := api.CreateResumableUpload(sess, &ResumableUploadOptions{Folder: "f", Filename: "a.txt", Length: int64(len(content))})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := api.WriteResumableUpload(sess, "f", upload.ID, 0, strings.NewReader(content[:4])); err != nil {
+				t.Fatal(err)
+			}
+			u, err := internal.GetPartialUpload(api.root, "f", upload.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.HashOffset != 4 || len(u.HashState) == 0 {
+				t.Fatalf("hash state saved at offset %d (%d bytes), want offset 4", u.HashOffset, len(u.HashState))
+			}
+			tt.tamper(u)
+			if err := u.Save(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := api.WriteResumableUpload(sess, "f", upload.ID, 4, strings.NewReader(content[4:])); err != nil {
+				t.Fatal(err)
+			}
+
+			file := getTestFile(t, api, "a.txt")
+			if file.Hash != wantHash {
+				t.Errorf("hash = %s, want %s", file.Hash, wantHash)
+			}
+			if got := readTestFile(t, file); got != content {
+				t.Errorf("content = %q, want %q", got, content)
+			}
+			// the content was moved to the file
+			s, _ := internal.GetStorage(api.root)
+			if _, err := s.Stat(u.GetPartName()); err == nil {
+				t.Error("the partial content still exists")
+			}
+		})
+	}
+}
+
+func TestResumableUploadCreator(t *testing.T) {
+	api := newTestAPI(t)
+	folder, err := internal.GetFolder(api.root, "f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	access, err := folder.GetAccessToken("upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newSession := func(tokenName string) Session {
+		return &tokenSession{ctx: context.Background(), ip: "127.0.0.1", access: access, tokenName: tokenName}
+	}
+	creator, other := newSession("creator"), newSession("other")
+
+	upload, err := api.CreateResumableUpload(creator, &ResumableUploadOptions{Folder: "f", Filename: "a.txt", Length: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// other sessions with upload access can't see, resume or cancel the upload
+	if _, err := api.GetResumableUpload(other, "f", upload.ID); !isErrNotFound(err) {
+		t.Errorf("HEAD of another session returned %v", err)
+	}
+	if _, err := api.WriteResumableUpload(other, "f", upload.ID, 0, strings.NewReader("01234")); !isErrNotFound(err) {
+		t.Errorf("PATCH of another session returned %v", err)
+	}
+	if err := api.DeleteResumableUpload(other, "f", upload.ID); !isErrNotFound(err) {
+		t.Errorf("DELETE of another session returned %v", err)
+	}
+
+	if u, err := api.WriteResumableUpload(creator, "f", upload.ID, 0, strings.NewReader("01234")); err != nil || u.Offset != 5 {
+		t.Fatalf("PATCH of the creator returned %v, %v", u, err)
+	}
+	if err := api.DeleteResumableUpload(creator, "f", upload.ID); err != nil {
+		t.Fatalf("DELETE of the creator returned %v", err)
+	}
+	if _, err := api.GetResumableUpload(creator, "f", upload.ID); !isErrNotFound(err) {
+		t.Errorf("HEAD of a deleted upload returned %v", err)
+	}
+}
+
+func TestResumableUploadFileType(t *testing.T) {
+	api := newTestAPI(t, func(config *internal.FolderConfig) {
+		config.BlockedExtensions = []string{"exe"}
+		config.AllowedTypes = []string{"text/*"}
+	})
+	sess := newTestSession(t, api, "read", "write")
+
+	// the extension is checked before the content arrives
+	_, err := api.CreateResumableUpload(sess, &ResumableUploadOptions{Folder: "f", Filename: "a.exe", Length: 10})
+	if _, ok := err.(*internal.ErrFileTypeNotAllowed); !ok {
+		t.Errorf("creating an upload with a blocked extension returned %v", err)
+	}
+	// and the content type once the content arrived
+	if err := writeTestUpload(t, api, sess, &ResumableUploadOptions{Folder: "f", Filename: "a.txt"}, "text"); err != nil {
+		t.Errorf("upload of an allowed type failed: %v", err)
+	}
+	err = writeTestUpload(t, api, sess, &ResumableUploadOptions{Folder: "f", Filename: "b.txt"}, "\x89PNG\r\n\x1a\n")
+	if _, ok := err.(*internal.ErrFileTypeNotAllowed); !ok {
+		t.Errorf("upload of a not allowed type returned %v", err)
+	}
+}
+
+func TestResumableUploadLease(t *testing.T) {
+	api, mr := newTestRedisAPI(t, time.Second)
+	sess := newTestSession(t, api, "read", "write")
+	upload, err := api.CreateResumableUpload(sess, &ResumableUploadOptions{Folder: "f", Filename: "a.txt", Length: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := "upload:" + path.Join("f", upload.ID)
+
+	// another instance is writing the upload
+	mr.Set(getLeaseKey(name), "other instance")
+	if _, err := api.WriteResumableUpload(sess, "f", upload.ID, 0, strings.NewReader("01234")); !isErrUploadBusy(err) {
+		t.Errorf("PATCH while another instance writes the upload returned %v", err)
+	}
+	if err := api.DeleteResumableUpload(sess, "f", upload.ID); !isErrUploadBusy(err) {
+		t.Errorf("DELETE while another instance writes the upload returned %v", err)
+	}
+
+	mr.Del(getLeaseKey(name))
+	if u, err := api.WriteResumableUpload(sess, "f", upload.ID, 0, strings.NewReader("01234")); err != nil || u.Offset != 5 {
+		t.Fatalf("PATCH after the other instance finished returned %v, %v", u, err)
+	}
+	if mr.Exists(getLeaseKey(name)) || mr.Exists(getFenceKey(name)) {
+		t.Error("the lease of the upload was kept after the request")
+	}
+}
+
+func isErrNotFound(err error) bool {
+	_, ok := err.(*ErrNotFound)
+	return ok
+}
+
+func isErrUploadBusy(err error) bool {
+	_, ok := err.(*ErrUploadBusy)
+	return ok
+}
@@ -0,0 +1,36 @@
+package razbox
+
+import (
+	"context"
+	"strings"
+
+	"github.com/razzie/razbox/internal"
+)
+
+// Scrub re-hashes every stored file content under the root and reports the results (see internal.Scrub).
+// Missing checksums are recorded if update is true.
+func (api *API) Scrub(update bool, report func(*internal.ScrubResult)) error {
+	return internal.Scrub(api.root, func(result *internal.ScrubResult) {
+		if update && result.Err == nil && len(result.Expected) == 0 && len(result.Filename) > 0 {
+			result = api.recordChecksum(result)
+		}
+		report(result)
+	})
+}
+
+// recordChecksum hashes a file again under the write lock of its folder (as it could have been replaced meanwhile)
+// and records its checksum
+func (api *API) recordChecksum(result *internal.ScrubResult) *internal.ScrubResult {
+	folder, unlock, _, err := api.getLockedFolder(context.Background(), result.Folder, true)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer unlock()
+
+	result = internal.ScrubFile(api.root, strings.TrimSuffix(result.Name, ".bin"), true)
+	if result.Updated {
+		api.uncacheFile(folder, result.Filename)
+	}
+	return result
+}
@@ -0,0 +1,67 @@
+package razbox
+
+import (
+	"testing"
+
+	"github.com/razzie/razbox/internal"
+)
+
+func TestScrubUpdatesCachedFiles(t *testing.T) {
+	tests := []struct {
+		name        string
+		update      bool
+		wantUpdated bool
+	}{
+		{"check only", false, false},
+		{"update", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			sess := newTestSession(t, api, "read", "write")
+			if err := writeTestUpload(t, api, sess, &ResumableUploadOptions{Folder: "f", Filename: "a.txt"}, "content"); err != nil {
+				t.Fatal(err)
+			}
+			hash := getTestFile(t, api, "a.txt").Hash
+
+			// a file uploaded before checksums existed, in a cached folder
+			folder, err := internal.GetFolder(api.root, "f")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, file := range folder.GetFiles() {
+				file.Hash = ""
+				if err := file.Save(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, folder.CacheVersion, _ = api.cache.GetCachedFolder("f")
+			if err := api.cache.CacheFolder(folder); err != nil {
+				t.Fatal(err)
+			}
+			if cached, _, err := api.cache.GetCachedFolder("f"); err != nil || len(cached.CachedFiles) != 1 {
+				t.Fatal("the folder isn't cached:", err)
+			}
+
+			var results []*internal.ScrubResult
+			err = api.Scrub(tt.update, func(result *internal.ScrubResult) {
+				results = append(results, result)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 1 || results[0].Err != nil || results[0].Updated != tt.wantUpdated {
+				t.Fatalf("unexpected results: %+v", results)
+			}
+
+			reader, err := api.OpenFile(sess, "f/a.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reader.Close()
+			if updated := reader.Hash() == hash; updated != tt.wantUpdated {
+				t.Errorf("updated = %t in the served file, want %t", updated, tt.wantUpdated)
+			}
+		})
+	}
+}
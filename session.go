@@ -0,0 +1,54 @@
+package razbox
+
+import (
+	"context"
+
+	"github.com/razzie/beepboop"
+)
+
+// Session represents the requester and its access to folders (*beepboop.Session implements it)
+type Session interface {
+	Context() context.Context
+	IP() string
+	GetAccessCode(accessType, resource string) (string, bool)
+	MergeAccess(access beepboop.AccessMap) error
+	RemoveAccess(accessType, resource string) error
+}
+
+type tokenSession struct {
+	ctx       context.Context
+	ip        string
+	access    beepboop.AccessMap
+	tokenName string // name of the API token (empty for anonymous sessions)
+}
+
+func (sess *tokenSession) Context() context.Context {
+	return sess.ctx
+}
+
+func (sess *tokenSession) IP() string {
+	return sess.ip
+}
+
+func (sess *tokenSession) GetAccessCode(accessType, resource string) (string, bool) {
+	return sess.access.Get(accessType, resource)
+}
+
+func (sess *tokenSession) MergeAccess(access beepboop.AccessMap) error {
+	sess.access.Merge(access)
+	return nil
+}
+
+func (sess *tokenSession) RemoveAccess(accessType, resource string) error {
+	sess.access.Remove(accessType, resource)
+	return nil
+}
+
+// NewAnonymousSession returns a Session without any folder access
+func NewAnonymousSession(ctx context.Context, ip string) Session {
+	return &tokenSession{
+		ctx:    ctx,
+		ip:     ip,
+		access: make(beepboop.AccessMap),
+	}
+}
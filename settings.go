@@ -0,0 +1,149 @@
+package razbox
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/razzie/razbox/internal"
+)
+
+// ErrInvalidFolderSetting is returned when a folder setting has an invalid value
+type ErrInvalidFolderSetting = internal.ErrInvalidFolderSetting
+
+// FolderSettings ...
+type FolderSettings struct {
+	MaxFileSizeMB      int64    `json:"max_file_size_mb"`
+	MaxFolderSizeMB    int64    `json:"max_folder_size_mb"`
+	Subfolders         bool     `json:"subfolders"`
+	TrashRetentionDays int      `json:"trash_retention_days"` // 0 = default, negative = no trash
+	MaxFileVersions    int      `json:"max_file_versions"`    // 0 = default, negative = no versions
+	Dedup              bool     `json:"dedup"`
+	AllowedTypes       []string `json:"allowed_types"` // MIME types like image/png or image/* (empty = all)
+	BlockedTypes       []string `json:"blocked_types"`
+	AllowedExtensions  []string `json:"allowed_extensions"` // file extensions without the dot (empty = all)
+	BlockedExtensions  []string `json:"blocked_extensions"`
+}
+
+func newFolderSettings(s *internal.FolderSettings) *FolderSettings {
+	return &FolderSettings{
+		MaxFileSizeMB:      s.MaxFileSizeMB,
+		MaxFolderSizeMB:    s.MaxFolderSizeMB,
+		Subfolders:         s.Subfolders,
+		TrashRetentionDays: s.TrashRetentionDays,
+		MaxFileVersions:    s.MaxFileVersions,
+		Dedup:              s.Dedup,
+		AllowedTypes:       s.AllowedTypes,
+		BlockedTypes:       s.BlockedTypes,
+		AllowedExtensions:  s.AllowedExtensions,
+		BlockedExtensions:  s.BlockedExtensions,
+	}
+}
+
+func (s *FolderSettings) toInternal() *internal.FolderSettings {
+	return &internal.FolderSettings{
+		MaxFileSizeMB:      s.MaxFileSizeMB,
+		MaxFolderSizeMB:    s.MaxFolderSizeMB,
+		Subfolders:         s.Subfolders,
+		TrashRetentionDays: s.TrashRetentionDays,
+		MaxFileVersions:    s.MaxFileVersions,
+		Dedup:              s.Dedup,
+		AllowedTypes:       s.AllowedTypes,
+		BlockedTypes:       s.BlockedTypes,
+		AllowedExtensions:  s.AllowedExtensions,
+		BlockedExtensions:  s.BlockedExtensions,
+	}
+}
+
+// describeSettingsChange lists the changed settings for the audit log
+func describeSettingsChange(prev, next *FolderSettings) string {
+	var changes []string
+	add := func(name string, prevValue, nextValue interface{}) {
+		if prevValue != nextValue {
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v", name, prevValue, nextValue))
+		}
+	}
+	add("max file size", prev.MaxFileSizeMB, next.MaxFileSizeMB)
+	add("max folder size", prev.MaxFolderSizeMB, next.MaxFolderSizeMB)
+	add("subfolders", prev.Subfolders, next.Subfolders)
+	add("trash retention", prev.TrashRetentionDays, next.TrashRetentionDays)
+	add("max file versions", prev.MaxFileVersions, next.MaxFileVersions)
+	add("dedup", prev.Dedup, next.Dedup)
+	add("allowed types", strings.Join(prev.AllowedTypes, " "), strings.Join(next.AllowedTypes, " "))
+	add("blocked types", strings.Join(prev.BlockedTypes, " "), strings.Join(next.BlockedTypes, " "))
+	add("allowed extensions", strings.Join(prev.AllowedExtensions, " "), strings.Join(next.AllowedExtensions, " "))
+	add("blocked extensions", strings.Join(prev.BlockedExtensions, " "), strings.Join(next.BlockedExtensions, " "))
+	return strings.Join(changes, ", ")
+}
+
+// GetFolderSettings returns the changeable config options of a config root folder
+func (api *API) GetFolderSettings(sess Session, folderName string) (*FolderSettings, error) {
+	folder, unlock, cached, err := api.getFolder(sess, folderName)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		defer api.goCacheFolder(folder)
+	}
+	defer unlock()
+
+	err = folder.EnsureReadAccess(sess)
+	if err != nil {
+		return nil, &ErrNoReadAccess{Folder: folderName}
+	}
+
+	err = folder.EnsureAdminAccess(sess)
+	if err != nil {
+		return nil, &ErrNoWriteAccess{Folder: folderName}
+	}
+
+	if folder.ConfigInherited {
+		return nil, &internal.ErrInheritedConfigChange{}
+	}
+
+	return newFolderSettings(folder.GetSettings()), nil
+}
+
+// SetFolderSettings changes the config options of a config root folder
+func (api *API) SetFolderSettings(sess Session, folderName string, settings *FolderSettings) error {
+	changed := false
+	folder, unlock, cached, err := api.getFolderForWrite(sess, folderName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if !cached || changed {
+			api.goCacheFolder(folder)
+		}
+	}()
+	defer unlock()
+
+	err = folder.EnsureReadAccess(sess)
+	if err != nil {
+		return &ErrNoReadAccess{Folder: folderName}
+	}
+
+	err = folder.EnsureAdminAccess(sess)
+	if err != nil {
+		return &ErrNoWriteAccess{Folder: folderName}
+	}
+
+	err = api.setFolderSettings(sess, folder, settings)
+	if err != nil {
+		return err
+	}
+	changed = true
+	return nil
+}
+
+// setFolderSettings changes the config options of a locked folder (whose access is already checked)
+func (api *API) setFolderSettings(sess Session, folder *internal.Folder, settings *FolderSettings) error {
+	old := newFolderSettings(folder.GetSettings())
+	err := folder.SetSettings(settings.toInternal())
+	if err != nil {
+		return err
+	}
+
+	api.uncacheInheritedSubfolders(folder)
+	api.audit(sess, folder, internal.AuditChangeSettings, folder.RelPath, describeSettingsChange(old, settings))
+	return nil
+}
@@ -0,0 +1,428 @@
+package razbox
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/razzie/razbox/internal"
+)
+
+// ShareLinkInfo ...
+type ShareLinkInfo struct {
+	ID           string `json:"id"`
+	Path         string `json:"path"`
+	Folder       bool   `json:"folder"`
+	Token        string `json:"token"`
+	Created      int64  `json:"created"`
+	Expires      int64  `json:"expires"`
+	MaxDownloads int    `json:"max_downloads,omitempty"`
+	Downloads    int    `json:"downloads,omitempty"`
+	Password     bool   `json:"password,omitempty"`
+}
+
+func newShareLinkInfo(root *internal.Folder, l *internal.ShareLink) *ShareLinkInfo {
+	return &ShareLinkInfo{
+		ID:           l.ID,
+		Path:         l.Path,
+		Folder:       l.Folder,
+		Token:        root.GetShareToken(l),
+		Created:      l.Created.Unix(),
+		Expires:      l.Expires.Unix(),
+		MaxDownloads: l.MaxDownloads,
+		Downloads:    l.Downloads,
+		Password:     len(l.Password) > 0,
+	}
+}
+
+// ShareLinkOptions ...
+type ShareLinkOptions struct {
+	Path         string // file or folder to share
+	ExpiresIn    time.Duration
+	MaxDownloads int // 0 = unlimited
+	Password     string
+}
+
+// ErrSharePasswordRequired is returned when a password protected share link is used without authentication
+type ErrSharePasswordRequired = internal.ErrSharePasswordRequired
+
+// MaxShareLinkDuration is the longest time a share link can be valid for
+const MaxShareLinkDuration = 365 * 24 * time.Hour
+
+type shareSession struct {
+	Session
+	token   string
+	request *http.Request
+}
+
+// NewShareSession returns a Session that also has the read access permitted by the given share token.
+// Files opened by GET requests through share links with a download limit reserve a download,
+// and range requests of such links are rejected.
+func NewShareSession(sess Session, token string, r *http.Request) Session {
+	return &shareSession{
+		Session: sess,
+		token:   token,
+		request: r,
+	}
+}
+
+func getShareToken(sess Session) (string, bool) {
+	if sess, ok := sess.(*shareSession); ok {
+		return sess.token, true
+	}
+	return "", false
+}
+
+// getShareRoot returns the config root of a locked folder (which stores the share links)
+func (api *API) getShareRoot(folder *internal.Folder) (root *internal.Folder, err error) {
+	if !folder.ConfigInherited {
+		return folder, nil
+	}
+	root, cached, err := api.getFolderNoLock(folder.ConfigRootFolder)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		api.goCacheFolder(root)
+	}
+	return root, nil
+}
+
+type shareAccess struct {
+	api  *API
+	root *internal.Folder
+	link *internal.ShareLink
+}
+
+// checkShareAccess returns the share link of the session that permits read access to the given file or folder
+// of a locked folder (or nil if the session doesn't have a share token)
+func (api *API) checkShareAccess(sess Session, folder *internal.Folder, relPath string) (*shareAccess, error) {
+	token, ok := getShareToken(sess)
+	if !ok {
+		return nil, nil
+	}
+	if tokenFolder, err := internal.GetShareTokenFolder(token); err != nil || tokenFolder != folder.ConfigRootFolder {
+		return nil, &internal.ErrInvalidShareToken{}
+	}
+
+	root, err := api.getShareRoot(folder)
+	if err != nil {
+		return nil, err
+	}
+	link, err := root.GetShareLinkByToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if !link.Covers(relPath) {
+		return nil, &internal.ErrInvalidShareToken{}
+	}
+	if link.IsExhausted() {
+		return nil, &internal.ErrShareLinkExhausted{}
+	}
+	if len(link.Password) > 0 {
+		code, _ := sess.GetAccessCode("share", link.ID)
+		if code != root.GetShareAccessCode(link) {
+			return nil, &internal.ErrSharePasswordRequired{}
+		}
+	}
+
+	return &shareAccess{
+		api:  api,
+		root: root,
+		link: link,
+	}, nil
+}
+
+// openDownload opens a file of a locked folder through the share link.
+// GET requests of share links with a download limit reserve a download (unless the link is exhausted),
+// which is released if the reader is closed before the whole content was read.
+func (share *shareAccess) openDownload(sess Session, file *internal.File) (FileReader, error) {
+	r := sess.(*shareSession).request
+	limited := share.link.MaxDownloads > 0 && (r == nil || r.Method == "GET")
+	if limited && r != nil && len(r.Header.Get("Range")) > 0 {
+		// parts of the content would have to be counted across requests
+		return nil, &internal.ErrShareLinkRangeNotAllowed{}
+	}
+
+	reader, err := file.Open()
+	if err != nil || !limited {
+		return reader, err
+	}
+	if err := share.updateDownloads(true); err != nil {
+		reader.Close()
+		return nil, err
+	}
+	return &shareDownload{
+		FileReader: reader,
+		share:      share,
+	}, nil
+}
+
+// updateDownloads counts or releases a download of the share link
+// (under a read lock of the folder, so the config root is reloaded and saved while other downloads wait)
+func (share *shareAccess) updateDownloads(count bool) error {
+	api := share.api
+	api.shareDownloadLock.Lock()
+	defer api.shareDownloadLock.Unlock()
+
+	// downloads and writers of other instances can change the config root too
+	_, release, err := api.leaseFolder(context.Background(), share.root.ConfigRootFolder)
+	if err != nil {
+		return err
+	}
+	root, err := updateShareLinkDownloads(api.root, share.root.ConfigRootFolder, share.link.ID, count)
+	if err == nil && api.cache != nil {
+		root.CacheVersion, _ = api.cache.TouchFolder(root.RelPath)
+	}
+	fence := release()
+	if err != nil {
+		return err
+	}
+
+	api.folderLocks.addWrite()
+	root.Fence = fence
+	api.goCacheFolder(root)
+	return nil
+}
+
+// releaseDownload releases a reserved download of the share link
+func (share *shareAccess) releaseDownload() error {
+	_, unlock, err := share.api.lockFolder(context.Background(), share.root, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return share.updateDownloads(false)
+}
+
+// shareDownload is a file opened through a share link with a reserved download,
+// which is released unless the whole content was read from the beginning
+type shareDownload struct {
+	FileReader
+	share  *shareAccess
+	pos    int64
+	read   int64 // number of bytes read continuously from the beginning
+	closed bool
+}
+
+func (d *shareDownload) Read(p []byte) (int, error) {
+	n, err := d.FileReader.Read(p)
+	if d.pos == d.read {
+		d.read += int64(n)
+	}
+	d.pos += int64(n)
+	return n, err
+}
+
+func (d *shareDownload) Seek(offset int64, whence int) (int64, error) {
+	pos, err := d.FileReader.Seek(offset, whence)
+	if err == nil {
+		d.pos = pos
+	}
+	return pos, err
+}
+
+func (d *shareDownload) Close() error {
+	if !d.closed {
+		d.closed = true
+		if d.read < d.Size() {
+			if err := d.share.releaseDownload(); err != nil {
+				log.Print("failed to release a download of share link: ", d.share.link.ID, " ", err)
+			}
+		}
+	}
+	return d.FileReader.Close()
+}
+
+func updateShareLinkDownloads(root, configRoot, id string, count bool) (*internal.Folder, error) {
+	folder, err := internal.GetFolder(root, configRoot)
+	if err != nil {
+		return nil, err
+	}
+	link, err := folder.GetShareLink(id)
+	if err != nil {
+		return nil, err
+	}
+	if count {
+		return folder, folder.CountShareLinkDownload(link)
+	}
+	return folder, folder.UncountShareLinkDownload(link)
+}
+
+// GetSharedPath returns the file or folder that a share token grants access to
+func (api *API) GetSharedPath(token string) (relPath string, isFolder bool, err error) {
+	folderName, err := internal.GetShareTokenFolder(token)
+	if err != nil {
+		return "", false, err
+	}
+	root, cached, err := api.getFolderNoLock(folderName)
+	if err != nil {
+		return "", false, &internal.ErrInvalidShareToken{}
+	}
+	if !cached {
+		defer api.goCacheFolder(root)
+	}
+
+	link, err := root.GetShareLinkByToken(token)
+	if err != nil {
+		return "", false, err
+	}
+	return link.Path, link.Folder, nil
+}
+
+// AuthShareLink grants the session access to a password protected share link
+func (api *API) AuthShareLink(sess Session, token, password string) error {
+	if err := api.checkRateLimit("auth", sess.IP(), api.AuthsPerMin); err != nil {
+		return err
+	}
+
+	folderName, err := internal.GetShareTokenFolder(token)
+	if err != nil {
+		return err
+	}
+	root, unlock, cached, err := api.getFolder(sess, folderName)
+	if err != nil {
+		return err
+	}
+	if !cached {
+		defer api.goCacheFolder(root)
+	}
+	defer unlock()
+
+	link, err := root.GetShareLinkByToken(token)
+	if err != nil {
+		return err
+	}
+	if !link.TestPassword(password) {
+		api.audit(sess, root, internal.AuditAuthFailed, link.Path, "share link "+link.ID)
+		return &ErrWrongPassword{}
+	}
+	api.audit(sess, root, internal.AuditAuth, link.Path, "share link "+link.ID)
+	return sess.MergeAccess(root.GetShareAccessToken(link))
+}
+
+// GetShareLinks returns the share links of a folder's config root
+func (api *API) GetShareLinks(sess Session, folderName string) ([]*ShareLinkInfo, error) {
+	folder, unlock, cached, err := api.getFolder(sess, folderName)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		defer api.goCacheFolder(folder)
+	}
+	defer unlock()
+
+	err = folder.EnsureReadAccess(sess)
+	if err != nil {
+		return nil, &ErrNoReadAccess{Folder: folderName}
+	}
+
+	err = folder.EnsureWriteAccess(sess)
+	if err != nil {
+		return nil, &ErrNoWriteAccess{Folder: folderName}
+	}
+
+	root, err := api.getShareRoot(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]*ShareLinkInfo, 0, len(root.Config.ShareLinks))
+	for _, l := range root.Config.ShareLinks {
+		if l.IsExpired() {
+			continue
+		}
+		links = append(links, newShareLinkInfo(root, l))
+	}
+	return links, nil
+}
+
+// CreateShareLink creates a signed share link to a file or folder
+func (api *API) CreateShareLink(sess Session, o *ShareLinkOptions) (*ShareLinkInfo, error) {
+	relPath := path.Clean(o.Path)
+	isFolder := internal.IsFolder(api.root, relPath)
+	dir := relPath
+	if !isFolder {
+		dir = path.Dir(relPath)
+	}
+
+	folder, unlock, cached, err := api.getFolderForWrite(sess, dir)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		defer api.goCacheFolder(folder)
+	}
+	defer unlock()
+
+	err = folder.EnsureReadAccess(sess)
+	if err != nil {
+		return nil, &ErrNoReadAccess{Folder: dir}
+	}
+
+	err = folder.EnsureWriteAccess(sess)
+	if err != nil {
+		return nil, &ErrNoWriteAccess{Folder: dir}
+	}
+
+	if !isFolder {
+		if _, err := folder.GetFile(path.Base(relPath)); err != nil {
+			return nil, &ErrNotFound{}
+		}
+	}
+	if o.ExpiresIn <= 0 || o.ExpiresIn > MaxShareLinkDuration {
+		o.ExpiresIn = MaxShareLinkDuration
+	}
+	if o.MaxDownloads < 0 {
+		o.MaxDownloads = 0
+	}
+
+	root, err := api.getShareRoot(folder)
+	if err != nil {
+		return nil, err
+	}
+	link, err := root.CreateShareLink(relPath, isFolder, time.Now().Add(o.ExpiresIn), o.MaxDownloads, o.Password)
+	if err != nil {
+		return nil, err
+	}
+	api.goCacheFolder(root)
+	api.audit(sess, folder, internal.AuditShareLink, relPath, "created "+link.ID)
+
+	return newShareLinkInfo(root, link), nil
+}
+
+// RevokeShareLink ...
+func (api *API) RevokeShareLink(sess Session, folderName, id string) error {
+	folder, unlock, cached, err := api.getFolderForWrite(sess, folderName)
+	if err != nil {
+		return err
+	}
+	if !cached {
+		defer api.goCacheFolder(folder)
+	}
+	defer unlock()
+
+	err = folder.EnsureReadAccess(sess)
+	if err != nil {
+		return &ErrNoReadAccess{Folder: folderName}
+	}
+
+	err = folder.EnsureWriteAccess(sess)
+	if err != nil {
+		return &ErrNoWriteAccess{Folder: folderName}
+	}
+
+	root, err := api.getShareRoot(folder)
+	if err != nil {
+		return err
+	}
+	err = root.RevokeShareLink(id)
+	if err != nil {
+		return err
+	}
+	api.goCacheFolder(root)
+	api.audit(sess, folder, internal.AuditShareLink, folder.RelPath, "revoked "+id)
+	return nil
+}
@@ -0,0 +1,139 @@
+package razbox
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/razzie/razbox/internal"
+)
+
+func TestShareLinkDownloadCount(t *testing.T) {
+	api := newTestAPI(t)
+	owner := newTestSession(t, api, "read", "write")
+	if err := writeTestUpload(t, api, owner, &ResumableUploadOptions{Folder: "f", Filename: "s.txt"}, "shared content"); err != nil {
+		t.Fatal(err)
+	}
+	link, err := api.CreateShareLink(owner, &ShareLinkOptions{
+		Path:         "f/s.txt",
+		ExpiresIn:    time.Hour,
+		MaxDownloads: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	etag := strconv.Quote(getTestFile(t, api, "s.txt").Hash)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sess := NewShareSession(newTestSession(t, api), link.Token, r)
+		reader, err := api.OpenFile(sess, "f/s.txt")
+		if err != nil {
+			status := http.StatusForbidden
+			if err, ok := err.(interface{ HTTPStatus() int }); ok {
+				status = err.HTTPStatus()
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		defer reader.Close()
+		w.Header().Set("Content-Type", reader.MimeType())
+		w.Header().Set("ETag", strconv.Quote(reader.Hash()))
+		http.ServeContent(w, r, reader.Name(), reader.ModTime(), reader)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		header     http.Header
+		wantStatus int
+		wantCount  int // number of counted downloads after the request
+	}{
+		{"head", "HEAD", nil, http.StatusOK, 0},
+		// the content can't be downloaded in parts to get around the limit
+		{"range", "GET", http.Header{"Range": {"bytes=7-"}}, http.StatusRequestedRangeNotSatisfiable, 0},
+		{"partial range from start", "GET", http.Header{"Range": {"bytes=0-5"}}, http.StatusRequestedRangeNotSatisfiable, 0},
+		{"full range", "GET", http.Header{"Range": {"bytes=0-"}}, http.StatusRequestedRangeNotSatisfiable, 0},
+		// the reserved download is released if the content isn't sent
+		{"not modified", "GET", http.Header{"If-None-Match": {etag}}, http.StatusNotModified, 0},
+		{"full", "GET", nil, http.StatusOK, 1},
+		{"full again", "GET", nil, http.StatusOK, 2},
+		{"exhausted", "GET", nil, http.StatusGone, 2},
+		{"exhausted range", "GET", http.Header{"Range": {"bytes=0-"}}, http.StatusGone, 2},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, server.URL, nil)
+		for key, values := range tt.header {
+			req.Header[key] = values
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.wantStatus)
+		}
+
+		links, err := api.GetShareLinks(owner, "f")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if links[0].Downloads != tt.wantCount {
+			t.Errorf("%s: downloads = %d, want %d", tt.name, links[0].Downloads, tt.wantCount)
+		}
+	}
+}
+
+func TestShareLinkDownloadReservation(t *testing.T) {
+	api := newTestAPI(t)
+	owner := newTestSession(t, api, "read", "write")
+	if err := writeTestUpload(t, api, owner, &ResumableUploadOptions{Folder: "f", Filename: "s.txt"}, "shared content"); err != nil {
+		t.Fatal(err)
+	}
+	link, err := api.CreateShareLink(owner, &ShareLinkOptions{
+		Path:         "f/s.txt",
+		ExpiresIn:    time.Hour,
+		MaxDownloads: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := func() (FileReader, error) {
+		req := httptest.NewRequest("GET", "/", nil)
+		return api.OpenFile(NewShareSession(newTestSession(t, api), link.Token, req), "f/s.txt")
+	}
+
+	// concurrent downloads can't exceed the limit
+	first, err := open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := open(); !isErrShareLinkExhausted(err) {
+		t.Errorf("second download while the first one is in progress returned %v", err)
+	}
+
+	// an incomplete download releases its reservation
+	first.Read(make([]byte, 4))
+	first.Close()
+	second, err := open()
+	if err != nil {
+		t.Fatalf("download after an incomplete one failed: %v", err)
+	}
+	if _, err := ioutil.ReadAll(second); err != nil {
+		t.Fatal(err)
+	}
+	second.Close()
+	if _, err := open(); !isErrShareLinkExhausted(err) {
+		t.Errorf("download after a complete one returned %v", err)
+	}
+}
+
+func isErrShareLinkExhausted(err error) bool {
+	_, ok := err.(*internal.ErrShareLinkExhausted)
+	return ok
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/razzie/razbox/internal"
+)
+
+var (
+	// Root is the root directory of folders
+	Root string
+	// Fix (if enabled) repairs the found issues
+	Fix bool
+	// Delete (if enabled) deletes orphaned files instead of moving them to quarantine
+	Delete bool
+	// RedisConnStr is the Redis connection string used to invalidate the cached folders
+	RedisConnStr string
+)
+
+func init() {
+	flag.StringVar(&Root, "root", "./uploads", "Root directory of folders (or storage URL)")
+	flag.BoolVar(&Fix, "fix", false, "Repair the found issues (relink misnamed files, quarantine orphaned files)")
+	flag.BoolVar(&Delete, "delete", false, "Delete orphaned files instead of moving them to "+internal.QuarantineFolderName)
+	flag.StringVar(&RedisConnStr, "redis", "", "Redis connection string (to invalidate the cache of repaired folders)")
+	flag.Parse()
+}
+
+func main() {
+	if internal.IsLocalRoot(Root) && !filepath.IsAbs(Root) {
+		var err error
+		Root, err = filepath.Abs(Root)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var cache internal.FolderCache
+	if Fix && len(RedisConnStr) > 0 {
+		opt, err := redis.ParseURL(RedisConnStr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cache = internal.NewRedisFolderCache(redis.NewClient(opt), 0)
+	}
+
+	issues, err := internal.Fsck(Root)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var failed int
+	repairedFolders := make(map[string]bool)
+	for _, issue := range issues {
+		if !Fix {
+			fmt.Println(issue)
+			continue
+		}
+		if err := issue.Repair(!Delete); err != nil {
+			failed++
+			fmt.Printf("%v - repair failed: %v\n", issue, err)
+			continue
+		}
+		fmt.Printf("%v - repaired\n", issue)
+		if len(issue.Folder) > 0 {
+			repairedFolders[issue.Folder] = true
+		}
+	}
+
+	if cache != nil {
+		for folder := range repairedFolders {
+			if err := cache.UncacheFolder(folder); err != nil {
+				log.Print("UncacheFolder error:", err)
+			}
+		}
+	}
+
+	fmt.Printf("%d issues found\n", len(issues))
+	if failed > 0 || (!Fix && len(issues) > 0) {
+		os.Exit(1)
+	}
+}
@@ -53,6 +53,18 @@ func main() {
 		log.Fatal("No matches for", SourceFiles)
 	}
 
+	if internal.IsLocalRoot(Root) && !filepath.IsAbs(Root) {
+		Root, err = filepath.Abs(Root)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	folder, err := internal.GetFolder(Root, TargetFolder)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	for _, filename := range matches {
 		basename := filepath.Base(filename)
 		fmt.Printf("Creating file %s... ", path.Join(TargetFolder, basename))
@@ -66,6 +78,11 @@ func main() {
 		fi, _ := file.Stat()
 		mime, _ := internal.DetectContentType(file)
 		file.Seek(0, io.SeekStart)
+		if err := folder.CheckFileType(basename, mime); err != nil {
+			file.Close()
+			fmt.Println("error:", err)
+			continue
+		}
 
 		boxfile := &internal.File{
 			Name:     basename,
@@ -77,7 +94,7 @@ func main() {
 			Uploaded: fi.ModTime(),
 		}
 
-		err = boxfile.Create(file, false)
+		err = folder.CreateFile(boxfile, file, false)
 		file.Close()
 		if err != nil {
 			fmt.Println("error:", err)
@@ -3,8 +3,6 @@ package main
 import (
 	"flag"
 	"log"
-	"os"
-	"path"
 	"path/filepath"
 
 	"github.com/razzie/razbox/internal"
@@ -17,6 +15,8 @@ var (
 	ReadPassword string
 	// WritePassword is the write password for the target folder
 	WritePassword string
+	// UploadPassword is the upload-only password for the target folder
+	UploadPassword string
 	// MaxFileSizeMB is the upload file size limit in MiB for the folder
 	MaxFileSizeMB int64
 	// MaxFolderSizeMB is maximum size of the folder in MiB
@@ -25,21 +25,31 @@ var (
 	Folder string
 	// Subfolders is a flag that allows the user to create subfolders
 	Subfolders bool
+	// TrashRetentionDays is the number of days deleted files are kept in trash
+	TrashRetentionDays int
+	// MaxFileVersions is the number of previous versions kept of overwritten files
+	MaxFileVersions int
+	// Dedup enables storing file contents in the deduplicated blob store
+	Dedup bool
 )
 
 func init() {
 	flag.StringVar(&Root, "root", "./uploads", "Root directory of folders")
 	flag.StringVar(&ReadPassword, "readpw", "", "Password for read access to the folder (optional)")
 	flag.StringVar(&WritePassword, "writepw", "", "Password for write access to the folder")
+	flag.StringVar(&UploadPassword, "uploadpw", "", "Password that only permits uploading files to the folder (optional)")
 	flag.Int64Var(&MaxFileSizeMB, "max-file-size", 0, "File size limit for uploads in MiB for this folder")
 	flag.Int64Var(&MaxFolderSizeMB, "max-folder-size", 0, "Size limit in MiB for this folder")
 	flag.StringVar(&Folder, "folder", "", "Folder name (relative path)")
 	flag.BoolVar(&Subfolders, "subfolders", false, "Allows the user to create subfolders")
+	flag.IntVar(&TrashRetentionDays, "trash-retention", 0, "Days to keep deleted files in trash (0 = default, negative = no trash)")
+	flag.IntVar(&MaxFileVersions, "max-file-versions", 0, "Previous versions to keep of overwritten files (0 = default, negative = no versions)")
+	flag.BoolVar(&Dedup, "dedup", false, "Store file contents deduplicated (by SHA-256) in the blob store of the root")
 	flag.Parse()
 }
 
 func main() {
-	if !filepath.IsAbs(Root) {
+	if internal.IsLocalRoot(Root) && !filepath.IsAbs(Root) {
 		var err error
 		Root, err = filepath.Abs(Root)
 		if err != nil {
@@ -47,7 +57,12 @@ func main() {
 		}
 	}
 
-	err := os.MkdirAll(path.Join(Root, Folder), 0755)
+	storage, err := internal.GetStorage(Root)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = storage.MkdirAll(Folder)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -56,13 +71,22 @@ func main() {
 		Root:    Root,
 		RelPath: Folder,
 		Config: internal.FolderConfig{
-			MaxFileSizeMB:   MaxFileSizeMB,
-			MaxFolderSizeMB: MaxFolderSizeMB,
-			Subfolders:      Subfolders,
+			MaxFileSizeMB:      MaxFileSizeMB,
+			MaxFolderSizeMB:    MaxFolderSizeMB,
+			Subfolders:         Subfolders,
+			TrashRetentionDays: TrashRetentionDays,
+			MaxFileVersions:    MaxFileVersions,
+			Dedup:              Dedup,
 		},
 	}
 	err = folder.SetPasswords(ReadPassword, WritePassword)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if len(UploadPassword) > 0 {
+		err = folder.SetUploadPassword(UploadPassword)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 }
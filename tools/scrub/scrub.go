@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/razzie/razbox"
+	"github.com/razzie/razbox/internal"
+)
+
+var (
+	// Root is the root directory of folders
+	Root string
+	// Update (if enabled) records the missing checksums of files uploaded before checksums existed
+	Update bool
+	// Verbose (if enabled) reports the files that passed the check too
+	Verbose bool
+	// RedisConnStr is the Redis connection string used to lock and invalidate the cached folders of updated files
+	RedisConnStr string
+)
+
+func init() {
+	flag.StringVar(&Root, "root", "./uploads", "Root directory of folders (or storage URL)")
+	flag.BoolVar(&Update, "update", false, "Record missing checksums")
+	flag.BoolVar(&Verbose, "v", false, "Report files that passed the check too")
+	flag.StringVar(&RedisConnStr, "redis", "", "Redis connection string (to lock and invalidate the cache of updated folders)")
+	flag.Parse()
+}
+
+func main() {
+	api, err := razbox.NewAPI(Root)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if Update && len(RedisConnStr) > 0 {
+		if _, err := api.ConnectDB(RedisConnStr); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var checked, mismatches, missing, errors int
+	err = api.Scrub(Update, func(result *internal.ScrubResult) {
+		checked++
+		switch {
+		case result.Err != nil:
+			errors++
+			fmt.Printf("ERROR    %s: %v\n", result.Name, result.Err)
+		case len(result.Expected) == 0:
+			missing++
+			fmt.Printf("MISSING  %s (sha256 %s)\n", result.Name, result.Actual)
+		case !result.OK():
+			mismatches++
+			fmt.Printf("MISMATCH %s (expected %s, got %s)\n", result.Name, result.Expected, result.Actual)
+		case result.Updated:
+			fmt.Printf("UPDATED  %s (sha256 %s)\n", result.Name, result.Actual)
+		case Verbose:
+			fmt.Printf("OK       %s\n", result.Name)
+		}
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("%d checked, %d mismatches, %d missing checksums, %d errors\n", checked, mismatches, missing, errors)
+	if mismatches > 0 || errors > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,173 @@
+package razbox
+
+import (
+	"context"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/razzie/razbox/internal"
+)
+
+// TrashedFileInfo ...
+type TrashedFileInfo struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Folder  string `json:"folder"`
+	MIME    string `json:"mime"`
+	Size    int64  `json:"size"`
+	Deleted int64  `json:"deleted"`
+	Expires int64  `json:"expires"`
+}
+
+// GetTrash returns the files that were deleted from the folder or its subfolders
+func (api *API) GetTrash(sess Session, folderName string) ([]*TrashedFileInfo, error) {
+	folder, unlock, cached, err := api.getFolder(sess, folderName)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		defer api.goCacheFolder(folder)
+	}
+	defer unlock()
+
+	err = folder.EnsureReadAccess(sess)
+	if err != nil {
+		return nil, &ErrNoReadAccess{Folder: folderName}
+	}
+
+	err = folder.EnsureWriteAccess(sess)
+	if err != nil {
+		return nil, &ErrNoWriteAccess{Folder: folderName}
+	}
+
+	retention := folder.GetTrashRetention()
+	trash := folder.GetTrash()
+	files := make([]*TrashedFileInfo, 0, len(trash))
+	for _, t := range trash {
+		files = append(files, &TrashedFileInfo{
+			ID:      t.ID,
+			Name:    t.File.Name,
+			Folder:  t.Folder,
+			MIME:    t.File.MIME,
+			Size:    t.File.Size,
+			Deleted: t.Deleted.Unix(),
+			Expires: t.Deleted.Add(retention).Unix(),
+		})
+	}
+	return files, nil
+}
+
+// RestoreTrashedFile moves a deleted file back to its original folder and returns its path
+func (api *API) RestoreTrashedFile(sess Session, folderName, id string) (string, error) {
+	changed := false
+	folder, unlock, cached, err := api.getFolderForWrite(sess, folderName)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if !cached || changed {
+			api.goCacheFolder(folder)
+		}
+	}()
+	defer unlock()
+
+	err = folder.EnsureReadAccess(sess)
+	if err != nil {
+		return "", &ErrNoReadAccess{Folder: folderName}
+	}
+
+	err = folder.EnsureWriteAccess(sess)
+	if err != nil {
+		return "", &ErrNoWriteAccess{Folder: folderName}
+	}
+
+	file, err := folder.RestoreTrashedFile(id)
+	if err != nil {
+		return "", err
+	}
+
+	fileFolder := path.Dir(file.RelPath)
+	api.audit(sess, folder, internal.AuditRestore, path.Join(fileFolder, file.Name), "from trash")
+	if fileFolder == folder.RelPath {
+		api.cacheFile(folder, file)
+		changed = true
+	} else if api.cache != nil {
+		api.cache.CacheFile(fileFolder, file)
+	}
+	return path.Join(fileFolder, file.Name), nil
+}
+
+// PurgeTrash permanently deletes the trashed files of all folders that are older than their trash retention,
+// and the expired partial uploads (which are abandoned, so they would use up storage forever)
+func (api *API) PurgeTrash() (purged, purgedUploads int) {
+	internal.WalkStorage(api.root, ".", func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			if id := strings.TrimSuffix(info.Name(), ".tus"); id != info.Name() && api.purgeExpiredUpload(path.Dir(p), id) {
+				purgedUploads++
+			}
+			return nil
+		}
+		if info.Name() == internal.BlobFolderName {
+			return filepath.SkipDir
+		}
+		if info.Name() != internal.TrashFolderName {
+			return nil
+		}
+
+		configRoot := path.Dir(p)
+		folder, err := internal.GetFolder(api.root, configRoot)
+		if err != nil {
+			log.Print("PurgeTrash error:", err)
+			return filepath.SkipDir
+		}
+
+		ctx, unlock, err := api.lockFolder(context.Background(), folder, true)
+		if err != nil {
+			return filepath.SkipDir // try again next time
+		}
+		purged += folder.PurgeTrash(ctx)
+		unlock()
+		return filepath.SkipDir
+	})
+	return
+}
+
+// purgeExpiredUpload deletes a partial upload if it expired (unless it's being written right now)
+func (api *API) purgeExpiredUpload(folderName, id string) bool {
+	u, err := internal.GetPartialUpload(api.root, folderName, id)
+	if err != nil || !u.IsExpired() {
+		return false
+	}
+	_, unlock, err := api.lockUpload(context.Background(), folderName, id)
+	if err != nil {
+		return false
+	}
+	defer unlock()
+
+	if err := u.Delete(); err != nil {
+		log.Print("PurgeTrash error:", err)
+		return false
+	}
+	return true
+}
+
+// RunTrashReaper purges expired trashed files and partial uploads periodically (blocks forever)
+func (api *API) RunTrashReaper(interval time.Duration) {
+	for {
+		purged, purgedUploads := api.PurgeTrash()
+		if purged > 0 {
+			log.Printf("purged %d expired files from trash", purged)
+		}
+		if purgedUploads > 0 {
+			log.Printf("purged %d expired partial uploads", purgedUploads)
+		}
+		time.Sleep(interval)
+	}
+}
@@ -18,7 +18,7 @@ type LimitedReader struct {
 // Read implements io.Reader
 func (l *LimitedReader) Read(p []byte) (n int, err error) {
 	if l.N <= 0 {
-		return 0, &ErrSizeLimitExceeded{}
+		return 0, limitReached(l.R)
 	}
 	if int64(len(p)) > l.N {
 		p = p[:l.N]
@@ -37,7 +37,7 @@ type LimitedReadCloser struct {
 // Read implements io.Reader
 func (l *LimitedReadCloser) Read(p []byte) (n int, err error) {
 	if l.N <= 0 {
-		return 0, &ErrSizeLimitExceeded{}
+		return 0, limitReached(l.R)
 	}
 	if int64(len(p)) > l.N {
 		p = p[:l.N]
@@ -52,6 +52,16 @@ func (l *LimitedReadCloser) Close() error {
 	return l.R.Close()
 }
 
+// limitReached returns io.EOF if the reader has no more data, or ErrSizeLimitExceeded otherwise
+func limitReached(r io.Reader) error {
+	var probe [1]byte
+	n, err := r.Read(probe[:])
+	if n > 0 {
+		return &ErrSizeLimitExceeded{}
+	}
+	return err
+}
+
 func getContentDispositionFilename(header http.Header) string {
 	contentDisposition := header.Get("Content-Disposition")
 	_, params, _ := mime.ParseMediaType(contentDisposition)
@@ -0,0 +1,128 @@
+package razbox
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+
+	"github.com/razzie/razbox/internal"
+)
+
+// FileVersionInfo ...
+type FileVersionInfo struct {
+	Version  int    `json:"version"`
+	MIME     string `json:"mime"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256,omitempty"`
+	Uploaded int64  `json:"uploaded"`
+}
+
+func getFileWithReadAccess(sess Session, folder *internal.Folder, filePath string) (*internal.File, error) {
+	err := folder.EnsureReadAccess(sess)
+	if err != nil {
+		return nil, &ErrNoReadAccess{Folder: path.Dir(filePath)}
+	}
+
+	file, err := folder.GetFile(filepath.Base(filePath))
+	if err != nil {
+		return nil, &ErrNotFound{}
+	}
+	return file, nil
+}
+
+// GetFileVersions returns the previous versions of a file (newest first)
+func (api *API) GetFileVersions(sess Session, filePath string) ([]*FileVersionInfo, error) {
+	filePath = path.Clean(filePath)
+	folder, unlock, cached, err := api.getFolder(sess, path.Dir(filePath))
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		defer api.goCacheFolder(folder)
+	}
+	defer unlock()
+
+	file, err := getFileWithReadAccess(sess, folder, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	versionNumbers := file.GetVersionNumbers()
+	versions := make([]*FileVersionInfo, 0, len(versionNumbers))
+	for _, version := range versionNumbers {
+		v, err := file.GetVersion(version)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, &FileVersionInfo{
+			Version:  version,
+			MIME:     v.MIME,
+			SHA256:   v.Hash,
+			Size:     v.Size,
+			Uploaded: v.Uploaded.Unix(),
+		})
+	}
+	return versions, nil
+}
+
+// OpenFileVersion opens a previous version of a file
+func (api *API) OpenFileVersion(sess Session, filePath string, version int) (FileReader, error) {
+	filePath = path.Clean(filePath)
+	folder, unlock, cached, err := api.getFolder(sess, path.Dir(filePath))
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		defer api.goCacheFolder(folder)
+	}
+	defer unlock()
+
+	file, err := getFileWithReadAccess(sess, folder, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := file.GetVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	v.Name = file.Name
+	return v.Open()
+}
+
+// RestoreFileVersion replaces the content of a file with one of its previous versions
+func (api *API) RestoreFileVersion(sess Session, filePath string, version int) error {
+	filePath = path.Clean(filePath)
+	dir := path.Dir(filePath)
+	changed := false
+	folder, unlock, cached, err := api.getFolderForWrite(sess, dir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if !cached || changed {
+			api.goCacheFolder(folder)
+		}
+	}()
+	defer unlock()
+
+	file, err := getFileWithReadAccess(sess, folder, filePath)
+	if err != nil {
+		return err
+	}
+
+	err = folder.EnsureWriteAccess(sess)
+	if err != nil {
+		return &ErrNoWriteAccess{Folder: dir}
+	}
+
+	restored, err := folder.RestoreFileVersion(file, version)
+	if err != nil {
+		return err
+	}
+
+	api.cacheFile(folder, restored)
+	changed = true
+	api.audit(sess, folder, internal.AuditRestore, filePath, fmt.Sprintf("version %d", version))
+	return nil
+}
@@ -0,0 +1,46 @@
+package razbox
+
+import (
+	"sync"
+	"time"
+
+	"github.com/razzie/razbox/internal"
+)
+
+// watchDelay is how long changes are collected before the changed folders get uncached
+const watchDelay = time.Millisecond * 100
+
+// WatchStorage uncaches the folders that change in a local storage root,
+// including the changes made by hand, by other tools or by other instances (blocks until an error happens)
+func (api *API) WatchStorage() error {
+	if !internal.IsLocalRoot(api.root) {
+		return &internal.ErrWatchNotSupported{Root: api.root}
+	}
+
+	var mu sync.Mutex
+	changed := make(map[string]bool)
+	uncache := func() {
+		mu.Lock()
+		folders := changed
+		changed = make(map[string]bool)
+		mu.Unlock()
+
+		// a folder could get a config of its own (or lose it) before it's locked
+		api.folderLocks.addWrite()
+		if api.cache == nil {
+			return
+		}
+		for folder := range folders {
+			api.cache.UncacheFolder(folder)
+		}
+	}
+
+	return internal.WatchLocalRoot(api.root, func(folder string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(changed) == 0 {
+			time.AfterFunc(watchDelay, uncache)
+		}
+		changed[folder] = true
+	})
+}
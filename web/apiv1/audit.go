@@ -0,0 +1,69 @@
+package apiv1
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/razzie/razbox"
+)
+
+type auditResponse struct {
+	Folder  string               `json:"folder"`
+	Entries []*razbox.AuditEntry `json:"entries"`
+}
+
+func parseAuditLogFilter(r *http.Request) (*razbox.AuditLogFilter, error) {
+	q := r.URL.Query()
+	filter := &razbox.AuditLogFilter{
+		Action: q.Get("action"),
+		Query:  q.Get("q"),
+	}
+	parseUnix := func(key string) (time.Time, error) {
+		value := q.Get(key)
+		if len(value) == 0 {
+			return time.Time{}, nil
+		}
+		sec, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, &errBadRequest{err: err}
+		}
+		return time.Unix(sec, 0), nil
+	}
+
+	var err error
+	if filter.Since, err = parseUnix("since"); err != nil {
+		return nil, err
+	}
+	if filter.Until, err = parseUnix("until"); err != nil {
+		return nil, err
+	}
+	if limit := q.Get("limit"); len(limit) > 0 {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			return nil, &errBadRequest{err: err}
+		}
+	}
+	return filter, nil
+}
+
+func auditHandler(api *razbox.API, w http.ResponseWriter, r *request) {
+	if r.Method != "GET" {
+		methodNotAllowed(w, "GET")
+		return
+	}
+
+	filter, err := parseAuditLogFilter(r.Request)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	entries, err := api.GetAuditLog(r.Session, r.RelPath, filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &auditResponse{
+		Folder:  r.RelPath,
+		Entries: entries,
+	})
+}
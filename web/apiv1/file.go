@@ -0,0 +1,101 @@
+package apiv1
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/razzie/razbox"
+)
+
+type editFileRequest struct {
+	Filename *string   `json:"filename"`
+	Tags     *[]string `json:"tags"`
+	Public   *bool     `json:"public"`
+	MoveTo   string    `json:"move_to"`
+}
+
+func fileHandler(api *razbox.API, w http.ResponseWriter, r *request) {
+	switch r.Method {
+	case "GET", "HEAD":
+		file, err := openFile(api, r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		defer file.Close()
+		w.Header().Set("Content-Type", file.MimeType())
+		for key, values := range razbox.GetChecksumHeaders(file.Hash()) {
+			w.Header()[key] = values
+		}
+		http.ServeContent(w, r.Request, file.Name(), file.ModTime(), file)
+
+	case "PATCH":
+		editFile(api, w, r)
+
+	case "DELETE":
+		if err := api.DeleteFile(r.Session, r.RelPath); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		methodNotAllowed(w, "GET", "HEAD", "PATCH", "DELETE")
+	}
+}
+
+func editFile(api *razbox.API, w http.ResponseWriter, r *request) {
+	var req editFileRequest
+	if err := decodeJSON(r.Request, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	entries, flags, err := api.GetFolderEntries(r.Session, r.RelPath)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	// folders (including empty ones) return their flags too
+	if flags != nil || len(entries) == 0 || entries[0].Folder {
+		writeError(w, &razbox.ErrNotFound{})
+		return
+	}
+	entry := entries[0]
+
+	dir := path.Dir(r.RelPath)
+	o := &razbox.EditFileOptions{
+		Folder:           dir,
+		OriginalFilename: entry.Name,
+		Tags:             entry.Tags,
+		Public:           entry.Public,
+		MoveTo:           req.MoveTo,
+	}
+	if req.Filename != nil {
+		o.NewFilename = *req.Filename
+	}
+	if req.Tags != nil {
+		o.Tags = *req.Tags
+	}
+	if req.Public != nil {
+		o.Public = *req.Public
+	}
+	if err := api.EditFile(r.Session, o); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func openFile(api *razbox.API, r *request) (razbox.FileReader, error) {
+	version := r.URL.Query().Get("version")
+	if len(version) == 0 {
+		return api.OpenFile(r.Session, r.RelPath)
+	}
+	versionNum, err := strconv.Atoi(version)
+	if err != nil {
+		return nil, &errBadRequest{err: err}
+	}
+	return api.OpenFileVersion(r.Session, r.RelPath, versionNum)
+}
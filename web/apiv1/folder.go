@@ -0,0 +1,125 @@
+package apiv1
+
+import (
+	"net/http"
+	"path"
+
+	"github.com/razzie/razbox"
+)
+
+type folderResponse struct {
+	Folder  string                `json:"folder"`
+	Entries []*razbox.FolderEntry `json:"entries"`
+	Flags   *razbox.FolderFlags   `json:"flags,omitempty"`
+}
+
+type createSubfolderRequest struct {
+	Name           string                 `json:"name"`
+	OwnConfig      bool                   `json:"own_config,omitempty"`
+	ReadPassword   string                 `json:"read_password,omitempty"`
+	WritePassword  string                 `json:"write_password,omitempty"`
+	UploadPassword string                 `json:"upload_password,omitempty"`
+	ParentManaged  bool                   `json:"parent_managed,omitempty"`
+	Settings       *razbox.FolderSettings `json:"settings,omitempty"`
+}
+
+type createSubfolderResponse struct {
+	Folder string `json:"folder"`
+}
+
+type editSubfolderRequest struct {
+	Name   string `json:"name,omitempty"`
+	MoveTo string `json:"move_to,omitempty"`
+}
+
+type editSubfolderResponse struct {
+	Folder string `json:"folder"`
+}
+
+type deleteSubfolderResponse struct {
+	Trashed int `json:"trashed"`
+}
+
+func folderHandler(api *razbox.API, w http.ResponseWriter, r *request) {
+	switch r.Method {
+	case "GET":
+		entries, flags, err := api.GetFolderEntries(r.Session, r.RelPath)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if entries == nil {
+			entries = []*razbox.FolderEntry{}
+		}
+		writeJSON(w, http.StatusOK, &folderResponse{
+			Folder:  r.RelPath,
+			Entries: entries,
+			Flags:   flags,
+		})
+
+	case "POST":
+		var req createSubfolderRequest
+		if err := decodeJSON(r.Request, &req); err != nil {
+			writeError(w, err)
+			return
+		}
+		subfolder, err := api.CreateSubfolderWithOptions(r.Session, r.RelPath, &razbox.CreateSubfolderOptions{
+			Name:           req.Name,
+			OwnConfig:      req.OwnConfig,
+			ReadPassword:   req.ReadPassword,
+			WritePassword:  req.WritePassword,
+			UploadPassword: req.UploadPassword,
+			ParentManaged:  req.ParentManaged,
+			Settings:       req.Settings,
+		})
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, &createSubfolderResponse{Folder: subfolder})
+
+	case "PATCH":
+		var req editSubfolderRequest
+		if err := decodeJSON(r.Request, &req); err != nil {
+			writeError(w, err)
+			return
+		}
+		folder := r.RelPath
+		var err error
+		if len(req.Name) > 0 {
+			folder, err = api.RenameSubfolder(r.Session, folder, req.Name)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+		if len(req.MoveTo) > 0 {
+			folder, err = api.MoveSubfolder(r.Session, folder, req.MoveTo)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, &editSubfolderResponse{Folder: folder})
+
+	case "DELETE":
+		// non-empty folders are only deleted (to the trash) with ?recursive
+		if _, recursive := r.URL.Query()["recursive"]; recursive {
+			trashed, err := api.DeleteSubfolderRecursive(r.Session, r.RelPath)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, &deleteSubfolderResponse{Trashed: trashed})
+			return
+		}
+		if err := api.DeleteSubfolder(r.Session, path.Dir(r.RelPath), path.Base(r.RelPath)); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		methodNotAllowed(w, "GET", "POST", "PATCH", "DELETE")
+	}
+}
@@ -0,0 +1,103 @@
+package apiv1
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/razzie/razbox"
+	"github.com/razzie/reqip"
+)
+
+// Prefix is the URL path prefix of the v1 API
+const Prefix = "/api/v1/"
+
+type request struct {
+	*http.Request
+	RelPath string
+	Session razbox.Session
+}
+
+type handlerFunc func(api *razbox.API, w http.ResponseWriter, r *request)
+
+// folderResolver returns the folder which the request path belongs to
+type folderResolver func(r *http.Request, relPath string) string
+
+func folderPath(_ *http.Request, relPath string) string {
+	return relPath
+}
+
+func parentFolderPath(_ *http.Request, relPath string) string {
+	return path.Dir(relPath)
+}
+
+// Handler returns a http.Handler that serves the v1 JSON API
+func Handler(api *razbox.API) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(Prefix+"folders/", endpoint(api, "folders/", folderPath, folderHandler))
+	mux.Handle(Prefix+"upload/", endpoint(api, "upload/", folderPath, uploadHandler))
+	mux.Handle(Prefix+"tus/", endpoint(api, "tus/", tusFolderPath, tusHandler))
+	mux.Handle(Prefix+"files/", endpoint(api, "files/", parentFolderPath, fileHandler))
+	mux.Handle(Prefix+"versions/", endpoint(api, "versions/", parentFolderPath, versionHandler))
+	mux.Handle(Prefix+"trash/", endpoint(api, "trash/", folderPath, trashHandler))
+	mux.Handle(Prefix+"thumbnails/", endpoint(api, "thumbnails/", parentFolderPath, thumbnailHandler))
+	mux.Handle(Prefix+"shares/", endpoint(api, "shares/", folderPath, sharesHandler))
+	mux.Handle(Prefix+"members/", endpoint(api, "members/", folderPath, membersHandler))
+	mux.Handle(Prefix+"settings/", endpoint(api, "settings/", folderPath, settingsHandler))
+	mux.Handle(Prefix+"audit/", endpoint(api, "audit/", folderPath, auditHandler))
+	mux.HandleFunc(Prefix, func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, &razbox.ErrNotFound{})
+	})
+	return mux
+}
+
+func endpoint(api *razbox.API, name string, folderOf folderResolver, handler handlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		relPath := path.Clean(strings.TrimPrefix(r.URL.Path, Prefix+name))
+		sess, err := getSession(api, r, folderOf(r, relPath))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		handler(api, w, &request{
+			Request: r,
+			RelPath: relPath,
+			Session: sess,
+		})
+	})
+}
+
+func getSession(api *razbox.API, r *http.Request, folder string) (razbox.Session, error) {
+	sess, err := getTokenSession(api, r, folder)
+	if err != nil {
+		return nil, err
+	}
+
+	// share links are accepted in the "share" query parameter
+	// (and their password in the X-Share-Password header)
+	shareToken := r.URL.Query().Get("share")
+	if len(shareToken) == 0 {
+		return sess, nil
+	}
+	if pw := r.Header.Get("X-Share-Password"); len(pw) > 0 {
+		if err := api.AuthShareLink(sess, shareToken, pw); err != nil {
+			return nil, err
+		}
+	}
+	return razbox.NewShareSession(sess, shareToken, r), nil
+}
+
+func getTokenSession(api *razbox.API, r *http.Request, folder string) (razbox.Session, error) {
+	ip := reqip.GetClientIP(r)
+	auth := r.Header.Get("Authorization")
+	if len(auth) == 0 {
+		return razbox.NewAnonymousSession(r.Context(), ip), nil
+	}
+
+	token := strings.TrimPrefix(auth, "Bearer ")
+	if token == auth {
+		return nil, &razbox.ErrInvalidAPIToken{}
+	}
+	return api.NewTokenSession(r.Context(), ip, folder, strings.TrimSpace(token))
+}
@@ -0,0 +1,59 @@
+package apiv1
+
+import (
+	"net/http"
+
+	"github.com/razzie/razbox"
+)
+
+type memberRequest struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+func membersHandler(api *razbox.API, w http.ResponseWriter, r *request) {
+	switch r.Method {
+	case "GET":
+		members, err := api.GetMembers(r.Session, r.RelPath)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, members)
+
+	case "POST":
+		var req memberRequest
+		if err := decodeJSON(r.Request, &req); err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := api.AddMember(r.Session, r.RelPath, req.Name, req.Password, req.Role); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+
+	case "PATCH":
+		var req memberRequest
+		if err := decodeJSON(r.Request, &req); err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := api.SetMemberRole(r.Session, r.RelPath, req.Name, req.Role); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case "DELETE":
+		if err := api.RemoveMember(r.Session, r.RelPath, r.URL.Query().Get("name")); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		methodNotAllowed(w, "GET", "POST", "PATCH", "DELETE")
+	}
+}
@@ -0,0 +1,49 @@
+package apiv1
+
+import (
+	"net/http"
+
+	"github.com/razzie/razbox"
+)
+
+func settingsHandler(api *razbox.API, w http.ResponseWriter, r *request) {
+	switch r.Method {
+	case "GET":
+		settings, err := api.GetFolderSettings(r.Session, r.RelPath)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, settings)
+
+	case "PUT", "PATCH":
+		// PATCH only changes the settings present in the request
+		settings := new(razbox.FolderSettings)
+		if r.Method == "PATCH" {
+			var err error
+			settings, err = api.GetFolderSettings(r.Session, r.RelPath)
+			if err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+		if err := decodeJSON(r.Request, settings); err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := api.SetFolderSettings(r.Session, r.RelPath, settings); err != nil {
+			writeError(w, err)
+			return
+		}
+		// the saved settings are normalized (like lowercase extensions)
+		settings, err := api.GetFolderSettings(r.Session, r.RelPath)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, settings)
+
+	default:
+		methodNotAllowed(w, "GET", "PUT", "PATCH")
+	}
+}
@@ -0,0 +1,56 @@
+package apiv1
+
+import (
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/razzie/razbox"
+)
+
+type createShareLinkRequest struct {
+	Path         string `json:"path"`       // relative to the folder (empty = the folder itself)
+	ExpiresIn    int64  `json:"expires_in"` // seconds
+	MaxDownloads int    `json:"max_downloads"`
+	Password     string `json:"password"`
+}
+
+func sharesHandler(api *razbox.API, w http.ResponseWriter, r *request) {
+	switch r.Method {
+	case "GET":
+		links, err := api.GetShareLinks(r.Session, r.RelPath)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, links)
+
+	case "POST":
+		var req createShareLinkRequest
+		if err := decodeJSON(r.Request, &req); err != nil {
+			writeError(w, err)
+			return
+		}
+		link, err := api.CreateShareLink(r.Session, &razbox.ShareLinkOptions{
+			Path:         path.Join(r.RelPath, path.Clean("/"+req.Path)),
+			ExpiresIn:    time.Duration(req.ExpiresIn) * time.Second,
+			MaxDownloads: req.MaxDownloads,
+			Password:     req.Password,
+		})
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, link)
+
+	case "DELETE":
+		if err := api.RevokeShareLink(r.Session, r.RelPath, r.URL.Query().Get("id")); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		methodNotAllowed(w, "GET", "POST", "DELETE")
+	}
+}
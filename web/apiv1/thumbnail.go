@@ -0,0 +1,29 @@
+package apiv1
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/razzie/razbox"
+)
+
+func thumbnailHandler(api *razbox.API, w http.ResponseWriter, r *request) {
+	if r.Method != "GET" {
+		methodNotAllowed(w, "GET")
+		return
+	}
+
+	thumb, err := api.GetFileThumbnail(r.Session, r.RelPath)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if len(thumb.Data) == 0 {
+		writeError(w, &razbox.ErrNotFound{})
+		return
+	}
+
+	w.Header().Set("Content-Type", thumb.MIME)
+	w.Header().Set("Content-Length", strconv.Itoa(len(thumb.Data)))
+	w.Write(thumb.Data)
+}
@@ -0,0 +1,51 @@
+package apiv1
+
+import (
+	"net/http"
+
+	"github.com/razzie/razbox"
+)
+
+type trashResponse struct {
+	Folder string                    `json:"folder"`
+	Files  []*razbox.TrashedFileInfo `json:"files"`
+}
+
+type restoreRequest struct {
+	ID string `json:"id"`
+}
+
+type restoreResponse struct {
+	File string `json:"file"`
+}
+
+func trashHandler(api *razbox.API, w http.ResponseWriter, r *request) {
+	switch r.Method {
+	case "GET":
+		files, err := api.GetTrash(r.Session, r.RelPath)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, &trashResponse{
+			Folder: r.RelPath,
+			Files:  files,
+		})
+
+	case "POST":
+		var req restoreRequest
+		if err := decodeJSON(r.Request, &req); err != nil {
+			writeError(w, err)
+			return
+		}
+		file, err := api.RestoreTrashedFile(r.Session, r.RelPath, req.ID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, &restoreResponse{File: file})
+
+	default:
+		methodNotAllowed(w, "GET", "POST")
+	}
+}
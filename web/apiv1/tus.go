@@ -0,0 +1,174 @@
+package apiv1
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/razzie/razbox"
+)
+
+// tus 1.0 resumable upload protocol (core + creation, expiration and termination extensions): https://tus.io/protocols/resumable-upload.html
+const tusVersion = "1.0.0"
+
+type errUnsupportedTusVersion struct{}
+
+func (err errUnsupportedTusVersion) Error() string {
+	return "Unsupported tus version"
+}
+
+func (err errUnsupportedTusVersion) Code() string {
+	return "unsupported_tus_version"
+}
+
+func (err errUnsupportedTusVersion) HTTPStatus() int {
+	return http.StatusPreconditionFailed
+}
+
+// uploads are created in tus/<folder> and are located at tus/<folder>/<id>
+func tusFolderPath(r *http.Request, relPath string) string {
+	if r.Method == "POST" || r.Method == "OPTIONS" {
+		return relPath
+	}
+	return path.Dir(relPath)
+}
+
+func tusHandler(api *razbox.API, w http.ResponseWriter, r *request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+
+	if r.Method == "OPTIONS" {
+		w.Header().Set("Tus-Version", tusVersion)
+		w.Header().Set("Tus-Extension", "creation,expiration,termination")
+		if flags, err := api.GetFolderFlags(r.Session, r.RelPath); err == nil {
+			w.Header().Set("Tus-Max-Size", strconv.FormatInt(flags.MaxUploadSizeMB<<20, 10))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		writeError(w, &errUnsupportedTusVersion{})
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		createTusUpload(api, w, r)
+	case "HEAD":
+		getTusUpload(api, w, r)
+	case "PATCH":
+		patchTusUpload(api, w, r)
+	case "DELETE":
+		if err := api.DeleteResumableUpload(r.Session, path.Dir(r.RelPath), path.Base(r.RelPath)); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, "OPTIONS", "POST", "HEAD", "PATCH", "DELETE")
+	}
+}
+
+func createTusUpload(api *razbox.API, w http.ResponseWriter, r *request) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil {
+		writeError(w, &razbox.ErrInvalidUploadLength{})
+		return
+	}
+
+	meta := parseTusMetadata(r.Header.Get("Upload-Metadata"))
+	filename := meta["filename"]
+	if len(filename) == 0 {
+		filename = meta["name"]
+	}
+
+	o := &razbox.ResumableUploadOptions{
+		Folder:    r.RelPath,
+		Filename:  filename,
+		Length:    length,
+		Tags:      strings.Fields(meta["tags"]),
+		Overwrite: isTrue(meta["overwrite"]),
+		Public:    isTrue(meta["public"]),
+	}
+	upload, err := api.CreateResumableUpload(r.Session, o)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	location := &url.URL{Path: Prefix + "tus/" + path.Join(upload.Folder, upload.ID)}
+	w.Header().Set("Location", location.String())
+	setTusExpires(w, upload)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func getTusUpload(api *razbox.API, w http.ResponseWriter, r *request) {
+	upload, err := api.GetResumableUpload(r.Session, path.Dir(r.RelPath), path.Base(r.RelPath))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	setTusExpires(w, upload)
+	w.WriteHeader(http.StatusOK)
+}
+
+func patchTusUpload(api *razbox.API, w http.ResponseWriter, r *request) {
+	if contentType := r.Header.Get("Content-Type"); contentType != "application/offset+octet-stream" {
+		writeError(w, &razbox.ErrInvalidContentType{ContentType: contentType})
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		writeError(w, &errBadRequest{err: err})
+		return
+	}
+
+	upload, err := api.WriteResumableUpload(r.Session, path.Dir(r.RelPath), path.Base(r.RelPath), offset, r.Body)
+	if upload != nil {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+		if upload.Offset < upload.Length {
+			setTusExpires(w, upload)
+		}
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setTusExpires sets the Upload-Expires header (expiration extension)
+func setTusExpires(w http.ResponseWriter, upload *razbox.ResumableUpload) {
+	w.Header().Set("Upload-Expires", upload.Expires.UTC().Format(http.TimeFormat))
+}
+
+// parseTusMetadata parses the Upload-Metadata header (comma separated "key base64value" pairs)
+func parseTusMetadata(header string) map[string]string {
+	meta := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		kv := strings.Fields(pair)
+		if len(kv) == 0 {
+			continue
+		}
+		var value []byte
+		if len(kv) > 1 {
+			value, _ = base64.StdEncoding.DecodeString(kv[1])
+		}
+		meta[kv[0]] = string(value)
+	}
+	return meta
+}
+
+func isTrue(value string) bool {
+	b, _ := strconv.ParseBool(value)
+	return b
+}
@@ -0,0 +1,47 @@
+package apiv1
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/razzie/razbox"
+)
+
+func uploadHandler(api *razbox.API, w http.ResponseWriter, r *request) {
+	if r.Method != "POST" {
+		methodNotAllowed(w, "POST")
+		return
+	}
+
+	flags, err := api.GetUploadFlags(r.Session, r.RelPath)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	limit := (flags.MaxUploadSizeMB + 10) << 20
+	if r.ContentLength > limit {
+		writeError(w, &razbox.ErrSizeLimitExceeded{})
+		return
+	}
+
+	r.Body = &razbox.LimitedReadCloser{
+		R: r.Body,
+		N: limit,
+	}
+	q := r.URL.Query()
+	o := &razbox.UploadFileOptions{
+		Folder:      r.RelPath,
+		Content:     r.Body,
+		ContentType: r.Header.Get("Content-Type"),
+		Filename:    q.Get("filename"),
+		Tags:        strings.Fields(q.Get("tags")),
+		Overwrite:   isTrue(q.Get("overwrite")),
+		Public:      isTrue(q.Get("public")),
+	}
+	if err := api.UploadFile(r.Session, o); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
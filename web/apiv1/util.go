@@ -0,0 +1,87 @@
+package apiv1
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/razzie/razbox"
+)
+
+type errorBody struct {
+	Error errorDetails `json:"error"`
+}
+
+type errorDetails struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type errMethodNotAllowed struct {
+	allowed []string
+}
+
+func (err errMethodNotAllowed) Error() string {
+	return "Method not allowed"
+}
+
+func (err errMethodNotAllowed) Code() string {
+	return "method_not_allowed"
+}
+
+func (err errMethodNotAllowed) HTTPStatus() int {
+	return http.StatusMethodNotAllowed
+}
+
+type errBadRequest struct {
+	err error
+}
+
+func (err errBadRequest) Error() string {
+	return "Bad request: " + err.err.Error()
+}
+
+func (err errBadRequest) Code() string {
+	return "bad_request"
+}
+
+func (err errBadRequest) HTTPStatus() int {
+	return http.StatusBadRequest
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status, code := razbox.ClassifyError(err)
+	if retryAfter, ok := razbox.GetRetryAfter(err); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	}
+	switch err := err.(type) {
+	case *razbox.ErrInvalidAPIToken:
+		w.Header().Set("WWW-Authenticate", `Bearer realm="razbox"`)
+	case *errMethodNotAllowed:
+		w.Header().Set("Allow", strings.Join(err.allowed, ", "))
+	}
+	writeJSON(w, status, &errorBody{
+		Error: errorDetails{
+			Code:    code,
+			Message: err.Error(),
+		},
+	})
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	writeError(w, &errMethodNotAllowed{allowed: allowed})
+}
+
+func decodeJSON(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return &errBadRequest{err: err}
+	}
+	return nil
+}
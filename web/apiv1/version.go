@@ -0,0 +1,46 @@
+package apiv1
+
+import (
+	"net/http"
+
+	"github.com/razzie/razbox"
+)
+
+type versionsResponse struct {
+	File     string                    `json:"file"`
+	Versions []*razbox.FileVersionInfo `json:"versions"`
+}
+
+type restoreVersionRequest struct {
+	Version int `json:"version"`
+}
+
+func versionHandler(api *razbox.API, w http.ResponseWriter, r *request) {
+	switch r.Method {
+	case "GET":
+		versions, err := api.GetFileVersions(r.Session, r.RelPath)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, &versionsResponse{
+			File:     r.RelPath,
+			Versions: versions,
+		})
+
+	case "POST":
+		var req restoreVersionRequest
+		if err := decodeJSON(r.Request, &req); err != nil {
+			writeError(w, err)
+			return
+		}
+		if err := api.RestoreFileVersion(r.Session, r.RelPath, req.Version); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		methodNotAllowed(w, "GET", "POST")
+	}
+}
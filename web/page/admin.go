@@ -0,0 +1,156 @@
+package page
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/razzie/beepboop"
+	"github.com/razzie/razbox"
+)
+
+type adminPageView struct {
+	Error   string                    `json:"error,omitempty"`
+	Login   bool                      `json:"login,omitempty"`
+	Folders []*razbox.AdminFolderInfo `json:"folders,omitempty"`
+	Created string                    `json:"created,omitempty"`
+}
+
+type adminFolderPageView struct {
+	Error   string                  `json:"error,omitempty"`
+	Folder  *razbox.AdminFolderInfo `json:"folder,omitempty"`
+	Message string                  `json:"message,omitempty"`
+}
+
+func adminPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.View {
+	r := pr.Request
+	pr.Title = "Admin"
+	v := &adminPageView{}
+
+	if !api.IsAdminEnabled() {
+		return HandleError(r, &razbox.ErrAdminDisabled{})
+	}
+
+	if r.Method == "POST" {
+		r.ParseForm()
+		if r.FormValue("action") == "login" {
+			if err := api.AuthAdmin(pr, r.FormValue("admin-password")); err != nil {
+				v.Error = err.Error()
+				v.Login = true
+				return pr.Respond(v, WithError(err))
+			}
+			return pr.RedirectView("/admin/")
+		}
+	}
+
+	if !api.IsAdmin(pr.Session()) {
+		v.Login = true
+		return pr.Respond(v)
+	}
+
+	var actionErr error
+	if r.Method == "POST" && r.FormValue("action") == "create" {
+		o := &razbox.CreateFolderOptions{
+			Folder:         r.FormValue("folder"),
+			ReadPassword:   r.FormValue("readpw"),
+			WritePassword:  r.FormValue("writepw"),
+			UploadPassword: r.FormValue("uploadpw"),
+		}
+		o.Settings.MaxFileSizeMB, _ = strconv.ParseInt(r.FormValue("max_file_size"), 10, 64)
+		o.Settings.MaxFolderSizeMB, _ = strconv.ParseInt(r.FormValue("max_folder_size"), 10, 64)
+		o.Settings.Subfolders = r.FormValue("subfolders") == "subfolders"
+		o.Settings.Dedup = r.FormValue("dedup") == "dedup"
+		v.Created, actionErr = api.CreateFolder(pr.Session(), o)
+	}
+
+	folders, err := api.GetAllFolders(pr.Session())
+	if err != nil {
+		return HandleError(r, err)
+	}
+	v.Folders = folders
+
+	if actionErr != nil {
+		v.Error = actionErr.Error()
+		return pr.Respond(v, WithError(actionErr))
+	}
+	return pr.Respond(v)
+}
+
+func adminFolderPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.View {
+	r := pr.Request
+	dir := path.Clean(pr.RelPath)
+	pr.Title = "Admin: " + dir
+	v := &adminFolderPageView{}
+
+	if !api.IsAdmin(pr.Session()) {
+		return pr.RedirectView("/admin/", beepboop.WithErrorMessage("Admin access required", http.StatusUnauthorized))
+	}
+
+	var actionErr error
+	if r.Method == "POST" {
+		r.ParseForm()
+		switch r.FormValue("action") {
+		case "settings":
+			var settings *razbox.FolderSettings
+			settings, actionErr = parseSettingsForm(r)
+			if actionErr == nil {
+				actionErr = api.AdminSetFolderSettings(pr.Session(), dir, settings)
+			}
+			if actionErr == nil {
+				v.Message = "Settings saved"
+			}
+
+		case "password":
+			accessType := r.FormValue("access_type")
+			if pw := r.FormValue("password"); pw != r.FormValue("password-confirm") {
+				v.Error = "Password mismatch"
+			} else {
+				actionErr = api.AdminResetFolderPassword(pr.Session(), dir, accessType, pw)
+				if actionErr == nil {
+					v.Message = accessType + " password changed"
+				}
+			}
+
+		case "delete":
+			if err := api.DeleteFolder(pr.Session(), dir); err != nil {
+				actionErr = err
+				break
+			}
+			return pr.RedirectView("/admin/")
+		}
+	}
+
+	folder, err := api.GetFolderInfo(pr.Session(), dir)
+	if err != nil {
+		return HandleError(r, err)
+	}
+	v.Folder = folder
+
+	if actionErr != nil {
+		v.Error = actionErr.Error()
+		return pr.Respond(v, WithError(actionErr))
+	}
+	return pr.Respond(v)
+}
+
+// Admin returns a beepboop.Page that lists, creates and administers the config root folders
+func Admin(api *razbox.API) *beepboop.Page {
+	return &beepboop.Page{
+		Path:            "/admin/",
+		ContentTemplate: GetContentTemplate("admin"),
+		Handler: func(pr *beepboop.PageRequest) *beepboop.View {
+			return adminPageHandler(api, pr)
+		},
+	}
+}
+
+// AdminFolder returns a beepboop.Page that configures, resets passwords on or deletes a config root folder
+func AdminFolder(api *razbox.API) *beepboop.Page {
+	return &beepboop.Page{
+		Path:            "/admin-folder/",
+		ContentTemplate: GetContentTemplate("admin-folder"),
+		Handler: func(pr *beepboop.PageRequest) *beepboop.View {
+			return adminFolderPageHandler(api, pr)
+		},
+	}
+}
@@ -0,0 +1,74 @@
+package page
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/razzie/beepboop"
+	"github.com/razzie/razbox"
+)
+
+type apiTokensPageView struct {
+	Error    string                 `json:"error,omitempty"`
+	Folder   string                 `json:"folder,omitempty"`
+	Tokens   []*razbox.APITokenInfo `json:"tokens,omitempty"`
+	NewToken string                 `json:"new_token,omitempty"`
+	NewName  string                 `json:"new_name,omitempty"`
+}
+
+func apiTokensPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.View {
+	r := pr.Request
+	dir := path.Clean(pr.RelPath)
+	pr.Title = "API tokens of " + dir
+	v := &apiTokensPageView{
+		Folder: dir,
+	}
+
+	flags, err := api.GetFolderFlags(pr.Session(), dir)
+	if err != nil {
+		return HandleError(r, err)
+	}
+
+	if !flags.AdminMode {
+		return pr.RedirectView(
+			fmt.Sprintf("/write-auth/%s?r=%s", dir, r.URL.RequestURI()),
+			beepboop.WithErrorMessage("Admin access required", http.StatusUnauthorized))
+	}
+
+	var actionErr error
+	if r.Method == "POST" {
+		r.ParseForm()
+		name := r.FormValue("name")
+
+		switch r.FormValue("action") {
+		case "create":
+			v.NewToken, actionErr = api.CreateAPIToken(pr.Session(), dir, name, r.FormValue("access_type"))
+			v.NewName = name
+		case "revoke":
+			actionErr = api.RevokeAPIToken(pr.Session(), dir, name)
+		}
+	}
+
+	v.Tokens, err = api.GetAPITokens(pr.Session(), dir)
+	if err != nil {
+		return HandleError(r, err)
+	}
+
+	if actionErr != nil {
+		v.Error = actionErr.Error()
+		return pr.Respond(v, WithError(actionErr))
+	}
+	return pr.Respond(v)
+}
+
+// APITokens returns a beepboop.Page that handles API token management of folders
+func APITokens(api *razbox.API) *beepboop.Page {
+	return &beepboop.Page{
+		Path:            "/api-tokens/",
+		ContentTemplate: GetContentTemplate("api-tokens"),
+		Handler: func(pr *beepboop.PageRequest) *beepboop.View {
+			return apiTokensPageHandler(api, pr)
+		},
+	}
+}
@@ -0,0 +1,88 @@
+package page
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/razzie/beepboop"
+	"github.com/razzie/razbox"
+)
+
+type auditEntryView struct {
+	*razbox.AuditEntry
+	TimeString string `json:"time_string"`
+}
+
+type auditPageView struct {
+	Error   string            `json:"error,omitempty"`
+	Folder  string            `json:"folder,omitempty"`
+	Actions []string          `json:"actions,omitempty"`
+	Action  string            `json:"action,omitempty"`
+	Query   string            `json:"query,omitempty"`
+	Since   string            `json:"since,omitempty"`
+	Until   string            `json:"until,omitempty"`
+	Entries []*auditEntryView `json:"entries,omitempty"`
+}
+
+func auditPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.View {
+	r := pr.Request
+	dir := path.Clean(pr.RelPath)
+	pr.Title = "Audit log of " + dir
+	q := r.URL.Query()
+	v := &auditPageView{
+		Folder:  dir,
+		Actions: razbox.AuditActions,
+		Action:  q.Get("action"),
+		Query:   q.Get("q"),
+		Since:   q.Get("since"),
+		Until:   q.Get("until"),
+	}
+
+	flags, err := api.GetFolderFlags(pr.Session(), dir)
+	if err != nil {
+		return HandleError(r, err)
+	}
+
+	if !flags.EditMode {
+		return pr.RedirectView(
+			fmt.Sprintf("/write-auth/%s?r=%s", dir, r.URL.RequestURI()),
+			beepboop.WithErrorMessage("Write access required", http.StatusUnauthorized))
+	}
+
+	filter := &razbox.AuditLogFilter{
+		Action: v.Action,
+		Query:  v.Query,
+	}
+	if since, err := time.ParseInLocation("2006-01-02", v.Since, time.Local); err == nil {
+		filter.Since = since
+	}
+	if until, err := time.ParseInLocation("2006-01-02", v.Until, time.Local); err == nil {
+		filter.Until = until.AddDate(0, 0, 1)
+	}
+
+	entries, err := api.GetAuditLog(pr.Session(), dir, filter)
+	if err != nil {
+		return HandleError(r, err)
+	}
+	for _, e := range entries {
+		v.Entries = append(v.Entries, &auditEntryView{
+			AuditEntry: e,
+			TimeString: time.Unix(e.Time, 0).Format("2006-01-02 15:04:05"),
+		})
+	}
+
+	return pr.Respond(v)
+}
+
+// Audit returns a beepboop.Page that shows the filtered audit log of a folder
+func Audit(api *razbox.API) *beepboop.Page {
+	return &beepboop.Page{
+		Path:            "/audit/",
+		ContentTemplate: GetContentTemplate("audit"),
+		Handler: func(pr *beepboop.PageRequest) *beepboop.View {
+			return auditPageHandler(api, pr)
+		},
+	}
+}
@@ -3,7 +3,6 @@ package page
 import (
 	"encoding/base64"
 	"fmt"
-	"net/http"
 	"path"
 
 	"github.com/razzie/beepboop"
@@ -30,17 +29,28 @@ func authPageHandler(api *razbox.API, accessType string, pr *beepboop.PageReques
 		Redirect:      r.URL.Query().Get("r"),
 	}
 	if len(v.Redirect) == 0 {
-		v.Redirect = "/x/" + dir
+		if accessType == "upload" {
+			v.Redirect = "/upload/" + dir
+		} else {
+			v.Redirect = "/x/" + dir
+		}
 	}
 
 	if r.Method == "POST" {
 		r.ParseForm()
 		pw := r.FormValue(pwPrefix + "-password")
+		member := r.FormValue("member")
 		v.Redirect = r.FormValue("redirect")
 
-		if err := api.Auth(pr, dir, accessType, pw); err != nil {
+		var err error
+		if len(member) > 0 {
+			err = api.AuthMember(pr, dir, member, pw)
+		} else {
+			err = api.Auth(pr, dir, accessType, pw)
+		}
+		if err != nil {
 			v.Error = err.Error()
-			return pr.Respond(v, beepboop.WithError(err, http.StatusUnauthorized))
+			return pr.Respond(v, WithError(err))
 		}
 
 		return pr.RedirectView(v.Redirect)
@@ -60,7 +70,7 @@ func ReadAuth(api *razbox.API) *beepboop.Page {
 	}
 }
 
-// WriteAuth returns a beepboop.Page that handles authentication for read access
+// WriteAuth returns a beepboop.Page that handles authentication for write access
 func WriteAuth(api *razbox.API) *beepboop.Page {
 	return &beepboop.Page{
 		Path:            "/write-auth/",
@@ -70,3 +80,14 @@ func WriteAuth(api *razbox.API) *beepboop.Page {
 		},
 	}
 }
+
+// UploadAuth returns a beepboop.Page that handles authentication for upload-only access
+func UploadAuth(api *razbox.API) *beepboop.Page {
+	return &beepboop.Page{
+		Path:            "/upload-auth/",
+		ContentTemplate: GetContentTemplate("auth"),
+		Handler: func(pr *beepboop.PageRequest) *beepboop.View {
+			return authPageHandler(api, "upload", pr)
+		},
+	}
+}
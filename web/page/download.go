@@ -2,7 +2,6 @@ package page
 
 import (
 	"fmt"
-	"net/http"
 	"path"
 	"strings"
 
@@ -19,21 +18,16 @@ type downloadPageView struct {
 func downloadPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.View {
 	r := pr.Request
 	dir := path.Clean(pr.RelPath)
-	flags, err := api.GetFolderFlags(pr.Session(), dir)
+	flags, err := api.GetUploadFlags(pr.Session(), dir)
 	if err != nil {
 		return HandleError(r, err)
 	}
 
-	if !flags.EditMode {
-		return pr.RedirectView(
-			fmt.Sprintf("/write-auth/%s?r=%s", dir, r.URL.RequestURI()),
-			beepboop.WithErrorMessage("Write access required", http.StatusUnauthorized))
-	}
-
 	pr.Title = "Download file to " + dir
 	v := &uploadPageView{
 		Folder:      dir,
 		MaxFileSize: fmt.Sprintf("%dMB", flags.MaxUploadSizeMB),
+		UploadOnly:  !flags.EditMode,
 	}
 
 	if r.Method == "POST" {
@@ -50,9 +44,12 @@ func downloadPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.Vi
 		err := api.DownloadFileToFolder(pr.Session(), o)
 		if err != nil {
 			v.Error = err.Error()
-			return pr.Respond(v, beepboop.WithError(err, http.StatusInternalServerError))
+			return pr.Respond(v, WithError(err))
 		}
 
+		if v.UploadOnly {
+			return pr.RedirectView("/download-to-folder/" + dir)
+		}
 		return pr.RedirectView("/x/" + dir)
 	}
 
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/razzie/beepboop"
@@ -11,14 +12,15 @@ import (
 )
 
 type editPageView struct {
-	Error      string   `json:"error,omitempty"`
-	Folder     string   `json:"folder,omitempty"`
-	Filename   string   `json:"filename,omitempty"`
-	Tags       string   `json:"tags,omitempty"`
-	Public     bool     `json:"public,omitempty"`
-	Redirect   string   `json:"redirect,omitempty"`
-	Thumb      bool     `json:"thumb,omitempty"`
-	Subfolders []string `json:"subfolders,omitempty"`
+	Error      string                    `json:"error,omitempty"`
+	Folder     string                    `json:"folder,omitempty"`
+	Filename   string                    `json:"filename,omitempty"`
+	Tags       string                    `json:"tags,omitempty"`
+	Public     bool                      `json:"public,omitempty"`
+	Redirect   string                    `json:"redirect,omitempty"`
+	Thumb      bool                      `json:"thumb,omitempty"`
+	Subfolders []string                  `json:"subfolders,omitempty"`
+	Versions   []*razbox.FileVersionInfo `json:"versions,omitempty"`
 }
 
 func editPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.View {
@@ -43,6 +45,7 @@ func editPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.View {
 
 	pr.Title = "Edit " + filename
 	subfolders, _ := api.GetSubfolders(pr.Session(), dir)
+	versions, _ := api.GetFileVersions(pr.Session(), filename)
 	v := &editPageView{
 		Folder:     dir,
 		Filename:   entry[0].Name,
@@ -51,11 +54,22 @@ func editPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.View {
 		Redirect:   redirect,
 		Thumb:      entry[0].HasThumbnail,
 		Subfolders: subfolders,
+		Versions:   versions,
 	}
 
 	if r.Method == "POST" {
 		r.ParseForm()
 
+		if restore := r.FormValue("restore_version"); len(restore) > 0 {
+			version, _ := strconv.Atoi(restore)
+			err := api.RestoreFileVersion(pr.Session(), filename, version)
+			if err != nil {
+				v.Error = err.Error()
+				return pr.Respond(v, WithError(err))
+			}
+			return pr.RedirectView(r.URL.RequestURI())
+		}
+
 		o := &razbox.EditFileOptions{
 			Folder:           dir,
 			OriginalFilename: entry[0].Name,
@@ -67,7 +81,7 @@ func editPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.View {
 		err := api.EditFile(pr.Session(), o)
 		if err != nil {
 			v.Error = err.Error()
-			return pr.Respond(v, beepboop.WithError(err, http.StatusInternalServerError))
+			return pr.Respond(v, WithError(err))
 		}
 
 		return pr.RedirectView(redirect)
@@ -2,6 +2,7 @@ package page
 
 import (
 	"path"
+	"strconv"
 
 	"github.com/razzie/beepboop"
 	"github.com/razzie/razbox"
@@ -14,6 +15,8 @@ type folderPageView struct {
 	Tags         []string              `json:"tags,omitempty"`
 	EditMode     bool                  `json:"edit_mode,omitempty"`
 	Editable     bool                  `json:"editable,omitempty"`
+	AdminMode    bool                  `json:"admin_mode,omitempty"`
+	Uploadable   bool                  `json:"uploadable,omitempty"`
 	Deletable    bool                  `json:"deletable,omitempty"`
 	Configurable bool                  `json:"configurable,omitempty"`
 	Subfolders   bool                  `json:"subfolders,omitempty"`
@@ -42,11 +45,21 @@ func folderPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.View
 			}
 		}
 
-		reader, err := api.OpenFile(pr.Session(), folderOrFilename)
+		var reader razbox.FileReader
+		if version := r.URL.Query().Get("version"); len(version) > 0 {
+			versionNum, _ := strconv.Atoi(version)
+			reader, err = api.OpenFileVersion(pr.Session(), folderOrFilename, versionNum)
+		} else {
+			reader, err = api.OpenFile(pr.Session(), folderOrFilename)
+		}
 		if err != nil {
 			return HandleError(r, err)
 		}
-		return pr.FileView(reader, reader.MimeType(), download)
+		var opts []beepboop.ViewOption
+		for key, values := range razbox.GetChecksumHeaders(reader.Hash()) {
+			opts = append(opts, beepboop.WithHeader(key, values[0]))
+		}
+		return pr.FileView(reader, reader.MimeType(), download, opts...)
 	}
 
 	pr.Title = folderOrFilename
@@ -55,6 +68,8 @@ func folderPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.View
 		Search:       tag,
 		EditMode:     flags.EditMode,
 		Editable:     flags.Editable,
+		AdminMode:    flags.AdminMode,
+		Uploadable:   flags.Uploadable,
 		Deletable:    flags.Deletable,
 		Configurable: flags.Configurable,
 		Subfolders:   flags.Subfolders,
@@ -0,0 +1,77 @@
+package page
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/razzie/beepboop"
+	"github.com/razzie/razbox"
+)
+
+type membersPageView struct {
+	Error   string               `json:"error,omitempty"`
+	Folder  string               `json:"folder,omitempty"`
+	Members []*razbox.MemberInfo `json:"members,omitempty"`
+}
+
+func membersPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.View {
+	r := pr.Request
+	dir := path.Clean(pr.RelPath)
+	pr.Title = "Members of " + dir
+	v := &membersPageView{
+		Folder: dir,
+	}
+
+	flags, err := api.GetFolderFlags(pr.Session(), dir)
+	if err != nil {
+		return HandleError(r, err)
+	}
+
+	if !flags.AdminMode {
+		return pr.RedirectView(
+			fmt.Sprintf("/write-auth/%s?r=%s", dir, r.URL.RequestURI()),
+			beepboop.WithErrorMessage("Admin access required", http.StatusUnauthorized))
+	}
+
+	var actionErr error
+	if r.Method == "POST" {
+		r.ParseForm()
+		name := r.FormValue("name")
+
+		switch r.FormValue("action") {
+		case "add":
+			if pw := r.FormValue("password"); pw != r.FormValue("password-confirm") {
+				v.Error = "Password mismatch"
+			} else {
+				actionErr = api.AddMember(pr.Session(), dir, name, pw, r.FormValue("role"))
+			}
+		case "set-role":
+			actionErr = api.SetMemberRole(pr.Session(), dir, name, r.FormValue("role"))
+		case "remove":
+			actionErr = api.RemoveMember(pr.Session(), dir, name)
+		}
+	}
+
+	v.Members, err = api.GetMembers(pr.Session(), dir)
+	if err != nil {
+		return HandleError(r, err)
+	}
+
+	if actionErr != nil {
+		v.Error = actionErr.Error()
+		return pr.Respond(v, WithError(actionErr))
+	}
+	return pr.Respond(v)
+}
+
+// Members returns a beepboop.Page that handles member management of folders
+func Members(api *razbox.API) *beepboop.Page {
+	return &beepboop.Page{
+		Path:            "/members/",
+		ContentTemplate: GetContentTemplate("members"),
+		Handler: func(pr *beepboop.PageRequest) *beepboop.View {
+			return membersPageHandler(api, pr)
+		},
+	}
+}
@@ -14,7 +14,7 @@ type passwordPageView struct {
 	Error         string `json:"error,omitempty"`
 	Folder        string `json:"folder,omitempty"`
 	PwFieldPrefix string `json:"pw_field_prefix,omitempty"`
-	WriteAccess   bool   `json:"write_access,omitempty"`
+	AccessType    string `json:"access_type,omitempty"`
 }
 
 func passwordPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.View {
@@ -32,9 +32,7 @@ func passwordPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.Vi
 		pw := r.FormValue(v.PwFieldPrefix + "-password")
 		pwconfirm := r.FormValue(v.PwFieldPrefix + "-password-confirm")
 
-		if accessType == "write" {
-			v.WriteAccess = true
-		}
+		v.AccessType = accessType
 
 		if pw != pwconfirm {
 			v.Error = "Password mismatch"
@@ -43,7 +41,7 @@ func passwordPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.Vi
 
 		if err := api.ChangeFolderPassword(pr.Session(), dir, accessType, pw); err != nil {
 			v.Error = err.Error()
-			return pr.Respond(v, beepboop.WithError(err, http.StatusInternalServerError))
+			return pr.Respond(v, WithError(err))
 		}
 
 		return pr.RedirectView("/x/" + dir)
@@ -54,10 +52,10 @@ func passwordPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.Vi
 		return HandleError(r, err)
 	}
 
-	if !flags.EditMode {
+	if !flags.AdminMode {
 		return pr.RedirectView(
 			fmt.Sprintf("/write-auth/%s?r=%s", dir, r.URL.RequestURI()),
-			beepboop.WithErrorMessage("Write access required", http.StatusUnauthorized))
+			beepboop.WithErrorMessage("Admin access required", http.StatusUnauthorized))
 	}
 
 	return pr.Respond(v)
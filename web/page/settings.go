@@ -0,0 +1,102 @@
+package page
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/razzie/beepboop"
+	"github.com/razzie/razbox"
+)
+
+type settingsPageView struct {
+	Error    string                 `json:"error,omitempty"`
+	Folder   string                 `json:"folder,omitempty"`
+	Settings *razbox.FolderSettings `json:"settings,omitempty"`
+	Saved    bool                   `json:"saved,omitempty"`
+}
+
+// parseListField splits a space or comma separated form field
+func parseListField(r *http.Request, field string) []string {
+	return strings.Fields(strings.ReplaceAll(r.FormValue(field), ",", " "))
+}
+
+func parseSettingsForm(r *http.Request) (*razbox.FolderSettings, error) {
+	var err error
+	s := &razbox.FolderSettings{
+		Subfolders:        r.FormValue("subfolders") == "subfolders",
+		Dedup:             r.FormValue("dedup") == "dedup",
+		AllowedTypes:      parseListField(r, "allowed_types"),
+		BlockedTypes:      parseListField(r, "blocked_types"),
+		AllowedExtensions: parseListField(r, "allowed_extensions"),
+		BlockedExtensions: parseListField(r, "blocked_extensions"),
+	}
+	if s.MaxFileSizeMB, err = strconv.ParseInt(r.FormValue("max_file_size"), 10, 64); err != nil {
+		return nil, &razbox.ErrInvalidFolderSetting{Setting: "max file size"}
+	}
+	if s.MaxFolderSizeMB, err = strconv.ParseInt(r.FormValue("max_folder_size"), 10, 64); err != nil {
+		return nil, &razbox.ErrInvalidFolderSetting{Setting: "max folder size"}
+	}
+	if s.TrashRetentionDays, err = strconv.Atoi(r.FormValue("trash_retention")); err != nil {
+		return nil, &razbox.ErrInvalidFolderSetting{Setting: "trash retention"}
+	}
+	if s.MaxFileVersions, err = strconv.Atoi(r.FormValue("max_file_versions")); err != nil {
+		return nil, &razbox.ErrInvalidFolderSetting{Setting: "max file versions"}
+	}
+	return s, nil
+}
+
+func settingsPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.View {
+	r := pr.Request
+	dir := path.Clean(pr.RelPath)
+	pr.Title = "Settings of " + dir
+	v := &settingsPageView{
+		Folder: dir,
+	}
+
+	flags, err := api.GetFolderFlags(pr.Session(), dir)
+	if err != nil {
+		return HandleError(r, err)
+	}
+
+	if !flags.AdminMode {
+		return pr.RedirectView(
+			fmt.Sprintf("/write-auth/%s?r=%s", dir, r.URL.RequestURI()),
+			beepboop.WithErrorMessage("Admin access required", http.StatusUnauthorized))
+	}
+
+	var saveErr error
+	if r.Method == "POST" {
+		r.ParseForm()
+		var settings *razbox.FolderSettings
+		settings, saveErr = parseSettingsForm(r)
+		if saveErr == nil {
+			saveErr = api.SetFolderSettings(pr.Session(), dir, settings)
+		}
+		v.Saved = saveErr == nil
+	}
+
+	v.Settings, err = api.GetFolderSettings(pr.Session(), dir)
+	if err != nil {
+		return HandleError(r, err)
+	}
+
+	if saveErr != nil {
+		v.Error = saveErr.Error()
+		return pr.Respond(v, WithError(saveErr))
+	}
+	return pr.Respond(v)
+}
+
+// Settings returns a beepboop.Page that changes the config options of a folder
+func Settings(api *razbox.API) *beepboop.Page {
+	return &beepboop.Page{
+		Path:            "/settings/",
+		ContentTemplate: GetContentTemplate("settings"),
+		Handler: func(pr *beepboop.PageRequest) *beepboop.View {
+			return settingsPageHandler(api, pr)
+		},
+	}
+}
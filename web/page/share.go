@@ -0,0 +1,92 @@
+package page
+
+import (
+	"path"
+	"strings"
+
+	"github.com/razzie/beepboop"
+	"github.com/razzie/razbox"
+)
+
+type sharePageView struct {
+	Error    string                `json:"error,omitempty"`
+	Token    string                `json:"token,omitempty"`
+	Name     string                `json:"name,omitempty"`
+	Password bool                  `json:"password,omitempty"`
+	Entries  []*razbox.FolderEntry `json:"entries,omitempty"`
+}
+
+func sharePageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.View {
+	r := pr.Request
+	relPath := strings.SplitN(strings.TrimPrefix(pr.RelPath, "/"), "/", 2)
+	token := relPath[0]
+	sharedPath, isFolder, err := api.GetSharedPath(token)
+	if err != nil {
+		return HandleError(r, err)
+	}
+
+	target := sharedPath
+	if isFolder && len(relPath) > 1 {
+		target = path.Join(sharedPath, path.Clean("/"+relPath[1]))
+	}
+	pr.Title = path.Base(target)
+	v := &sharePageView{
+		Token: token,
+		Name:  path.Base(target),
+	}
+
+	if r.Method == "POST" {
+		r.ParseForm()
+		if err := api.AuthShareLink(pr.Session(), token, r.FormValue("share-password")); err != nil {
+			v.Error = err.Error()
+			v.Password = true
+			return pr.Respond(v, WithError(err))
+		}
+		return pr.RedirectView(r.URL.RequestURI())
+	}
+
+	sess := razbox.NewShareSession(pr.Session(), token, r)
+	entries, flags, err := api.GetFolderEntries(sess, target)
+	if err != nil {
+		if _, ok := err.(*razbox.ErrSharePasswordRequired); ok {
+			v.Password = true
+			return pr.Respond(v)
+		}
+		return HandleError(r, err)
+	}
+
+	// this is a file
+	if flags == nil {
+		reader, err := api.OpenFile(sess, target)
+		if err != nil {
+			return HandleError(r, err)
+		}
+		_, download := r.URL.Query()["download"]
+		var opts []beepboop.ViewOption
+		for key, values := range razbox.GetChecksumHeaders(reader.Hash()) {
+			opts = append(opts, beepboop.WithHeader(key, values[0]))
+		}
+		return pr.FileView(reader, reader.MimeType(), download, opts...)
+	}
+
+	for _, entry := range entries {
+		if entry.RelPath != sharedPath && !strings.HasPrefix(entry.RelPath, sharedPath+"/") {
+			continue
+		}
+		entry.RelPath = path.Join(token, strings.TrimPrefix(entry.RelPath, sharedPath))
+		v.Entries = append(v.Entries, entry)
+	}
+
+	return pr.Respond(v)
+}
+
+// Share returns a beepboop.Page that handles share links
+func Share(api *razbox.API) *beepboop.Page {
+	return &beepboop.Page{
+		Path:            "/share/",
+		ContentTemplate: GetContentTemplate("share"),
+		Handler: func(pr *beepboop.PageRequest) *beepboop.View {
+			return sharePageHandler(api, pr)
+		},
+	}
+}
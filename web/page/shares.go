@@ -0,0 +1,98 @@
+package page
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/razzie/beepboop"
+	"github.com/razzie/razbox"
+)
+
+type shareLinkView struct {
+	*razbox.ShareLinkInfo
+	ExpiresAt string `json:"expires_at"`
+}
+
+type sharesPageView struct {
+	Error   string                `json:"error,omitempty"`
+	Folder  string                `json:"folder,omitempty"`
+	Files   []string              `json:"files,omitempty"`
+	Links   []*shareLinkView      `json:"links,omitempty"`
+	NewLink *razbox.ShareLinkInfo `json:"new_link,omitempty"`
+}
+
+func sharesPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.View {
+	r := pr.Request
+	dir := path.Clean(pr.RelPath)
+	pr.Title = "Share links of " + dir
+	v := &sharesPageView{
+		Folder: dir,
+	}
+
+	entries, flags, err := api.GetFolderEntries(pr.Session(), dir)
+	if err != nil {
+		return HandleError(r, err)
+	}
+
+	if flags == nil || !flags.EditMode {
+		return pr.RedirectView(
+			fmt.Sprintf("/write-auth/%s?r=%s", dir, r.URL.RequestURI()),
+			beepboop.WithErrorMessage("Write access required", http.StatusUnauthorized))
+	}
+
+	for _, entry := range entries {
+		if !entry.Folder {
+			v.Files = append(v.Files, entry.Name)
+		}
+	}
+
+	var actionErr error
+	if r.Method == "POST" {
+		r.ParseForm()
+
+		switch r.FormValue("action") {
+		case "create":
+			expiresIn, _ := strconv.Atoi(r.FormValue("expires_in"))
+			maxDownloads, _ := strconv.Atoi(r.FormValue("max_downloads"))
+			v.NewLink, actionErr = api.CreateShareLink(pr.Session(), &razbox.ShareLinkOptions{
+				Path:         path.Join(dir, r.FormValue("file")),
+				ExpiresIn:    time.Duration(expiresIn) * time.Hour,
+				MaxDownloads: maxDownloads,
+				Password:     r.FormValue("password"),
+			})
+		case "revoke":
+			actionErr = api.RevokeShareLink(pr.Session(), dir, r.FormValue("id"))
+		}
+	}
+
+	links, err := api.GetShareLinks(pr.Session(), dir)
+	if err != nil {
+		return HandleError(r, err)
+	}
+	for _, link := range links {
+		v.Links = append(v.Links, &shareLinkView{
+			ShareLinkInfo: link,
+			ExpiresAt:     time.Unix(link.Expires, 0).Format("2006-01-02 15:04"),
+		})
+	}
+
+	if actionErr != nil {
+		v.Error = actionErr.Error()
+		return pr.Respond(v, WithError(actionErr))
+	}
+	return pr.Respond(v)
+}
+
+// Shares returns a beepboop.Page that handles share link management of folders
+func Shares(api *razbox.API) *beepboop.Page {
+	return &beepboop.Page{
+		Path:            "/shares/",
+		ContentTemplate: GetContentTemplate("shares"),
+		Handler: func(pr *beepboop.PageRequest) *beepboop.View {
+			return sharesPageHandler(api, pr)
+		},
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"path"
+	"strings"
 
 	"github.com/razzie/beepboop"
 	"github.com/razzie/razbox"
@@ -26,12 +27,19 @@ func createSubfolderPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beep
 
 	if r.Method == "POST" {
 		r.ParseForm()
-		subfolderName := r.FormValue("subfolder")
+		o := &razbox.CreateSubfolderOptions{
+			Name:           r.FormValue("subfolder"),
+			OwnConfig:      r.FormValue("own_config") == "own_config",
+			ReadPassword:   r.FormValue("read-password"),
+			WritePassword:  r.FormValue("write-password"),
+			UploadPassword: r.FormValue("upload-password"),
+			ParentManaged:  r.FormValue("parent_managed") == "parent_managed",
+		}
 
-		subfolderPath, err := api.CreateSubfolder(pr.Session(), dir, subfolderName)
+		subfolderPath, err := api.CreateSubfolderWithOptions(pr.Session(), dir, o)
 		if err != nil {
 			v.Error = err.Error()
-			return pr.Respond(v, beepboop.WithError(err, http.StatusInternalServerError))
+			return pr.Respond(v, WithError(err))
 		}
 
 		return pr.RedirectView("/x/" + subfolderPath)
@@ -83,3 +91,98 @@ func DeleteSubfolder(api *razbox.API) *beepboop.Page {
 		},
 	}
 }
+
+type editSubfolderPageView struct {
+	Error      string   `json:"error,omitempty"`
+	Folder     string   `json:"folder,omitempty"`
+	Name       string   `json:"name,omitempty"`
+	Subfolders []string `json:"subfolders,omitempty"`
+	Editable   bool     `json:"editable"`
+	Redirect   string   `json:"redirect,omitempty"`
+}
+
+func editSubfolderPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.View {
+	r := pr.Request
+	dir := path.Clean(pr.RelPath)
+	parent := path.Dir(dir)
+	pr.Title = "Edit subfolder " + dir
+
+	flags, err := api.GetFolderFlags(pr.Session(), dir)
+	if err != nil {
+		return HandleError(r, err)
+	}
+
+	if !flags.EditMode {
+		return pr.RedirectView(
+			fmt.Sprintf("/write-auth/%s?r=%s", dir, r.URL.RequestURI()),
+			beepboop.WithErrorMessage("Write access required", http.StatusUnauthorized))
+	}
+
+	// subfolders of the config root that the folder can be moved to
+	var subfolders []string
+	relSubfolders, _ := api.GetSubfolders(pr.Session(), dir)
+	for _, relSubfolder := range relSubfolders {
+		subfolder := path.Join(dir, relSubfolder)
+		if subfolder == parent || subfolder == dir || strings.HasPrefix(subfolder, dir+"/") {
+			continue
+		}
+		subfolders = append(subfolders, subfolder)
+	}
+
+	v := &editSubfolderPageView{
+		Folder:     dir,
+		Name:       path.Base(dir),
+		Subfolders: subfolders,
+		Editable:   !flags.Configurable,
+		Redirect:   "/x/" + dir,
+	}
+
+	if flags.Configurable {
+		v.Error = "Folders with their own config can only be changed by the admin"
+		return pr.Respond(v)
+	}
+
+	if r.Method == "POST" {
+		r.ParseForm()
+
+		if r.FormValue("delete") == "delete" {
+			_, err := api.DeleteSubfolderRecursive(pr.Session(), dir)
+			if err != nil {
+				v.Error = err.Error()
+				return pr.Respond(v, WithError(err))
+			}
+			return pr.RedirectView("/x/" + parent)
+		}
+
+		newPath := dir
+		if name := r.FormValue("name"); len(name) > 0 && name != v.Name {
+			newPath, err = api.RenameSubfolder(pr.Session(), newPath, name)
+			if err != nil {
+				v.Error = err.Error()
+				return pr.Respond(v, WithError(err))
+			}
+		}
+		if moveTo := r.FormValue("move"); len(moveTo) > 0 {
+			newPath, err = api.MoveSubfolder(pr.Session(), newPath, moveTo)
+			if err != nil {
+				v.Error = err.Error()
+				return pr.Respond(v, WithError(err))
+			}
+		}
+
+		return pr.RedirectView("/x/" + newPath)
+	}
+
+	return pr.Respond(v)
+}
+
+// EditSubfolder returns a beepboop.Page that handles renaming, moving and recursive deletion of subfolders
+func EditSubfolder(api *razbox.API) *beepboop.Page {
+	return &beepboop.Page{
+		Path:            "/edit-subfolder/",
+		ContentTemplate: GetContentTemplate("edit-subfolder"),
+		Handler: func(pr *beepboop.PageRequest) *beepboop.View {
+			return editSubfolderPageHandler(api, pr)
+		},
+	}
+}
@@ -0,0 +1,65 @@
+package page
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/razzie/beepboop"
+	"github.com/razzie/razbox"
+)
+
+type trashPageView struct {
+	Error    string                    `json:"error,omitempty"`
+	Folder   string                    `json:"folder,omitempty"`
+	Files    []*razbox.TrashedFileInfo `json:"files,omitempty"`
+	Restored string                    `json:"restored,omitempty"`
+}
+
+func trashPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.View {
+	r := pr.Request
+	dir := path.Clean(pr.RelPath)
+	pr.Title = "Trash of " + dir
+	v := &trashPageView{
+		Folder: dir,
+	}
+
+	flags, err := api.GetFolderFlags(pr.Session(), dir)
+	if err != nil {
+		return HandleError(r, err)
+	}
+
+	if !flags.EditMode {
+		return pr.RedirectView(
+			fmt.Sprintf("/write-auth/%s?r=%s", dir, r.URL.RequestURI()),
+			beepboop.WithErrorMessage("Write access required", http.StatusUnauthorized))
+	}
+
+	var restoreErr error
+	if r.Method == "POST" {
+		r.ParseForm()
+		v.Restored, restoreErr = api.RestoreTrashedFile(pr.Session(), dir, r.FormValue("id"))
+	}
+
+	v.Files, err = api.GetTrash(pr.Session(), dir)
+	if err != nil {
+		return HandleError(r, err)
+	}
+
+	if restoreErr != nil {
+		v.Error = restoreErr.Error()
+		return pr.Respond(v, WithError(restoreErr))
+	}
+	return pr.Respond(v)
+}
+
+// Trash returns a beepboop.Page that lists and restores deleted files
+func Trash(api *razbox.API) *beepboop.Page {
+	return &beepboop.Page{
+		Path:            "/trash/",
+		ContentTemplate: GetContentTemplate("trash"),
+		Handler: func(pr *beepboop.PageRequest) *beepboop.View {
+			return trashPageHandler(api, pr)
+		},
+	}
+}
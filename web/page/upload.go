@@ -2,9 +2,7 @@ package page
 
 import (
 	"fmt"
-	"net/http"
 	"path"
-	"strings"
 
 	"github.com/razzie/beepboop"
 	"github.com/razzie/razbox"
@@ -14,31 +12,27 @@ type uploadPageView struct {
 	Error       string `json:"error,omitempty"`
 	Folder      string `json:"folder,omitempty"`
 	MaxFileSize string `json:"max_file_size,omitempty"`
+	UploadOnly  bool   `json:"upload_only,omitempty"`
 }
 
 func uploadPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.View {
 	r := pr.Request
 	dir := path.Clean(pr.RelPath)
 
-	flags, err := api.GetFolderFlags(pr.Session(), dir)
+	flags, err := api.GetUploadFlags(pr.Session(), dir)
 	if err != nil {
 		return HandleError(r, err)
 	}
 
-	if !flags.EditMode {
-		return pr.RedirectView(
-			fmt.Sprintf("/write-auth/%s?r=%s", dir, r.URL.RequestURI()),
-			beepboop.WithErrorMessage("Write access required", http.StatusUnauthorized))
-	}
-
 	pr.Title = "Upload file to " + dir
 	v := &uploadPageView{
 		Folder:      dir,
 		MaxFileSize: fmt.Sprintf("%dMB", flags.MaxUploadSizeMB),
+		UploadOnly:  !flags.EditMode,
 	}
 	handleError := func(err error) *beepboop.View {
 		v.Error = err.Error()
-		return pr.Respond(v, beepboop.WithError(err, http.StatusInternalServerError))
+		return pr.Respond(v, WithError(err))
 	}
 
 	if r.Method == "POST" {
@@ -51,22 +45,19 @@ func uploadPageHandler(api *razbox.API, pr *beepboop.PageRequest) *beepboop.View
 			R: r.Body,
 			N: limit,
 		}
-		r.ParseMultipartForm(1 << 20)
-		defer r.MultipartForm.RemoveAll()
-
 		o := &razbox.UploadFileOptions{
-			Folder:    dir,
-			Files:     r.MultipartForm.File["files"],
-			Filename:  r.FormValue("filename"),
-			Tags:      strings.Fields(r.FormValue("tags")),
-			Overwrite: r.FormValue("overwrite") == "overwrite",
-			Public:    r.FormValue("public") == "public",
+			Folder:      dir,
+			Content:     r.Body,
+			ContentType: r.Header.Get("Content-Type"),
 		}
 		err = api.UploadFile(pr.Session(), o)
 		if err != nil {
 			return handleError(err)
 		}
 
+		if v.UploadOnly {
+			return pr.RedirectView("/upload/" + dir)
+		}
 		return pr.RedirectView("/x/" + dir)
 	}
 
@@ -30,8 +30,30 @@ func HandleError(r *http.Request, err error) *beepboop.View {
 		return beepboop.RedirectView(r,
 			fmt.Sprintf("/write-auth/%s?r=%s", err.Folder, r.URL.RequestURI()),
 			beepboop.WithError(err, http.StatusUnauthorized))
+	case *razbox.ErrNoUploadAccess:
+		return beepboop.RedirectView(r,
+			fmt.Sprintf("/upload-auth/%s?r=%s", err.Folder, r.URL.RequestURI()),
+			beepboop.WithError(err, http.StatusUnauthorized))
 	default:
-		return beepboop.ErrorView(r, err.Error(), http.StatusInternalServerError)
+		statusCode, _ := razbox.ClassifyError(err)
+		return beepboop.ErrorView(r, err.Error(), statusCode, withRetryAfter(err))
+	}
+}
+
+// WithError is like beepboop.WithError, but the status code is determined by razbox.ClassifyError
+func WithError(err error) beepboop.ViewOption {
+	statusCode, _ := razbox.ClassifyError(err)
+	return func(view *beepboop.View) {
+		beepboop.WithError(err, statusCode)(view)
+		withRetryAfter(err)(view)
+	}
+}
+
+func withRetryAfter(err error) beepboop.ViewOption {
+	return func(view *beepboop.View) {
+		if retryAfter, ok := razbox.GetRetryAfter(err); ok {
+			beepboop.WithHeader("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))(view)
+		}
 	}
 }
 